@@ -0,0 +1,168 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/boltdb/bolt"
+)
+
+// 当前值等于old时写入new并返回true，不等时不写入返回false。old为nil表示键应当不存在。
+// 比较和写入在同一个写事务中完成
+func (b *dbConnection) CompareAndSwap(tn string, key, old, new interface{}) (swapped bool, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return false, fmt.Errorf("invalid key:%w", err)
+	}
+	var o []byte
+	if old != nil {
+		if o, err = dataToBytes(old); err != nil {
+			return false, fmt.Errorf("invalid old value:%v", err)
+		}
+	}
+	v, err := dataToBytes(new)
+	if err != nil {
+		return false, fmt.Errorf("invalid value:%v", err)
+	}
+	if err = debugCheck(key, new, k, v); err != nil {
+		return false, err
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		swapped = false
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		cur := liveValue(tx, bucket, tn, k)
+		if (old == nil) != (cur == nil) || !bytes.Equal(cur, o) {
+			return nil
+		}
+		stored, err := b.putValue(tx, bucket, tn, k, v)
+		if err != nil {
+			return fmt.Errorf("set %v.%v failed: %w", tn, k, err)
+		}
+		swapped = true
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+// 把键的值当作8字节大端的int64加上delta，返回新值。键不存在时从0开始，
+// Incr(tn, key, 0)可用来读取计数。值不是8字节或溢出时返回错误且不写入
+func (b *dbConnection) Incr(tn, key string, delta int64) (n int64, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		n = 0
+		if cur := liveValue(tx, bucket, tn, k); cur != nil {
+			if len(cur) != 8 {
+				return fmt.Errorf("value of %v.%v is not a counter: %d bytes", tn, key, len(cur))
+			}
+			n = int64(binary.BigEndian.Uint64(cur))
+		}
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return fmt.Errorf("counter %v.%v overflows: %d%+d", tn, key, n, delta)
+		}
+		n += delta
+
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(n))
+		stored, err := b.putValue(tx, bucket, tn, k, v)
+		if err != nil {
+			return fmt.Errorf("set %v.%v failed: %w", tn, k, err)
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// 同Incr(tn, key, -delta)
+func (b *dbConnection) Decr(tn, key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, fmt.Errorf("counter %v.%v overflows: -%d", tn, key, delta)
+	}
+	return b.Incr(tn, key, -delta)
+}
+
+// 把data追加到键的值后面，键不存在时等同于Set
+func (b *dbConnection) Append(tn string, key, data interface{}) error {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	d, err := dataToBytes(data)
+	if err != nil {
+		return fmt.Errorf("invalid value:%v", err)
+	}
+
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		cur := liveValue(tx, bucket, tn, k)
+		v := make([]byte, 0, len(cur)+len(d))
+		v = append(append(v, cur...), d...)
+		stored, err := b.putValue(tx, bucket, tn, k, v)
+		if err != nil {
+			return fmt.Errorf("set %v.%v failed: %w", tn, k, err)
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
+	})
+}
+
+// 写入新值并返回旧值，键不存在时旧值为nil
+func (b *dbConnection) GetSet(tn string, key, value interface{}) (old []byte, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+	v, err := dataToBytes(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value:%v", err)
+	}
+	if err = debugCheck(key, value, k, v); err != nil {
+		return nil, err
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		old = nil
+		if cur := liveValue(tx, bucket, tn, k); cur != nil {
+			old = append([]byte{}, cur...)
+		}
+		stored, err := b.putValue(tx, bucket, tn, k, v)
+		if err != nil {
+			return fmt.Errorf("set %v.%v failed: %w", tn, k, err)
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// 键不存在时写入并返回true，已存在时不写入返回false
+func (b *dbConnection) SetNX(tn string, key, value interface{}) (bool, error) {
+	return b.CompareAndSwap(tn, key, nil, value)
+}
@@ -0,0 +1,201 @@
+package bdb
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	var tests = []struct {
+		old, new interface{}
+		want     bool
+		value    string
+	}{
+		{"x", "a", false, ""},
+		{nil, "a", true, "a"},
+		{nil, "b", false, "a"},
+		{"b", "c", false, "a"},
+		{"a", 1, true, "1"},
+		{1, "", true, ""},
+		{nil, "d", false, ""},
+		{"", "e", true, "e"},
+	}
+	for _, test := range tests {
+		got, err := db.CompareAndSwap("test", "k", test.old, test.new)
+		if err != nil || got != test.want {
+			t.Errorf("db.CompareAndSwap(%v, %v) == %v, %v, want %v", test.old, test.new, got, err, test.want)
+		}
+		if v := string(db.Get("test", "k")); v != test.value {
+			t.Errorf("value after CompareAndSwap(%v, %v) == %q, want %q", test.old, test.new, v, test.value)
+		}
+	}
+
+	if _, err := db.CompareAndSwap("missing", "k", nil, "v"); err == nil {
+		t.Errorf("db.CompareAndSwap(missing table) err=nil, want error")
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "n", 0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; {
+				cur, _ := strconv.Atoi(string(db.Get("test", "n")))
+				if ok, err := db.CompareAndSwap("test", "n", cur, cur+1); err != nil {
+					t.Errorf("db.CompareAndSwap() failed, err=%v", err)
+					return
+				} else if ok {
+					i++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := string(db.Get("test", "n")); got != "160" {
+		t.Errorf("counter == %q, want %q", got, "160")
+	}
+}
+
+func TestIncr(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	var tests = []struct {
+		delta int64
+		want  int64
+	}{
+		{0, 0},
+		{5, 5},
+		{-7, -2},
+		{2, 0},
+	}
+	for _, test := range tests {
+		if got, err := db.Incr("test", "n", test.delta); err != nil || got != test.want {
+			t.Errorf("db.Incr(%d) == %d, %v, want %d", test.delta, got, err, test.want)
+		}
+	}
+	if got, err := db.Decr("test", "n", 3); err != nil || got != -3 {
+		t.Errorf("db.Decr(3) == %d, %v, want -3", got, err)
+	}
+
+	db.Incr("test", "max", math.MaxInt64)
+	if _, err := db.Incr("test", "max", 1); err == nil {
+		t.Errorf("db.Incr() overflow err=nil, want error")
+	}
+	if got, _ := db.Incr("test", "max", 0); got != math.MaxInt64 {
+		t.Errorf("counter after overflow == %d, want %d", got, int64(math.MaxInt64))
+	}
+	db.Set("test", "text", "abc")
+	if _, err := db.Incr("test", "text", 1); err == nil {
+		t.Errorf("db.Incr(non counter) err=nil, want error")
+	}
+}
+
+func TestIncrConcurrent(t *testing.T) {
+	db := openTestDB(t, "test")
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := db.Incr("test", "n", 1); err != nil {
+					t.Errorf("db.Incr() failed, err=%v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := db.Incr("test", "n", 0); got != 400 {
+		t.Errorf("counter == %d, want 400", got)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	for _, data := range []interface{}{"a", []byte("b"), 1} {
+		if err := db.Append("test", "log", data); err != nil {
+			t.Fatalf("db.Append(%v) failed, err=%v", data, err)
+		}
+	}
+	if got := string(db.Get("test", "log")); got != "ab1" {
+		t.Errorf("db.Get(log) == %q, want %q", got, "ab1")
+	}
+	if err := db.Append("missing", "log", "a"); err == nil {
+		t.Errorf("db.Append(missing table) err=nil, want error")
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				db.Append("test", "buf", "x")
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := db.ValueSize("test", "buf"); n != 160 {
+		t.Errorf("db.ValueSize(buf) == %d, want 160", n)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	if old, err := db.GetSet("test", "token", "a"); err != nil || old != nil {
+		t.Errorf("db.GetSet(first) == %q, %v, want nil, nil", old, err)
+	}
+	if old, err := db.GetSet("test", "token", "b"); err != nil || string(old) != "a" {
+		t.Errorf("db.GetSet(second) == %q, %v, want \"a\", nil", old, err)
+	}
+	if got := string(db.Get("test", "token")); got != "b" {
+		t.Errorf("db.Get(token) == %q, want %q", got, "b")
+	}
+	if _, err := db.GetSet("missing", "token", "a"); err == nil {
+		t.Errorf("db.GetSet(missing table) err=nil, want error")
+	}
+}
+
+func TestSetNX(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			ok, err := db.SetNX("test", "lock", g)
+			if err != nil {
+				t.Errorf("db.SetNX() failed, err=%v", err)
+			}
+			if ok {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("SetNX winners == %d, want 1", winners)
+	}
+
+	db.Delete("test", "lock")
+	if ok, err := db.SetNX("test", "lock", "again"); err != nil || !ok {
+		t.Errorf("db.SetNX() after Delete == %v, %v, want true, nil", ok, err)
+	}
+}
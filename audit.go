@@ -0,0 +1,126 @@
+package bdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 存放审计记录的表，键为8字节大端的序号，值为JSON编码的AuditRecord
+const auditTable = "__audit"
+
+// 审计模式
+type AuditMode int32
+
+const (
+	AuditOff          AuditMode = iota // 不记录，默认值
+	AuditOn                            // 记录每次写入、删除
+	AuditWithOldValue                  // 同时记录修改前的值
+)
+
+// 一条审计记录
+type AuditRecord struct {
+	Seq   uint64    `json:"seq"`
+	Time  time.Time `json:"time"`
+	Table string    `json:"table"`
+	Key   []byte    `json:"key"`
+	Op    string    `json:"op"` // set或delete
+	Old   []byte    `json:"old,omitempty"`
+}
+
+type auditor struct {
+	mode atomic.Int32
+}
+
+// 开启或关闭审计。开启后单条的写入和删除在同一个事务中追加一条审计记录，
+// 整表的操作和过期清理不记录
+func (b *dbConnection) SetAudit(mode AuditMode) {
+	b.auditor.mode.Store(int32(mode))
+}
+
+func (b *dbConnection) auditMode() AuditMode {
+	return AuditMode(b.auditor.mode.Load())
+}
+
+// 在写事务中追加一条审计记录
+func (b *dbConnection) audit(tx *bolt.Tx, op, tn string, k, old []byte) error {
+	mode := b.auditMode()
+	if mode == AuditOff {
+		return nil
+	}
+	bucket, err := tx.CreateBucketIfNotExists([]byte(auditTable))
+	if err != nil {
+		return err
+	}
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	r := AuditRecord{Seq: seq, Time: time.Now(), Table: tn, Key: k, Op: op}
+	if mode == AuditWithOldValue {
+		r.Old = old
+	}
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(seqKey(seq), v)
+}
+
+// 按序号返回after之后的最多limit条审计记录，limit<=0时不限条数
+func (b *dbConnection) AuditLog(after uint64, limit int) (records []AuditRecord, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		records = []AuditRecord{}
+		bucket := tx.Bucket([]byte(auditTable))
+		if bucket == nil || after == ^uint64(0) {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.Seek(seqKey(after + 1)); k != nil && (limit <= 0 || len(records) < limit); k, v = c.Next() {
+			var r AuditRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("decode audit record %d failed: %v", binary.BigEndian.Uint64(k), err)
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// 删除序号不大于upTo的审计记录，返回删除的条数
+func (b *dbConnection) TruncateAudit(upTo uint64) (count int, err error) {
+	err = b.update(func(tx *bolt.Tx) error {
+		count = 0
+		bucket := tx.Bucket([]byte(auditTable))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= upTo; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func seqKey(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
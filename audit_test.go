@@ -0,0 +1,53 @@
+package bdb
+
+import (
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "before", "v")
+
+	db.SetAudit(AuditWithOldValue)
+	db.Set("test", "k", "1")
+	db.Set("test", "k", "2")
+	db.Delete("test", "k")
+	db.Delete("test", "missing")
+	db.SetAudit(AuditOn)
+	db.Set("test", "k", "3")
+	db.SetAudit(AuditOff)
+	db.Set("test", "after", "v")
+
+	var want = []struct {
+		op, key, old string
+	}{
+		{"set", "k", ""},
+		{"set", "k", "1"},
+		{"delete", "k", "2"},
+		{"delete", "missing", ""},
+		{"set", "k", ""},
+	}
+	records, err := db.AuditLog(0, 0)
+	if err != nil || len(records) != len(want) {
+		t.Fatalf("db.AuditLog() == %d records, %v, want %d", len(records), err, len(want))
+	}
+	for i, w := range want {
+		r := records[i]
+		if r.Seq != uint64(i+1) || r.Table != "test" || r.Op != w.op || string(r.Key) != w.key || string(r.Old) != w.old || r.Time.IsZero() {
+			t.Errorf("records[%d] == %+v, want %v", i, r, w)
+		}
+	}
+
+	if page, _ := db.AuditLog(2, 2); len(page) != 2 || page[0].Seq != 3 || page[1].Seq != 4 {
+		t.Errorf("db.AuditLog(2, 2) == %+v, want seq 3, 4", page)
+	}
+	if n, err := db.TruncateAudit(3); err != nil || n != 3 {
+		t.Errorf("db.TruncateAudit(3) == %d, %v, want 3, nil", n, err)
+	}
+	if rest, _ := db.AuditLog(0, 0); len(rest) != 2 || rest[0].Seq != 4 {
+		t.Errorf("db.AuditLog() after truncate == %+v, want seq 4, 5", rest)
+	}
+	if tables, _ := db.ListTables(); len(tables) != 1 {
+		t.Errorf("db.ListTables() == %v, want [test]", tables)
+	}
+}
@@ -0,0 +1,28 @@
+package bdb
+
+import (
+	"errors"
+)
+
+// 排队的写操作超过上限
+var ErrWriteBacklog = errors.New("too many pending writes")
+
+// bolt的写事务是串行的，写入突增时调用方会无限排队；
+// 设置上限后超出的写直接返回ErrWriteBacklog，由调用方自行降级
+func (b *dbConnection) SetMaxPendingWrites(n int) {
+	b.maxPending.Store(int64(n))
+}
+
+func (b *dbConnection) PendingWrites() int {
+	return int(b.pendingWrites.Load())
+}
+
+// 占用一个写名额，成功后由调用方负责归还
+func (b *dbConnection) acquireWrite() error {
+	n := b.pendingWrites.Add(1)
+	if max := b.maxPending.Load(); max > 0 && n > max {
+		b.pendingWrites.Add(-1)
+		return ErrWriteBacklog
+	}
+	return nil
+}
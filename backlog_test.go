@@ -0,0 +1,46 @@
+package bdb
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestMaxPendingWrites(t *testing.T) {
+	db := openTestDB(t, "test")
+	conn := db.(*dbConnection)
+	db.SetMaxPendingWrites(1)
+
+	// 占住唯一的写名额
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- conn.update(func(tx *bolt.Tx) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	if n := db.PendingWrites(); n != 1 {
+		t.Errorf("db.PendingWrites() == %d, want 1", n)
+	}
+	if err := db.Set("test", "k", "v"); err != ErrWriteBacklog {
+		t.Errorf("db.Set() over backlog err=%v, want ErrWriteBacklog", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("blocked update failed, err=%v", err)
+	}
+	if n := db.PendingWrites(); n != 0 {
+		t.Errorf("db.PendingWrites() == %d after release, want 0", n)
+	}
+	if err := db.Set("test", "k", "v"); err != nil {
+		t.Errorf("db.Set() after release failed, err=%v", err)
+	}
+
+	db.SetMaxPendingWrites(0)
+}
@@ -0,0 +1,312 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 在一个只读事务中把整个数据库写到w，期间可以正常读写，返回写入的字节数
+func (b *dbConnection) Backup(w io.Writer) (n int64, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// 备份到文件，先写临时文件再改名，不会留下写了一半的备份
+func (b *dbConnection) BackupToFile(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, b.fileMode())
+	if err != nil {
+		return fmt.Errorf("create backup file failed:%w", err)
+	}
+	if _, err = b.Backup(f); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backup to %v failed:%w", path, err)
+	}
+	return nil
+}
+
+// 校验r中的bolt文件后替换当前数据库文件并重新打开。等待进行中的操作结束，
+// 替换前写入缓冲中的数据；文件无效时当前数据库不受影响。
+// 重新打开失败时连接被关闭，之后的操作返回ErrClosed
+func (b *dbConnection) Restore(r io.Reader) error {
+	name := b.GetDBName()
+	if name == "" {
+		return ErrNotOpen
+	}
+	if b.readOnly() {
+		return ErrReadOnly
+	}
+
+	// 临时文件与数据库在同一目录，改名才是原子的
+	tmp := name + ".restore"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, b.fileMode())
+	if err != nil {
+		return fmt.Errorf("create restore file failed:%w", err)
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = checkBoltFile(tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("restore failed:%w", err)
+	}
+
+	b.lockExclusive(false)
+	defer b.unlockExclusive()
+	if b.closed || b.bdb == nil {
+		os.Remove(tmp)
+		return ErrClosed
+	}
+	if err := b.flushBuffer(); err != nil {
+		b.log().Error("bdb: flush write buffer before restore failed", "db", b.name, "err", err)
+	}
+	return b.swapLocked(tmp)
+}
+
+// 持有写锁时用tmp替换数据库文件并重新打开
+func (b *dbConnection) swapLocked(tmp string) error {
+	b.bdb.Close()
+	if err := os.Rename(tmp, b.name); err != nil {
+		os.Remove(tmp)
+		return b.reopenLocked(fmt.Errorf("replace %v failed:%w", b.name, err))
+	}
+	return b.reopenLocked(nil)
+}
+
+// 从path中的备份恢复，见Restore
+func (b *dbConnection) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open backup failed:%w", err)
+	}
+	defer f.Close()
+	return b.Restore(f)
+}
+
+// 持有写锁时重新打开数据库文件，打开失败时标记为已关闭。cause不为nil时作为返回的错误
+func (b *dbConnection) reopenLocked(cause error) error {
+	db, err := b.openBolt(b.name, b.mode)
+	if err != nil {
+		b.bdb = nil
+		b.closed = true
+		return errors.Join(cause, fmt.Errorf("reopen %v failed:%w", b.name, err))
+	}
+	b.bdb = db
+	return cause
+}
+
+// 以只读方式打开文件并检查所有页
+func checkBoltFile(path string) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("invalid bolt file:%w", err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		// 读完所有错误，检查协程结束后才能结束事务
+		var first error
+		for err := range tx.Check() {
+			if first == nil {
+				first = fmt.Errorf("corrupted bolt file:%w", err)
+			}
+		}
+		return first
+	})
+}
+
+// 新建文件使用的权限，与打开数据库时相同
+func (b *dbConnection) fileMode() os.FileMode {
+	b.lifecycle.RLock()
+	defer b.lifecycle.RUnlock()
+	if b.mode == 0 {
+		return 0600
+	}
+	return b.mode
+}
+
+// 定时备份的配置
+type BackupSchedule struct {
+	Interval time.Duration // 备份间隔
+	Dir      string        // 备份目录
+	Pattern  string        // 备份在Dir下的路径，按time.Format的布局生成，可以包含子目录；为空时使用DefaultBackupPattern
+	Keep     int           // 保留最近的份数，0为全部保留
+
+	Sink BackupSink // 不为nil时备份交给Sink以Pattern生成的名字保存，不使用Dir，也不删除旧备份
+}
+
+// 默认的备份文件名
+const DefaultBackupPattern = "backup-20060102-150405.db"
+
+// 最近一次定时备份的结果
+type BackupStatus struct {
+	Time     time.Time     `json:"time"`
+	Path     string        `json:"path"`
+	Size     int64         `json:"size"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// 后台定时备份
+type backups struct {
+	mu     sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+	status *BackupStatus
+}
+
+// 按s在后台定时备份，并删除超出Keep份数的旧备份。再次调用时替换之前的配置，
+// 结果可以用Describe查看。Close时停止
+func (b *dbConnection) ScheduleBackups(s BackupSchedule) error {
+	if s.Interval <= 0 {
+		return fmt.Errorf("invalid backup interval:%v", s.Interval)
+	}
+	if s.Dir == "" && s.Sink == nil {
+		return fmt.Errorf("empty backup dir")
+	}
+	if s.Pattern == "" {
+		s.Pattern = DefaultBackupPattern
+	}
+	if s.Keep < 0 {
+		return fmt.Errorf("invalid backup keep:%v", s.Keep)
+	}
+
+	b.StopBackups()
+	bs := &b.backups
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.stop = make(chan struct{})
+	bs.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		t := time.NewTicker(s.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-t.C:
+				b.scheduledBackup(s, now)
+			}
+		}
+	}(bs.stop, bs.done)
+	return nil
+}
+
+// 停止定时备份并等待进行中的备份结束
+func (b *dbConnection) StopBackups() {
+	bs := &b.backups
+	bs.mu.Lock()
+	stop, done := bs.stop, bs.done
+	bs.stop, bs.done = nil, nil
+	bs.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (b *dbConnection) scheduledBackup(s BackupSchedule, now time.Time) {
+	var err error
+	path := filepath.Join(s.Dir, now.Format(s.Pattern))
+	st := &BackupStatus{Time: now, Path: path}
+	if s.Sink != nil {
+		st.Path = now.Format(s.Pattern)
+		st.Size, err = b.BackupTo(s.Sink, st.Path)
+	} else {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = b.BackupToFile(path)
+		}
+		if err == nil {
+			if fi, serr := os.Stat(path); serr == nil {
+				st.Size = fi.Size()
+			}
+			err = pruneBackups(s)
+		}
+	}
+	st.Duration = time.Since(now)
+	if err != nil {
+		st.Error = err.Error()
+		b.log().Error("bdb: scheduled backup failed", "path", st.Path, "err", err)
+	} else {
+		b.log().Info("bdb: scheduled backup done", "path", st.Path, "size", st.Size, "duration", st.Duration)
+	}
+
+	b.backups.mu.Lock()
+	b.backups.status = st
+	b.backups.mu.Unlock()
+}
+
+func (b *dbConnection) lastBackup() *BackupStatus {
+	b.backups.mu.Lock()
+	defer b.backups.mu.Unlock()
+	if b.backups.status == nil {
+		return nil
+	}
+	st := *b.backups.status
+	return &st
+}
+
+// 删除Dir下能按Pattern解析出时间的文件中较旧的，只保留最近的Keep份
+func pruneBackups(s BackupSchedule) error {
+	if s.Keep == 0 {
+		return nil
+	}
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var backups []backup
+	err := filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		if at, err := time.Parse(s.Pattern, filepath.ToSlash(rel)); err == nil {
+			backups = append(backups, backup{path, at})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+	for _, old := range backups[min(s.Keep, len(backups)):] {
+		if err := os.Remove(old.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package bdb
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackup(t *testing.T) {
+	db := openTestDB(t, "test")
+	for i := 0; i < 100; i++ {
+		db.Set("test", i, i)
+	}
+
+	// 备份期间继续写入
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 100; i < 200; i++ {
+			db.Set("test", i, i)
+		}
+	}()
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := db.BackupToFile(path); err != nil {
+		t.Fatalf("db.BackupToFile() failed, err=%v", err)
+	}
+	wg.Wait()
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary backup file left behind, err=%v", err)
+	}
+
+	restored, err := Open(path, 0600)
+	if err != nil {
+		t.Fatalf("Open(backup) failed, err=%v", err)
+	}
+	defer restored.Close()
+	if n, err := restored.Count("test"); err != nil || n < 100 {
+		t.Errorf("backup Count() == %d, %v, want >= 100", n, err)
+	}
+	if got := string(restored.Get("test", 42)); got != "42" {
+		t.Errorf("backup Get(42) == %q, want %q", got, "42")
+	}
+
+	var buf bytes.Buffer
+	if n, err := db.Backup(&buf); err != nil || n != int64(buf.Len()) || n == 0 {
+		t.Errorf("db.Backup() == %d, %v, buffer %d bytes", n, err, buf.Len())
+	}
+	if err := db.BackupToFile(filepath.Join(t.TempDir(), "missing", "backup.db")); err == nil {
+		t.Errorf("db.BackupToFile(missing dir) err=nil, want error")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "k", "old")
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := db.BackupToFile(path); err != nil {
+		t.Fatalf("db.BackupToFile() failed, err=%v", err)
+	}
+	db.Set("test", "k", "new")
+	db.CreateTable("later")
+
+	if err := db.Restore(bytes.NewReader([]byte("not a bolt file"))); err == nil {
+		t.Errorf("db.Restore(garbage) err=nil, want error")
+	}
+	if got := string(db.Get("test", "k")); got != "new" {
+		t.Errorf("db.Get(k) after failed restore == %q, want %q", got, "new")
+	}
+
+	if err := db.RestoreFile(path); err != nil {
+		t.Fatalf("db.RestoreFile() failed, err=%v", err)
+	}
+	if got := string(db.Get("test", "k")); got != "old" {
+		t.Errorf("db.Get(k) after restore == %q, want %q", got, "old")
+	}
+	if db.HasTable("later") {
+		t.Errorf("table created after the backup survived the restore")
+	}
+	if err := db.Set("test", "k", "again"); err != nil {
+		t.Errorf("db.Set() after restore failed, err=%v", err)
+	}
+	if _, err := os.Stat(db.GetDBName() + ".restore"); !os.IsNotExist(err) {
+		t.Errorf("temporary restore file left behind, err=%v", err)
+	}
+}
+
+func TestScheduleBackups(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "k", "v")
+	dir := t.TempDir()
+
+	if err := db.ScheduleBackups(BackupSchedule{Dir: dir}); err == nil {
+		t.Errorf("db.ScheduleBackups(no interval) err=nil, want error")
+	}
+	// 毫秒出现在文件名中，保证每次备份的名字不同
+	s := BackupSchedule{Interval: 10 * time.Millisecond, Dir: dir, Pattern: "2006-01-02/150405.000.db", Keep: 2}
+	if err := db.ScheduleBackups(s); err != nil {
+		t.Fatalf("db.ScheduleBackups() failed, err=%v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	db.StopBackups()
+
+	var files []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if len(files) != 2 {
+		t.Errorf("backups kept == %v, want 2 files", files)
+	}
+
+	info, err := db.Describe()
+	if err != nil || info.LastBackup == nil {
+		t.Fatalf("db.Describe() LastBackup == %v, %v, want status", info.LastBackup, err)
+	}
+	if st := info.LastBackup; st.Error != "" || st.Size == 0 || st.Path != files[len(files)-1] {
+		t.Errorf("LastBackup == %+v, want successful backup at %v", st, files[len(files)-1])
+	}
+	for _, ti := range info.Tables {
+		if ti.Name != "test" {
+			t.Errorf("db.Describe() lists table %q", ti.Name)
+		}
+	}
+}
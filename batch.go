@@ -0,0 +1,210 @@
+package bdb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// 分批处理时每个写事务的条数
+const batchSize = 1000
+
+// 分批遍历表tn，每批最多batchSize条，复制出来后在同一个写事务中交给fn处理。
+// 每批单独提交，下一批从上一批最后一个键之后继续，大表也不会占用过多内存；
+// 出错时之前的批次已经提交
+func (b *dbConnection) updateInBatches(tn string, fn func(tx *bolt.Tx, kvs []KV) error) error {
+	var after []byte
+	for {
+		n := 0
+		err := b.update(func(tx *bolt.Tx) error {
+			bucket, err := getBucket(tx, tn)
+			if err != nil {
+				return err
+			}
+
+			kvs := make([]KV, 0, batchSize)
+			c := bucket.Cursor()
+			for k, v := seekAfter(c, after); k != nil && len(kvs) < batchSize; k, v = c.Next() {
+				kvs = append(kvs, copyKV(k, v))
+			}
+			n = len(kvs)
+			if n == 0 {
+				return nil
+			}
+			if err := fn(tx, kvs); err != nil {
+				return err
+			}
+			after = kvs[n-1].Key
+			return nil
+		})
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+}
+
+// 所有键值在一个写事务中写入，任何一条失败时全部不写
+func (b *dbConnection) SetBatch(tn string, kvs map[interface{}]interface{}) error {
+	encoded, err := encodeMap(kvs)
+	if err != nil {
+		return err
+	}
+
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		mkvs := make(map[interface{}]interface{}, len(encoded))
+		for _, kv := range encoded {
+			stored, err := b.putValue(tx, bucket, tn, kv.Key, kv.Value)
+			if err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", tn, kv.Key, err)
+			}
+			mkvs[string(kv.Key)] = stored
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.SetBatch(tn, mkvs) })
+	})
+}
+
+// 编码map中的所有键值
+func encodeMap(m map[interface{}]interface{}) ([]KV, error) {
+	kvs := make([]KV, 0, len(m))
+	for key, value := range m {
+		k, err := keyToBytes(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key:%w", err)
+		}
+		v, err := dataToBytes(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value:%v", err)
+		}
+		if err = debugCheck(key, value, k, v); err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, KV{Key: k, Value: v})
+	}
+	return kvs, nil
+}
+
+// GetMulti部分键不存在时返回，errors.Is(err, ErrKeyNotFound)成立
+type MissingKeysError struct {
+	Keys []interface{} // 不存在的键，与传入的相同
+}
+
+func (e *MissingKeysError) Error() string {
+	return fmt.Sprintf("%d keys not found: %v", len(e.Keys), e.Keys)
+}
+
+func (e *MissingKeysError) Unwrap() error {
+	return ErrKeyNotFound
+}
+
+// 存在的键总会返回，有键不存在时同时返回*MissingKeysError
+func (b *dbConnection) GetMulti(tn string, keys []interface{}) (map[string][]byte, error) {
+	encoded := make([][]byte, len(keys))
+	for i, key := range keys {
+		k, err := keyToBytes(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key:%w", err)
+		}
+		encoded[i] = k
+	}
+
+	ret := make(map[string][]byte, len(keys))
+	var missing []interface{}
+	err := b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		for i, k := range encoded {
+			v := liveValue(tx, bucket, tn, k)
+			if v == nil {
+				missing = append(missing, keys[i])
+				continue
+			}
+			ret[string(k)] = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return ret, &MissingKeysError{Keys: missing}
+	}
+	return ret, nil
+}
+
+// 不存在的键忽略
+func (b *dbConnection) DeleteMulti(tn string, keys ...interface{}) (count int, err error) {
+	encoded := make([][]byte, len(keys))
+	for i, key := range keys {
+		k, err := keyToBytes(key)
+		if err != nil {
+			return 0, fmt.Errorf("invalid key:%w", err)
+		}
+		encoded[i] = k
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		count, err = b.deleteKeys(tx, bucket, tn, encoded)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// prefix为空时删除表中所有的键，子表不删除
+func (b *dbConnection) DeleteByPrefix(tn string, prefix []byte) (count int, err error) {
+	err = b.update(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		// 边遍历边删除会让游标跳过元素，先收集键
+		var keys [][]byte
+		c := bucket.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if v != nil {
+				keys = append(keys, append([]byte{}, k...))
+			}
+		}
+		count, err = b.deleteKeys(tx, bucket, tn, keys)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// 删除keys中存在的键(子表除外)，返回删除的条数
+func (b *dbConnection) deleteKeys(tx *bolt.Tx, bucket *bolt.Bucket, tn string, keys [][]byte) (int, error) {
+	deleted := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		if v := bucket.Get(k); v == nil {
+			continue
+		}
+		if err := b.del(tx, bucket, tn, k); err != nil {
+			return 0, fmt.Errorf("delete %v.%q failed: %w", tn, k, err)
+		}
+		deleted = append(deleted, k)
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	return len(deleted), b.mirrorWrite(tx, func(m BoltDB) error {
+		_, err := m.DeleteMulti(tn, deleted...)
+		return err
+	})
+}
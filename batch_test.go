@@ -0,0 +1,106 @@
+package bdb
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestSetBatch(t *testing.T) {
+	db := openTestDB(t, "test")
+	kvs := make(map[interface{}]interface{})
+	for i := 0; i < 100; i++ {
+		kvs[i] = "v" + strconv.Itoa(i)
+	}
+	if err := db.SetBatch("test", kvs); err != nil {
+		t.Fatalf("db.SetBatch() failed, err=%v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if got, want := string(db.Get("test", i)), "v"+strconv.Itoa(i); got != want {
+			t.Errorf("db.Get(%d) == %q, want %q", i, got, want)
+		}
+	}
+
+	// 编码失败时一条都不写
+	if err := db.SetBatch("test", map[interface{}]interface{}{"a": "1", "b": struct{}{}}); err == nil {
+		t.Errorf("db.SetBatch(bad value) succeeded")
+	}
+	if v := db.Get("test", "a"); v != nil {
+		t.Errorf("db.Get(a) == %q after failed batch, want nil", v)
+	}
+	if err := db.SetBatch("missing", kvs); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.SetBatch(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", 1, "one")
+	db.Set("test", "two", "2")
+
+	got, err := db.GetMulti("test", []interface{}{1, "two"})
+	if err != nil {
+		t.Fatalf("db.GetMulti() failed, err=%v", err)
+	}
+	if len(got) != 2 || string(got["1"]) != "one" || string(got["two"]) != "2" {
+		t.Errorf("db.GetMulti() == %q", got)
+	}
+
+	got, err = db.GetMulti("test", []interface{}{1, "x", 3})
+	var mke *MissingKeysError
+	if !errors.As(err, &mke) || !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("db.GetMulti(missing) err=%v, want *MissingKeysError", err)
+	}
+	if len(mke.Keys) != 2 || mke.Keys[0] != "x" || mke.Keys[1] != 3 {
+		t.Errorf("MissingKeysError.Keys == %v, want [x 3]", mke.Keys)
+	}
+	if len(got) != 1 || string(got["1"]) != "one" {
+		t.Errorf("db.GetMulti(missing) == %q, want only key 1", got)
+	}
+
+	if _, err := db.GetMulti("missing", []interface{}{1}); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.GetMulti(missing table) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestDeleteMulti(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"a", "b", "c"} {
+		db.Set("test", k, k)
+	}
+
+	if n, err := db.DeleteMulti("test", "a", "c", "missing"); err != nil || n != 2 {
+		t.Errorf("db.DeleteMulti() == %d, %v, want 2, nil", n, err)
+	}
+	if db.Get("test", "a") != nil || db.Get("test", "c") != nil || string(db.Get("test", "b")) != "b" {
+		t.Errorf("db.DeleteMulti() removed the wrong keys")
+	}
+	if _, err := db.DeleteMulti("test", "b", ""); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("db.DeleteMulti(empty key) err=%v, want ErrEmptyKey", err)
+	}
+	if string(db.Get("test", "b")) != "b" {
+		t.Errorf("db.DeleteMulti() with a bad key deleted b")
+	}
+}
+
+func TestDeleteByPrefix(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"user:1", "user:2", "user:30", "users", "order:1"} {
+		db.Set("test", k, k)
+	}
+
+	if n, err := db.DeleteByPrefix("test", []byte("user:")); err != nil || n != 3 {
+		t.Errorf("db.DeleteByPrefix(user:) == %d, %v, want 3, nil", n, err)
+	}
+	for k, want := range map[string]bool{"user:1": false, "user:30": false, "users": true, "order:1": true} {
+		if got := db.Get("test", k) != nil; got != want {
+			t.Errorf("key %q exists == %v, want %v", k, got, want)
+		}
+	}
+	if n, err := db.DeleteByPrefix("test", nil); err != nil || n != 2 {
+		t.Errorf("db.DeleteByPrefix(nil) == %d, %v, want 2, nil", n, err)
+	}
+	if _, err := db.DeleteByPrefix("missing", nil); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.DeleteByPrefix(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
@@ -0,0 +1,148 @@
+// bdbtest提供测试数据层代码用的临时数据库、可注入错误的Fake和断言函数
+package bdbtest
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/betterjun/bdb"
+)
+
+// 打开一个临时数据库并创建给定的表，测试结束时关闭并删除
+func Open(t testing.TB, tables ...string) bdb.BoltDB {
+	t.Helper()
+	db, err := bdb.OpenMemory()
+	if err != nil {
+		t.Fatalf("bdbtest: open memory db failed, err=%v", err)
+	}
+	t.Cleanup(db.Close)
+	for _, tn := range tables {
+		if err := db.CreateTable(tn); err != nil {
+			t.Fatalf("bdbtest: create table %q failed, err=%v", tn, err)
+		}
+	}
+	return db
+}
+
+// 基于临时数据库的BoltDB实现，可以让Set、Delete、GetValue、Has、Count返回指定的错误，
+// 并记录这几个方法的调用次数。其它方法直接交给内嵌的数据库
+type Fake struct {
+	bdb.BoltDB
+
+	mu    sync.Mutex
+	errs  map[string]error
+	calls map[string]int
+}
+
+// 创建Fake，表和生命周期与Open相同
+func NewFake(t testing.TB, tables ...string) *Fake {
+	t.Helper()
+	return &Fake{BoltDB: Open(t, tables...), errs: map[string]error{}, calls: map[string]int{}}
+}
+
+// 之后对method的调用都返回err而不访问数据库，err为nil时恢复正常
+func (f *Fake) FailOn(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errs, method)
+		return
+	}
+	f.errs[method] = err
+}
+
+// method被调用的次数，包括返回注入错误的调用
+func (f *Fake) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// 记录一次调用，返回注入的错误
+func (f *Fake) call(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+	return f.errs[method]
+}
+
+func (f *Fake) Set(tn string, key, value interface{}) error {
+	if err := f.call("Set"); err != nil {
+		return err
+	}
+	return f.BoltDB.Set(tn, key, value)
+}
+
+func (f *Fake) Delete(tn string, key interface{}) error {
+	if err := f.call("Delete"); err != nil {
+		return err
+	}
+	return f.BoltDB.Delete(tn, key)
+}
+
+func (f *Fake) GetValue(tn string, key interface{}) ([]byte, error) {
+	if err := f.call("GetValue"); err != nil {
+		return nil, err
+	}
+	return f.BoltDB.GetValue(tn, key)
+}
+
+func (f *Fake) Has(tn string, key interface{}) (bool, error) {
+	if err := f.call("Has"); err != nil {
+		return false, err
+	}
+	return f.BoltDB.Has(tn, key)
+}
+
+func (f *Fake) Count(tn string) (int, error) {
+	if err := f.call("Count"); err != nil {
+		return 0, err
+	}
+	return f.BoltDB.Count(tn)
+}
+
+// 读取键值，出错(包括键不存在)时测试失败
+func MustGet(t testing.TB, db bdb.BoltDB, tn string, key interface{}) []byte {
+	t.Helper()
+	v, err := db.GetValue(tn, key)
+	if err != nil {
+		t.Fatalf("bdbtest: get %v.%v failed, err=%v", tn, key, err)
+	}
+	return v
+}
+
+// 断言键的值为want
+func AssertValue(t testing.TB, db bdb.BoltDB, tn string, key interface{}, want []byte) {
+	t.Helper()
+	v, err := db.GetValue(tn, key)
+	if err != nil {
+		t.Errorf("bdbtest: get %v.%v failed, err=%v", tn, key, err)
+		return
+	}
+	if !bytes.Equal(v, want) {
+		t.Errorf("bdbtest: %v.%v == %q, want %q", tn, key, v, want)
+	}
+}
+
+// 断言键不存在
+func AssertMissing(t testing.TB, db bdb.BoltDB, tn string, key interface{}) {
+	t.Helper()
+	ok, err := db.Has(tn, key)
+	if err != nil {
+		t.Errorf("bdbtest: has %v.%v failed, err=%v", tn, key, err)
+	} else if ok {
+		t.Errorf("bdbtest: %v.%v exists, want missing", tn, key)
+	}
+}
+
+// 断言表中有n个键
+func AssertKeyCount(t testing.TB, db bdb.BoltDB, tn string, n int) {
+	t.Helper()
+	got, err := db.Count(tn)
+	if err != nil {
+		t.Errorf("bdbtest: count %v failed, err=%v", tn, err)
+	} else if got != n {
+		t.Errorf("bdbtest: %v has %d keys, want %d", tn, got, n)
+	}
+}
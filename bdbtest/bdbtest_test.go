@@ -0,0 +1,52 @@
+package bdbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/betterjun/bdb"
+)
+
+func TestOpen(t *testing.T) {
+	db := Open(t, "test")
+	db.Set("test", "k", "v")
+	if got := string(MustGet(t, db, "test", "k")); got != "v" {
+		t.Errorf("MustGet(k) == %q, want %q", got, "v")
+	}
+	AssertValue(t, db, "test", "k", []byte("v"))
+	AssertMissing(t, db, "test", "missing")
+	AssertKeyCount(t, db, "test", 1)
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake(t, "test")
+	var _ bdb.BoltDB = f
+
+	boom := errors.New("boom")
+	f.FailOn("Set", boom)
+	if err := f.Set("test", "k", "v"); err != boom {
+		t.Errorf("f.Set() err=%v, want boom", err)
+	}
+	AssertMissing(t, f, "test", "k")
+
+	f.FailOn("Set", nil)
+	if err := f.Set("test", "k", "v"); err != nil {
+		t.Errorf("f.Set() after clear err=%v", err)
+	}
+	if n := f.Calls("Set"); n != 2 {
+		t.Errorf("f.Calls(Set) == %d, want 2", n)
+	}
+
+	f.FailOn("Count", boom)
+	if _, err := f.Count("test"); err != boom {
+		t.Errorf("f.Count() err=%v, want boom", err)
+	}
+	f.FailOn("GetValue", boom)
+	if _, err := f.GetValue("test", "k"); err != boom {
+		t.Errorf("f.GetValue() err=%v, want boom", err)
+	}
+	// 未注入错误的方法直接访问数据库
+	if got := string(f.Get("test", "k")); got != "v" {
+		t.Errorf("f.Get(k) == %q, want %q", got, "v")
+	}
+}
@@ -0,0 +1,90 @@
+package bdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// big.Int编码的首字节
+const (
+	bigIntNeg  = 0x00
+	bigIntZero = 0x01
+	bigIntPos  = 0x02
+)
+
+// 保序编码big.Int，可直接用作键按数值排序：
+// 首字节区分负数、零、正数，其后是4字节大端的绝对值长度和绝对值；
+// 负数的长度和绝对值按位取反，绝对值越大排得越靠前
+func encodeBigInt(n *big.Int) ([]byte, error) {
+	if n == nil {
+		return nil, fmt.Errorf("nil big.Int")
+	}
+
+	switch n.Sign() {
+	case 0:
+		return []byte{bigIntZero}, nil
+	case 1:
+		mag := n.Bytes()
+		v := make([]byte, 5, 5+len(mag))
+		v[0] = bigIntPos
+		binary.BigEndian.PutUint32(v[1:], uint32(len(mag)))
+		return append(v, mag...), nil
+	}
+
+	mag := n.Bytes()
+	v := make([]byte, 5+len(mag))
+	v[0] = bigIntNeg
+	binary.BigEndian.PutUint32(v[1:], ^uint32(len(mag)))
+	for i, c := range mag {
+		v[5+i] = ^c
+	}
+	return v, nil
+}
+
+func decodeBigInt(v []byte) (*big.Int, error) {
+	if len(v) == 1 && v[0] == bigIntZero {
+		return new(big.Int), nil
+	}
+	if len(v) < 5 || (v[0] != bigIntNeg && v[0] != bigIntPos) {
+		return nil, fmt.Errorf("invalid big.Int encoding")
+	}
+
+	n := binary.BigEndian.Uint32(v[1:])
+	mag := append([]byte{}, v[5:]...)
+	if v[0] == bigIntNeg {
+		n = ^n
+		for i := range mag {
+			mag[i] = ^mag[i]
+		}
+	}
+	if int(n) != len(mag) {
+		return nil, fmt.Errorf("invalid big.Int encoding")
+	}
+
+	ret := new(big.Int).SetBytes(mag)
+	if v[0] == bigIntNeg {
+		ret.Neg(ret)
+	}
+	return ret, nil
+}
+
+func (b *dbConnection) GetBigInt(tn string, key interface{}) (*big.Int, error) {
+	v, err := b.lookup(tn, key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBigInt(v)
+}
+
+func (b *dbConnection) GetBigRat(tn string, key interface{}) (*big.Rat, error) {
+	v, err := b.lookup(tn, key)
+	if err != nil {
+		return nil, err
+	}
+	r := new(big.Rat)
+	if err = r.GobDecode(v); err != nil {
+		return nil, fmt.Errorf("invalid big.Rat encoding: %v", err)
+	}
+	return r, nil
+}
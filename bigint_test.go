@@ -0,0 +1,79 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+func TestBigIntOrdering(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	nums := []*big.Int{
+		new(big.Int).Neg(huge),
+		big.NewInt(-65536),
+		big.NewInt(-256),
+		big.NewInt(-255),
+		big.NewInt(-1),
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(255),
+		big.NewInt(256),
+		huge,
+	}
+
+	encoded := make([][]byte, len(nums))
+	for i, n := range nums {
+		v, err := encodeBigInt(n)
+		if err != nil {
+			t.Fatalf("encodeBigInt(%v) failed, err=%v", n, err)
+		}
+		encoded[i] = v
+
+		got, err := decodeBigInt(v)
+		if err != nil || got.Cmp(n) != 0 {
+			t.Errorf("decodeBigInt(encodeBigInt(%v)) == %v, err=%v", n, got, err)
+		}
+	}
+	if !sort.SliceIsSorted(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 }) {
+		t.Errorf("encoded big.Int not in numeric order")
+	}
+}
+
+func TestBigValues(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	n, _ := new(big.Int).SetString("-98765432109876543210987654321", 10)
+	if err := db.Set("test", "int", n); err != nil {
+		t.Fatalf("db.Set(big.Int) failed, err=%v", err)
+	}
+	got, err := db.GetBigInt("test", "int")
+	if err != nil || got.Cmp(n) != 0 {
+		t.Errorf("db.GetBigInt() == %v, err=%v, want %v", got, err, n)
+	}
+
+	r := big.NewRat(-1, 3)
+	if err := db.Set("test", "rat", r); err != nil {
+		t.Fatalf("db.Set(big.Rat) failed, err=%v", err)
+	}
+	gotRat, err := db.GetBigRat("test", "rat")
+	if err != nil || gotRat.Cmp(r) != 0 {
+		t.Errorf("db.GetBigRat() == %v, err=%v, want %v", gotRat, err, r)
+	}
+
+	// 作为键使用
+	for _, i := range []int64{5, -5, 0} {
+		db.Set("test", big.NewInt(i), i)
+	}
+	if v := string(db.Get("test", big.NewInt(-5))); v != "-5" {
+		t.Errorf("db.Get(big.Int(-5)) == %q, want %q", v, "-5")
+	}
+
+	if _, err := db.GetBigInt("test", "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.GetBigInt(missing) err=%v, want ErrKeyNotFound", err)
+	}
+	if err := db.Set("test", "nil", (*big.Int)(nil)); err == nil {
+		t.Errorf("db.Set(nil big.Int) should fail")
+	}
+}
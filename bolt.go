@@ -1,12 +1,33 @@
 package bdb
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"math/big"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
 
+var (
+	ErrEmptyKey      = errors.New("empty key")         // nil键和编码后为空的键
+	ErrKeyNotFound   = errors.New("key not found")     // 键不存在
+	ErrTableNotFound = errors.New("table not found")   // 表不存在
+	ErrClosed        = errors.New("database closed")   // 连接已关闭
+	ErrNotOpen       = errors.New("database not open") // 连接未打开
+)
+
 /*
 db对象
 */
@@ -17,67 +38,339 @@ type BoltDB interface {
 	DeleteTable(tn string) error                // 删除一张表
 	GetDBName() string                          // 获取数据库名
 
+	PageInfo() (pageSize, usedPages, freePages int, err error) // 页大小及已用、空闲页数
+	Describe() (DBInfo, error)                                 // 数据库概况
+	Count(tn string) (int, error)                              // 表中的键数
+
+	Stats() (bolt.Stats, error)                     // bolt的运行统计
+	TableStats(tn string) (bolt.BucketStats, error) // 表的页统计
+	Ping() error                                    // 健康检查
+	SizeOf(tn string) (int64, int, error)           // 估算表占用的字节数，同时返回键数
+	SetObserver(o Observer)                         // 设置操作耗时的观察者
+	SetTracer(t Tracer)                             // 设置操作的追踪
+	SetLogger(l *slog.Logger)                       // 设置内部的日志输出
+	SetQuota(tn string, q Quota)                    // 设置表的配额
+
+	InitTable(tn string, seed map[interface{}]interface{}) (bool, error) // 创建表并在表为空时写入初始数据，返回是否写入
+
+	ListTables() ([]string, error)                  // 列出所有顶层表
+	HasTable(tn string) bool                        // 表是否存在
+	RenameTable(oldName, newName string) error      // 重命名表
+	TruncateTable(tn string) error                  // 清空表，保留表和序列号
+	CopyTable(srcTn, dstTn string) (int, error)     // 把表复制到本库的另一张表
+	CopyTableTo(dst BoltDB, tn string) (int, error) // 把表复制到另一个库的同名表
+	ListCollections(tn string) ([]string, error)    // 列出表下的子表(hash、set等)
+	TableHash(tn string) ([]byte, error)            // 计算表内容的哈希，用于判断是否变化
+
+	Shard(src string, shardFunc func(k, v []byte) string) (map[string]int, error)        // 按shardFunc把表拆分到多张表，返回每张表的条数
+	TransformValues(tn string, transform func(k, v []byte) ([]byte, error)) (int, error) // 用transform改写表中所有的值，返回改动的条数
+	ExtractRange(src, dst string, start, end interface{}) (int, error)                   // 把[start, end)的记录复制到新表dst
+
+	UpdateAll(tn string, fn func(k, v []byte) (newValue interface{}, delete bool, err error)) (int, error) // 对每条记录执行fn，按返回值改写或删除
+
 	Set(tn string, key, value interface{}) error // 设置键值,key,value只支持int64,string,[]byte
 	Get(tn string, key interface{}) []byte       // 获取键值
 	Delete(tn string, key interface{}) error     // 删除键
 
+	SetBatch(tn string, kvs map[interface{}]interface{}) error         // 在一个事务中设置多个键值
+	GetMulti(tn string, keys []interface{}) (map[string][]byte, error) // 在一个事务中获取多个键值，以编码后的键为索引
+	DeleteMulti(tn string, keys ...interface{}) (int, error)           // 在一个事务中删除多个键，返回实际删除的条数
+	DeleteByPrefix(tn string, prefix []byte) (int, error)              // 删除以prefix开头的所有键，返回删除的条数
+
+	Begin(writable bool) (Txn, error)               // 开始手动提交的事务
+	Update(tn string, fn func(t Table) error) error // 在一个写事务中操作表
+	View(tn string, fn func(t Table) error) error   // 在一个只读事务中操作表
+
+	SetCodec(c Codec)                                // 设置SetObject、GetObject默认的编解码方式
+	SetTableCodec(tn string, c Codec)                // 为表单独设置编解码方式
+	SetObject(tn string, key, v interface{}) error   // 编码任意类型的值后写入
+	GetObject(tn string, key, out interface{}) error // 读出并解码到out
+	Save(tn string, v interface{}) error             // 以bdb:"key"字段为键保存结构体
+	Load(tn string, key, out interface{}) error      // 按主键读出结构体
+
+	CompareAndSwap(tn string, key, old, new interface{}) (bool, error) // 当前值等于old时写入new
+	Incr(tn, key string, delta int64) (int64, error)                   // 计数器加delta，返回新值
+	Decr(tn, key string, delta int64) (int64, error)                   // 计数器减delta，返回新值
+	Append(tn string, key, data interface{}) error                     // 把data追加到值的后面
+	GetSet(tn string, key, value interface{}) ([]byte, error)          // 写入新值并返回旧值
+	SetNX(tn string, key, value interface{}) (bool, error)             // 键不存在时才写入
+
+	SetWithTTL(tn string, key, value interface{}, ttl time.Duration) error // 设置键值，ttl后过期
+	PurgeExpired() (int, error)                                            // 立即删除所有已过期的键
+
+	OnExpire(tn string, fn EventFunc) // 注册键过期被清理时的回调
+	OnDelete(tn string, fn EventFunc) // 注册键被删除时的回调
+
+	Watch(tn string, prefix []byte) (<-chan Change, func()) // 订阅键的变化，返回的函数取消订阅
+	RegisterHook(point HookPoint, fn HookFunc)              // 注册写入、删除前后的钩子
+
+	SetAudit(mode AuditMode)                                 // 开启或关闭审计记录
+	AuditLog(after uint64, limit int) ([]AuditRecord, error) // 读取序号after之后的审计记录
+	TruncateAudit(upTo uint64) (int, error)                  // 删除序号不大于upTo的审计记录
+
+	SetCDC(enabled bool)                                         // 开启或关闭变更记录
+	StreamChanges(name string, sink ChangeSink) (func(), error)  // 在后台把变更送往sink
+	ReadChanges(after uint64, limit int) ([]ChangeRecord, error) // 读取序号after之后的变更
+	ChangeCursor(name string) (uint64, error)                    // 消费者已送达的序号
+	TruncateChanges(upTo uint64) (int, error)                    // 删除序号不大于upTo的变更
+
+	Backup(w io.Writer) (int64, error)                    // 在线备份整个数据库到w
+	BackupToFile(path string) error                       // 在线备份到文件
+	BackupTo(sink BackupSink, name string) (int64, error) // 在线备份到sink
+	Restore(r io.Reader) error                            // 用备份替换当前数据库并重新打开
+	RestoreFile(path string) error                        // 用备份文件替换当前数据库并重新打开
+
+	ScheduleBackups(s BackupSchedule) error // 在后台定时备份
+	StopBackups()                           // 停止定时备份
+	Compact(dstPath string) error           // 复制到新文件以回收空间，dstPath为空时原地压缩
+
+	CreateIndex(tn, name string, fn IndexFunc) error             // 声明二级索引并用现有数据建立
+	CreateUniqueIndex(tn, name string, fn IndexFunc) error       // 声明唯一索引
+	CreateIndexes(tn string, sample interface{}) error           // 按结构体的bdb:"index"字段声明索引
+	GetByIndex(tn, name string, value interface{}) ([]KV, error) // 按索引值查记录
+	Query(tn string) *Query                                      // 按字段查询表中的结构体
+
+	GetValue(tn string, key interface{}) ([]byte, error) // 获取键值，可区分键不存在、空值和表不存在
+	ValueSize(tn string, key interface{}) (int, error)   // 获取值的字节数，不拷贝值
+	Has(tn string, key interface{}) (bool, error)        // 键是否存在，不拷贝值
+
+	GetBigInt(tn string, key interface{}) (*big.Int, error) // 获取big.Int值
+	GetBigRat(tn string, key interface{}) (*big.Rat, error) // 获取big.Rat值
+
 	Add(tn string, value interface{}) error                  // 直接往表中添加，相当于集合
+	AddUUID(tn string, value interface{}) (string, error)    // 以随机生成的UUID为键添加，返回该UUID
 	Tarverse(tn string, tar func(k, v []byte) []byte) []byte // 遍历库表
+
+	AddWithKey(tn string, id uint64, value interface{}) error // 以外部递增id为键添加
+	DrainBatch(srcQueue, dstTable string, n int) (int, error) // 原子地把队列前n项移到另一张表
+
+	ForEachResumable(tn string, afterKey []byte, fn func(k, v []byte) error) ([]byte, error) // 从afterKey之后开始遍历，返回最后处理的键作为断点
+	ScanJSON(tn string, w io.Writer, validate bool) error                                    // 把表中的JSON值拼成数组写出
+	Match(tn string, pattern string, fn func(k, v []byte) bool) error                        // 遍历键匹配通配符的条目，fn返回false停止
+	Around(tn string, pivot interface{}, before, after int) ([]KV, []KV, error)              // 获取pivot前后的若干条
+	Scan(tn string, prefix []byte, fn func(k, v []byte) error) error                         // 遍历以prefix开头的键
+	GetRange(tn string, start, end interface{}, fn func(k, v []byte) error) error            // 遍历[start, end)内的键
+	ForEachReverse(tn string, limit int, fn func(k, v []byte) error) error                   // 从最后一个键倒序遍历最多limit条
+	Page(tn string, afterKey []byte, limit int) ([]KV, []byte, error)                        // 分页获取afterKey之后的limit条，返回下一页的起点
+	Keys(tn string, limit int) ([][]byte, error)                                             // 列出前limit个键
+	KeysWithPrefix(tn string, prefix []byte, limit int) ([][]byte, error)                    // 列出以prefix开头的前limit个键
+
+	NewIterator(tn string) (Iterator, error)              // 创建表的迭代器，用完须Close
+	All(tn string) iter.Seq2[[]byte, []byte]              // range遍历整张表
+	Prefix(tn string, p []byte) iter.Seq2[[]byte, []byte] // range遍历以p开头的键
+
+	AsMap(tn string, keyKind, valKind Kind) (map[interface{}]interface{}, error) // 按指定类型导出整张表
+
+	ExportBinary(w io.Writer) error                        // 以二进制格式导出整个库
+	ImportBinary(r io.Reader, policy ConflictPolicy) error // 导入ExportBinary的结果
+
+	SetCircuitBreaker(threshold int, cooldown time.Duration) // 连续threshold次写失败后熔断cooldown时长，threshold为0时关闭
+	CircuitStats() CircuitStats                              // 熔断器状态
+	SetMaxPendingWrites(n int)                               // 排队中的写操作超过n时直接拒绝，0表示不限
+	PendingWrites() int                                      // 当前排队及执行中的写操作数
+
+	SetMirror(other BoltDB)      // 把写操作同步到另一个库，nil表示取消
+	SetMirrorStrict(strict bool) // 严格模式下镜像写失败会使本次写失败
+
+	SetOpTimeout(d time.Duration) // 单次操作的超时时间，0表示不限时
+
+	GetCtx(ctx context.Context, tn string, key interface{}) ([]byte, error)                   // 同GetValue，开始前检查ctx
+	SetCtx(ctx context.Context, tn string, key, value interface{}) error                      // 同Set，ctx结束时不写入
+	DeleteCtx(ctx context.Context, tn string, key interface{}) error                          // 同Delete，ctx结束时不删除
+	TarverseCtx(ctx context.Context, tn string, tar func(k, v []byte) []byte) ([]byte, error) // 同Tarverse，每步之间检查ctx
+	ScanCtx(ctx context.Context, tn string, prefix []byte, fn func(k, v []byte) error) error  // 同Scan，每步之间检查ctx
+
+	AppendPoint(tn string, t time.Time, value []byte) error                                           // 追加t时刻的点
+	QueryRange(tn string, from, to time.Time) ([]Point, error)                                        // 读取[from, to)内的点
+	Downsample(tn string, from, to time.Time, step time.Duration, fn DownsampleFunc) ([]Point, error) // 按step分组聚合[from, to)内的点
+
+	SAdd(tn string, key interface{}, members ...interface{}) (int, error) // 向键key的集合加入成员，返回新加入的个数
+	SRem(tn string, key interface{}, members ...interface{}) (int, error) // 从集合中删除成员，返回删除的个数
+	SIsMember(tn string, key, member interface{}) (bool, error)           // 成员是否在集合中
+	SMembers(tn string, key interface{}) ([][]byte, error)                // 集合的所有成员
+	SCard(tn string, key interface{}) (int, error)                        // 集合的成员数
+
+	ZAdd(tn string, key, member interface{}, score float64) (bool, error)          // 加入有序集合或更新分数，新成员时返回true
+	ZRem(tn string, key, member interface{}) (bool, error)                         // 从有序集合中删除成员
+	ZScore(tn string, key, member interface{}) (float64, error)                    // 成员的分数
+	ZRank(tn string, key, member interface{}) (int, error)                         // 成员按分数从小到大的排名
+	ZRangeByScore(tn string, key interface{}, min, max float64) ([]ZMember, error) // 分数在[min, max]内的成员
+	ZCard(tn string, key interface{}) (int, error)                                 // 有序集合的成员数
+
+	LPush(tn string, key interface{}, values ...interface{}) (int, error) // 插入到列表头，返回列表长度
+	RPush(tn string, key interface{}, values ...interface{}) (int, error) // 追加到列表尾，返回列表长度
+	LPop(tn string, key interface{}) ([]byte, error)                      // 取出列表的第一个元素
+	RPop(tn string, key interface{}) ([]byte, error)                      // 取出列表的最后一个元素
+	LIndex(tn string, key interface{}, i int) ([]byte, error)             // 列表的第i个元素，负数从表尾数起
+	LRange(tn string, key interface{}, start, stop int) ([][]byte, error) // 列表中下标在[start, stop]内的元素
+	LLen(tn string, key interface{}) (int, error)                         // 列表的长度
+
+	HSet(tn string, key, field, value interface{}) error                 // 设置哈希的字段
+	HGet(tn string, key, field interface{}) ([]byte, error)              // 读取哈希的字段
+	HDel(tn string, key interface{}, fields ...interface{}) (int, error) // 删除哈希的字段，返回删除的个数
+	HGetAll(tn string, key interface{}) (map[string][]byte, error)       // 哈希的所有字段
+
+	SetWriteBuffer(interval time.Duration, size int) // 开启写缓冲，每interval或攒够size条写入一次，都为0时关闭
+	Flush() error                                    // 立即写入缓冲中的数据
+}
+
+// 键值对
+type KV struct {
+	Key   []byte
+	Value []byte
 }
 
 // 实现BoltDB接口
 type dbConnection struct {
-	name string   // 数据库名字
-	bdb  *bolt.DB // 数据库连接对象
+	name    string      // 数据库名字
+	mode    os.FileMode // 打开时的文件权限
+	opts    *Options    // 打开选项，nil为默认值
+	bdb     *bolt.DB    // 数据库连接对象
+	breaker breaker     // 写熔断器
+	mirror  mirror      // 写镜像
+	buffer  writeBuffer // 写缓冲
+	indexes indexes     // 二级索引
+	sweeper sweeper     // 后台过期清理
+	events  events      // 删除、过期的回调
+	watches watchers    // 变化的订阅
+	hooks   hooks       // 写入前后的钩子
+	auditor auditor     // 审计记录
+	cdc     cdc         // 变更记录
+	backups backups     // 定时备份
+	codecs  codecs      // 值的编解码方式
+	metrics observer    // 操作耗时的观察者
+	logger  logger      // 结构化日志
+	quotas  quotas      // 表的配额
+	tmpdir  string      // OpenMemory的临时目录，Close时删除
+
+	lifecycle sync.RWMutex // 操作持有读锁，Close持有写锁
+	closed    bool
+	handles   handles // 打开中的迭代器和事务
+
+	opTimeout     atomic.Int64 // 单次操作超时，time.Duration
+	maxPending    atomic.Int64 // 最多排队的写操作数
+	pendingWrites atomic.Int64 // 排队及执行中的写操作数
 }
 
 // 打开一个数据库对象
-func Open(db string, mode os.FileMode) BoltDB {
+// 打开失败时返回错误，不返回未初始化的连接
+func Open(db string, mode os.FileMode) (BoltDB, error) {
 	bdb := &dbConnection{name: db}
-	bdb.Open(db, mode)
-	return bdb
+	if err := bdb.Open(db, mode); err != nil {
+		return nil, err
+	}
+	return bdb, nil
 }
 
 func (b *dbConnection) Open(dbname string, mode os.FileMode) error {
-	db, err := bolt.Open(dbname, mode, nil)
+	return b.open(context.Background(), dbname, mode)
+}
+
+func (b *dbConnection) open(ctx context.Context, dbname string, mode os.FileMode) error {
+	db, err := b.openBoltCtx(ctx, dbname, mode)
 	if err != nil {
 		return err
 	}
+
+	b.stopSweeper()
+	b.lockExclusive(false)
+	if b.bdb != nil && !b.closed {
+		b.bdb.Close()
+	}
 	b.bdb = db
+	b.name = dbname
+	b.mode = mode
+	b.closed = false
+	b.unlockExclusive()
+	b.startSweeper()
 	return nil
 }
 
+// 按打开选项打开bolt数据库
+func (b *dbConnection) openBolt(dbname string, mode os.FileMode) (*bolt.DB, error) {
+	return b.openBoltCtx(context.Background(), dbname, mode)
+}
+
+// 等待文件锁时bolt无法被打断，ctx可以结束时改为每次只等lockPoll，之间检查ctx
+func (b *dbConnection) openBoltCtx(ctx context.Context, dbname string, mode os.FileMode) (*bolt.DB, error) {
+	opts := b.opts.boltOptions()
+	var db *bolt.DB
+	var err error
+	if ctx.Done() == nil {
+		db, err = bolt.Open(dbname, mode, opts)
+	} else {
+		db, err = openPolling(ctx, dbname, mode, opts)
+	}
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("open %v failed: %w", dbname, ErrLocked)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.opts != nil {
+		db.NoSync = b.opts.NoSync
+		if b.opts.MaxBatchSize > 0 {
+			db.MaxBatchSize = b.opts.MaxBatchSize
+		}
+		if b.opts.MaxBatchDelay > 0 {
+			db.MaxBatchDelay = b.opts.MaxBatchDelay
+		}
+	}
+	return db, nil
+}
+
+// 等待进行中的操作结束后关闭，之后的操作返回ErrClosed。关闭前会写入缓冲中的数据
 func (b *dbConnection) Close() {
+	b.stopFlusher()
+	b.stopSweeper()
+	b.stopStreams()
+	b.StopBackups()
+	b.lockExclusive(true)
+	defer b.unlockExclusive()
+	if b.closed {
+		return
+	}
+	if b.bdb != nil {
+		if err := b.flushBuffer(); err != nil {
+			b.log().Error("bdb: flush write buffer on close failed", "db", b.name, "err", err)
+		}
+	}
+	b.closed = true
 	if b.bdb != nil {
 		b.bdb.Close()
 	}
+	if b.tmpdir != "" {
+		os.RemoveAll(b.tmpdir)
+	}
+	b.unwatchAll()
 }
 
 func (b *dbConnection) CreateTable(tn string) error {
-	if b.bdb == nil {
-		return fmt.Errorf("invalid boltdb connection")
-	}
-
-	return b.bdb.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(tn))
+	return b.update(func(tx *bolt.Tx) error {
+		_, err := createBucket(tx, tn)
 		if err != nil {
 			return fmt.Errorf("create bucket (%v) failed: %s", tn, err)
 		}
-		return nil
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.CreateTable(tn) })
 	})
 }
 
 func (b *dbConnection) DeleteTable(tn string) error {
-	if b.bdb == nil {
-		return fmt.Errorf("invalid boltdb connection")
-	}
-
-	return b.bdb.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(tn))
+	return b.update(func(tx *bolt.Tx) error {
+		parent, name, err := parentOf(tx, tn, false)
+		if err == nil {
+			err = parent.DeleteBucket(name)
+		}
+		if err == nil {
+			err = dropIndexes(tx, tn)
+		}
+		if err == nil {
+			err = dropExpiry(tx, tn)
+		}
 		if err != nil {
 			return fmt.Errorf("delete bucket (%v) failed: %s", tn, err)
 		}
-		return nil
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.DeleteTable(tn) })
 	})
 }
 
@@ -85,38 +378,69 @@ func (b *dbConnection) GetDBName() string {
 	return b.name
 }
 
-func (b *dbConnection) Set(tn string, key, value interface{}) (ret error) {
-	b.bdb.Update(func(tx *bolt.Tx) error {
-		k, err := dataToBytes(key)
-		if err != nil {
-			ret = fmt.Errorf("invalid key:%v", err)
+func (b *dbConnection) Set(tn string, key, value interface{}) error {
+	return b.SetCtx(context.Background(), tn, key, value)
+}
+
+// 等待写锁期间ctx结束时不写入，返回ctx结束的原因
+func (b *dbConnection) SetCtx(ctx context.Context, tn string, key, value interface{}) (err error) {
+	defer b.observeOp(ctx, "set", tn, time.Now(), key, value, &err)
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	v, err := dataToBytes(value)
+	if err != nil {
+		return fmt.Errorf("invalid value:%v", err)
+	}
+	if err = debugCheck(key, value, k, v); err != nil {
+		return err
+	}
+	if err = ctxErr(ctx); err != nil {
+		return err
+	}
+	if ok, err := b.buffered(bufferedOp{tn: tn, key: k, value: v}); ok {
+		return err
+	}
+
+	return b.batchUpdateCtx(ctx, func(tx *bolt.Tx) error {
+		if err := ctxErr(ctx); err != nil {
 			return err
 		}
-		v, err := dataToBytes(value)
+		bucket, err := b.writeBucket(tx, tn)
 		if err != nil {
-			ret = fmt.Errorf("invalid value:%v", err)
 			return err
 		}
-
-		bucket := tx.Bucket([]byte(tn))
-		err = bucket.Put(k, v)
+		stored, err := b.putValue(tx, bucket, tn, k, v)
 		if err != nil {
-			ret = fmt.Errorf("set %v.%v failed: %v\n", tn, k, err)
+			return fmt.Errorf("set %v.%v failed: %w\n", tn, k, err)
 		}
-		return err
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
 	})
-	return ret
 }
 
 func (b *dbConnection) Get(tn string, key interface{}) (ret []byte) {
-	b.bdb.Update(func(tx *bolt.Tx) error {
-		k, err := dataToBytes(key)
+	defer b.observeOp(context.Background(), "get", tn, time.Now(), key, nil, nil)
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil
+	}
+	if b.acquire() != nil {
+		return nil
+	}
+	defer b.release()
+	// 先查写缓冲
+	if v, ok := b.buffer.get(tn, k); ok {
+		return v
+	}
+
+	// 只读事务，读之间以及读与写之间互不阻塞
+	err = b.bdb.View(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
 		if err != nil {
 			return err
 		}
-
-		bucket := tx.Bucket([]byte(tn))
-		v := bucket.Get(k)
+		v := liveValue(tx, bucket, tn, k)
 		// do make space before copy
 		if len(v) > 0 {
 			ret = make([]byte, len(v))
@@ -124,65 +448,617 @@ func (b *dbConnection) Get(tn string, key interface{}) (ret []byte) {
 		}
 		return nil
 	})
+	if err != nil {
+		b.log().Debug("bdb: get failed", "table", tn, "key", k, "err", err)
+	}
 	return ret
 }
 
-func (b *dbConnection) Delete(tn string, key interface{}) (ret error) {
-	b.bdb.Update(func(tx *bolt.Tx) error {
-		k, err := dataToBytes(key)
+// 与Get相同，但返回错误：键不存在时为ErrKeyNotFound，表不存在时为ErrTableNotFound，
+// 空值返回长度为0的非nil切片
+func (b *dbConnection) GetValue(tn string, key interface{}) ([]byte, error) {
+	return b.GetCtx(context.Background(), tn, key)
+}
+
+// 单次读无法中途打断，只在开始前检查ctx
+func (b *dbConnection) GetCtx(ctx context.Context, tn string, key interface{}) (v []byte, err error) {
+	defer b.observeOp(ctx, "get", tn, time.Now(), key, nil, &err)
+	if err := ctxErr(ctx); err != nil {
+		return nil, err
+	}
+	return b.lookup(tn, key)
+}
+
+// 只判断存在与否，不分配也不拷贝值；子表不算作键
+func (b *dbConnection) Has(tn string, key interface{}) (ok bool, err error) {
+	defer b.observeOp(context.Background(), "has", tn, time.Now(), key, nil, &err)
+	k, err := keyToBytes(key)
+	if err != nil {
+		return false, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
 		if err != nil {
-			ret = fmt.Errorf("invalid key:%v", err)
 			return err
 		}
+		ok = liveValue(tx, bucket, tn, k) != nil
+		return nil
+	})
+	return ok, err
+}
+
+// 直接取mmap中值的长度，用于在拉取大值之前先判断大小
+func (b *dbConnection) ValueSize(tn string, key interface{}) (size int, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
 
-		bucket := tx.Bucket([]byte(tn))
-		bucket.Delete(k)
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		v := liveValue(tx, bucket, tn, k)
+		if v == nil {
+			return ErrKeyNotFound
+		}
+		size = len(v)
 		return nil
 	})
-	return ret
+	return size, err
+}
+
+func (b *dbConnection) Delete(tn string, key interface{}) error {
+	return b.DeleteCtx(context.Background(), tn, key)
+}
+
+// 等待写锁期间ctx结束时不删除，返回ctx结束的原因
+func (b *dbConnection) DeleteCtx(ctx context.Context, tn string, key interface{}) (err error) {
+	defer b.observeOp(ctx, "delete", tn, time.Now(), key, nil, &err)
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	if err = ctxErr(ctx); err != nil {
+		return err
+	}
+	if ok, err := b.buffered(bufferedOp{tn: tn, key: k, del: true}); ok {
+		return err
+	}
+
+	return b.batchUpdateCtx(ctx, func(tx *bolt.Tx) error {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		if err = b.del(tx, bucket, tn, k); err != nil {
+			return fmt.Errorf("delete %v.%v failed: %w", tn, k, err)
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Delete(tn, k) })
+	})
+}
+
+func (b *dbConnection) Add(tn string, value interface{}) error {
+	v, err := dataToBytes(value)
+	if err != nil {
+		return fmt.Errorf("invalid value:%v", err)
+	}
+	if err = debugCheck(nil, value, nil, v); err != nil {
+		return err
+	}
+	if ok, err := b.buffered(bufferedOp{tn: tn, value: v}); ok {
+		return err
+	}
+
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		k, stored, err := b.addToBucket(tx, bucket, tn, v)
+		if err != nil {
+			return err
+		}
+		// 镜像库使用相同的键，保证两边id一致
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
+	})
 }
 
-func (b *dbConnection) Add(tn string, value interface{}) (ret error) {
-	b.bdb.Update(func(tx *bolt.Tx) error {
+// 分配下一个序列号作为键写入，返回该键和经过钩子后实际写入的值
+func (b *dbConnection) addToBucket(tx *bolt.Tx, bucket *bolt.Bucket, tn string, v []byte) (k, stored []byte, err error) {
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return nil, nil, fmt.Errorf("next sequence error:%v", err)
+	}
+
+	k, err = dataToBytes(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key:%v", err)
+	}
+
+	stored, err = b.putValue(tx, bucket, tn, k, v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("set %v.%v failed: %w\n", tn, k, err)
+	}
+	return k, stored, nil
+}
+
+// 键为id的8字节大端编码，按数值排序，与Queue和DrainBatch的键相同，与Add的十进制键不同；
+// 同时把表的序列号推进到不小于id，这样之后的Add不会再分配到已经用过的id
+func (b *dbConnection) AddWithKey(tn string, id uint64, value interface{}) (ret error) {
+	err := b.update(func(tx *bolt.Tx) error {
 		v, err := dataToBytes(value)
 		if err != nil {
 			ret = fmt.Errorf("invalid value:%v", err)
 			return err
 		}
+		if err = debugCheck(nil, value, nil, v); err != nil {
+			ret = err
+			return err
+		}
 
-		bucket := tx.Bucket([]byte(tn))
-		id, err := bucket.NextSequence()
+		bucket, err := b.writeBucket(tx, tn)
 		if err != nil {
-			ret = fmt.Errorf("next sequence error:%v", err)
+			ret = err
 			return err
 		}
 
-		k, err := dataToBytes(id)
+		if id > bucket.Sequence() {
+			if err = bucket.SetSequence(id); err != nil {
+				ret = fmt.Errorf("set sequence error:%v", err)
+				return err
+			}
+		}
+
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, id)
+		stored, err := b.putValue(tx, bucket, tn, k, v)
 		if err != nil {
-			ret = fmt.Errorf("invalid key:%v", err)
+			ret = fmt.Errorf("set %v.%v failed: %w", tn, id, err)
 			return err
 		}
-
-		err = bucket.Put(k, v)
+		err = b.mirrorWrite(tx, func(m BoltDB) error { return m.AddWithKey(tn, id, stored) })
 		if err != nil {
-			ret = fmt.Errorf("set %v.%v failed: %v\n", tn, k, err)
+			ret = err
 		}
 		return err
 	})
+	if ret == nil {
+		ret = err
+	}
 	return ret
 }
 
 func (b *dbConnection) Tarverse(tn string, tar func(k, v []byte) []byte) []byte {
-	var ret string
-	b.bdb.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(tn))
+	ret, _ := b.TarverseCtx(context.Background(), tn, tar)
+	return ret
+}
+
+// ctx结束或超时时停止，返回已遍历部分的结果和原因；表不存在时返回错误
+func (b *dbConnection) TarverseCtx(ctx context.Context, tn string, tar func(k, v []byte) []byte) ([]byte, error) {
+	if err := b.acquire(); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	b.flushBeforeRead()
+
+	var ret bytes.Buffer
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+	err := b.bdb.View(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
 		c := bucket.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
-			ret = ret + string(tar(k, v)) + " "
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			ret.Write(tar(k, v))
+			ret.WriteByte(' ')
+		}
+		return nil
+	})
+	return ret.Bytes(), err
+}
+
+// 写事务，所有写操作都经由这里
+func (b *dbConnection) update(fn func(tx *bolt.Tx) error) error {
+	return b.commit(context.Background(), fn, false)
+}
+
+// 开启Options.Batch时合并提交的写事务，fn可能被执行多次，必须是幂等的
+func (b *dbConnection) batchUpdate(fn func(tx *bolt.Tx) error) error {
+	return b.batchUpdateCtx(context.Background(), fn)
+}
+
+// 同batchUpdate，ctx只用于追踪写事务
+func (b *dbConnection) batchUpdateCtx(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	return b.commit(ctx, fn, b.opts != nil && b.opts.Batch)
+}
+
+// 提交后在释放lifecycle锁之后调用After钩子
+func (b *dbConnection) commit(ctx context.Context, fn func(tx *bolt.Tx) error, batch bool) error {
+	txs, err := b.commitLocked(ctx, fn, batch)
+	b.runAfter(txs...)
+	return err
+}
+
+func (b *dbConnection) commitLocked(ctx context.Context, fn func(tx *bolt.Tx) error, batch bool) ([]*bolt.Tx, error) {
+	if err := b.acquire(); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	if b.readOnly() {
+		return nil, ErrReadOnly
+	}
+	if err := b.flushBuffer(); err != nil {
+		return nil, err
+	}
+	return b.runWrite(ctx, fn, batch)
+}
+
+// 在写事务中执行fn，计入积压的写、熔断和提交的观察。调用方须持有lifecycle锁，
+// 写缓冲的写入也经由这里
+func (b *dbConnection) runWrite(ctx context.Context, fn func(tx *bolt.Tx) error, batch bool) ([]*bolt.Tx, error) {
+	if err := b.acquireWrite(); err != nil {
+		return nil, err
+	}
+	defer b.pendingWrites.Add(-1)
+	if err := b.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	run := b.bdb.Update
+	if batch {
+		run = b.bdb.Batch
+	}
+	var fnErr error
+	var txs []*bolt.Tx
+	start := time.Now()
+	err := run(func(tx *bolt.Tx) error {
+		txs = append(txs, tx)
+		if fnErr = fn(tx); fnErr == nil {
+			fnErr = b.applyMirror(tx)
+		}
+		return fnErr
+	})
+	for _, tx := range txs {
+		b.txDone(tx)
+	}
+	b.observeCommit(ctx, start, err)
+	b.breaker.done(err, fnErr)
+	return txs, err
+}
+
+// 写事务提交或回滚后丢弃按事务记录的配额用量、待触发的回调和未写入的镜像写
+func (b *dbConnection) txDone(tx *bolt.Tx) {
+	b.quotas.forget(tx)
+	b.events.forget(tx)
+	b.mirror.forget(tx)
+}
+
+// 在只读事务中获取值的拷贝，键不存在时返回ErrKeyNotFound
+func (b *dbConnection) lookup(tn string, key interface{}) (ret []byte, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
 		}
+		v := liveValue(tx, bucket, tn, k)
+		if v == nil {
+			return ErrKeyNotFound
+		}
+		ret = append([]byte{}, v...)
 		return nil
 	})
-	return []byte(ret)
+	return ret, err
+}
+
+// 只读事务
+func (b *dbConnection) view(fn func(tx *bolt.Tx) error) error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	b.flushBeforeRead()
+	return b.bdb.View(fn)
+}
+
+// 操作开始前持有读锁，保证执行期间不会被Close，成功后须调用release
+func (b *dbConnection) acquire() error {
+	b.lifecycle.RLock()
+	if b.closed {
+		b.lifecycle.RUnlock()
+		return ErrClosed
+	}
+	if b.bdb == nil {
+		b.lifecycle.RUnlock()
+		return ErrNotOpen
+	}
+	return nil
+}
+
+func (b *dbConnection) release() {
+	b.lifecycle.RUnlock()
+}
+
+// 迭代器、事务等会执行调用方代码的长期占用不持有lifecycle读锁，否则调用方在其中再调用db时
+// 会与等待写锁的Close互相等待。它们只计数，Close等排它操作先等计数归零再取写锁
+type handles struct {
+	mu      sync.Mutex
+	changed chan struct{} // 计数或等待者变化时关闭并换新
+	n       int           // 打开中的个数
+	waiting int           // 等待计数归零的排它操作数
+	closing bool          // Close正在等待，新的占用返回ErrClosed
+}
+
+// 打开迭代器、事务前调用，成功后须调用releaseHandle。有排它操作在等待且没有打开中的占用时等它完成；
+// 已有打开中的占用时不等，调用方可能正在其中嵌套打开
+func (b *dbConnection) acquireHandle() error {
+	h := &b.handles
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for h.waiting > 0 && h.n == 0 && !h.closing {
+		h.wait()
+	}
+	if h.closing {
+		return ErrClosed
+	}
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	b.release()
+	h.n++
+	return nil
+}
+
+func (b *dbConnection) releaseHandle() {
+	h := &b.handles
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.n--
+	if h.n == 0 {
+		h.broadcast()
+	}
+}
+
+// 等待打开中的迭代器和事务结束后取lifecycle写锁，须调用unlockExclusive释放。
+// closing为true时期间新的占用返回ErrClosed
+func (b *dbConnection) lockExclusive(closing bool) {
+	h := &b.handles
+	h.mu.Lock()
+	h.waiting++
+	if closing {
+		h.closing = true
+	}
+	for h.n > 0 {
+		h.wait()
+	}
+	h.mu.Unlock()
+	b.lifecycle.Lock()
+}
+
+func (b *dbConnection) unlockExclusive() {
+	b.lifecycle.Unlock()
+	h := &b.handles
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.waiting--
+	if h.waiting == 0 {
+		h.closing = false
+	}
+	h.broadcast()
+}
+
+// 调用方持有mu，返回时仍持有
+func (h *handles) wait() {
+	if h.changed == nil {
+		h.changed = make(chan struct{})
+	}
+	ch := h.changed
+	h.mu.Unlock()
+	<-ch
+	h.mu.Lock()
+}
+
+func (h *handles) broadcast() {
+	if h.changed != nil {
+		close(h.changed)
+		h.changed = nil
+	}
+}
+
+// 表名中的路径分隔符，"users/settings"表示users表下的子表settings。
+// 因此顶层表名中不能再包含分隔符
+const TableSeparator = "/"
+
+// *bolt.Tx和*bolt.Bucket共有的子表操作
+type bucketParent interface {
+	Bucket(name []byte) *bolt.Bucket
+	CreateBucket(name []byte) (*bolt.Bucket, error)
+	CreateBucketIfNotExists(name []byte) (*bolt.Bucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// 按路径找到tn的上一级和tn的最后一段名字，顶层表的上一级是tx本身。
+// create为true时逐级创建不存在的上级表
+func parentOf(tx *bolt.Tx, tn string, create bool) (bucketParent, []byte, error) {
+	names := strings.Split(tn, TableSeparator)
+	for _, name := range names {
+		if name == "" && len(names) > 1 {
+			return nil, nil, fmt.Errorf("invalid table name (%v)", tn)
+		}
+	}
+
+	var parent bucketParent = tx
+	for _, name := range names[:len(names)-1] {
+		var bucket *bolt.Bucket
+		if create {
+			var err error
+			if bucket, err = parent.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return nil, nil, err
+			}
+		} else if bucket = parent.Bucket([]byte(name)); bucket == nil {
+			return nil, nil, fmt.Errorf("table (%v) not exists:%w", tn, ErrTableNotFound)
+		}
+		parent = bucket
+	}
+	return parent, []byte(names[len(names)-1]), nil
+}
+
+// 获取表，表不存在时返回错误
+func getBucket(tx *bolt.Tx, tn string) (*bolt.Bucket, error) {
+	parent, name, err := parentOf(tx, tn, false)
+	if err != nil {
+		return nil, err
+	}
+	bucket := parent.Bucket(name)
+	if bucket == nil {
+		return nil, fmt.Errorf("table (%v) not exists:%w", tn, ErrTableNotFound)
+	}
+	return bucket, nil
+}
+
+// 获取表，不存在时连同上级表一起创建
+func createBucket(tx *bolt.Tx, tn string) (*bolt.Bucket, error) {
+	parent, name, err := parentOf(tx, tn, true)
+	if err != nil {
+		return nil, err
+	}
+	return parent.CreateBucketIfNotExists(name)
+}
+
+// 同putValue，并把实际写入的值写到镜像，批量改写表的方法经由这里
+func (b *dbConnection) putMirrored(tx *bolt.Tx, bucket *bolt.Bucket, tn string, k, v []byte) error {
+	stored, err := b.putValue(tx, bucket, tn, k, v)
+	if err != nil {
+		return err
+	}
+	return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
+}
+
+// 写入一条记录，单条的写入都经由这里，以便同步维护索引。
+// 返回经过BeforeSet钩子后实际写入的值，镜像应写入这个值
+func (b *dbConnection) putValue(tx *bolt.Tx, bucket *bolt.Bucket, tn string, k, v []byte) ([]byte, error) {
+	v, err := b.before(BeforeSet, tn, k, v)
+	if err != nil {
+		return nil, err
+	}
+	_, limited := b.quotas.of(tn)
+	var old []byte
+	if len(b.indexes.of(tn)) > 0 || b.auditMode() == AuditWithOldValue || limited {
+		if old = bucket.Get(k); old != nil {
+			old = append([]byte{}, old...)
+		}
+	}
+	if err := b.checkQuota(bucket, tn, k, old, v); err != nil {
+		return nil, err
+	}
+	if len(b.indexes.of(tn)) > 0 {
+		if err := b.updateIndexes(tx, tn, k, old, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := clearExpiry(tx, tn, k); err != nil {
+		return nil, err
+	}
+	if err := bucket.Put(k, v); err != nil {
+		return nil, err
+	}
+	if err := b.audit(tx, "set", tn, k, old); err != nil {
+		return nil, err
+	}
+	if err := b.capture(tx, OpPut, tn, k, v); err != nil {
+		return nil, err
+	}
+	b.notify(tx, OpPut, tn, k, v)
+	b.after(tx, AfterSet, tn, k, v)
+	return v, nil
+}
+
+// 删除一条记录，同putValue
+func (b *dbConnection) del(tx *bolt.Tx, bucket *bolt.Bucket, tn string, k []byte) error {
+	return b.remove(tx, bucket, tn, k, eventDelete)
+}
+
+// 删除一条记录并按kind触发回调
+func (b *dbConnection) remove(tx *bolt.Tx, bucket *bolt.Bucket, tn string, k []byte, kind eventKind) error {
+	exists := bucket.Get(k) != nil
+	if kind == eventDelete && exists {
+		if _, err := b.before(BeforeDelete, tn, k, nil); err != nil {
+			return err
+		}
+	}
+	_, limited := b.quotas.of(tn)
+	var old []byte
+	if len(b.indexes.of(tn)) > 0 || len(b.events.of(kind, tn)) > 0 || len(b.watches.match(tn, k)) > 0 || b.auditMode() == AuditWithOldValue || limited {
+		if old = bucket.Get(k); old != nil {
+			old = append([]byte{}, old...)
+			if err := b.updateIndexes(tx, tn, k, old, nil); err != nil {
+				return err
+			}
+			b.fire(tx, kind, tn, k, old)
+			b.notify(tx, OpDelete, tn, k, old)
+			b.releaseQuota(bucket, tn, k, old)
+		}
+	}
+	if err := clearExpiry(tx, tn, k); err != nil {
+		return err
+	}
+	if err := bucket.Delete(k); err != nil {
+		return err
+	}
+	if err := b.capture(tx, OpDelete, tn, k, nil); err != nil {
+		return err
+	}
+	if kind == eventDelete {
+		if err := b.audit(tx, "delete", tn, k, old); err != nil {
+			return err
+		}
+		if exists {
+			b.after(tx, AfterDelete, tn, k, nil)
+		}
+	}
+	return nil
+}
+
+// 获取要写入的表，开启AutoCreateTables时表不存在则创建
+func (b *dbConnection) writeBucket(tx *bolt.Tx, tn string) (*bolt.Bucket, error) {
+	if b.opts == nil || !b.opts.AutoCreateTables {
+		return getBucket(tx, tn)
+	}
+	bucket, err := createBucket(tx, tn)
+	if err != nil {
+		return nil, fmt.Errorf("create table (%v) failed:%v", tn, err)
+	}
+	return bucket, nil
+}
+
+// 键的编码，nil和空键统一返回ErrEmptyKey
+func keyToBytes(key interface{}) ([]byte, error) {
+	if key == nil {
+		return nil, ErrEmptyKey
+	}
+	k, err := dataToBytes(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(k) == 0 {
+		return nil, ErrEmptyKey
+	}
+	return k, nil
 }
 
 // 处理支持的key，value类型
@@ -196,6 +1072,15 @@ func dataToBytes(data interface{}) (v []byte, err error) {
 		v = []byte(fmt.Sprintf("%d", val))
 	case float64, float32:
 		v = []byte(fmt.Sprintf("%f", val))
+	case bool:
+		v = []byte(strconv.FormatBool(val))
+	case *big.Int:
+		v, err = encodeBigInt(val)
+	case *big.Rat:
+		if val == nil {
+			return nil, fmt.Errorf("nil big.Rat")
+		}
+		v, err = val.GobEncode()
 	case fmt.Stringer:
 		v = []byte(val.String())
 	default:
@@ -2,8 +2,14 @@ package bdb
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestMyBoltDB(t *testing.T) {
@@ -25,7 +31,10 @@ func TestMyBoltDB(t *testing.T) {
 		{"string-key-string", "string key value"},
 	}
 
-	db := Open(dbname, 0600)
+	db, err := Open(dbname, 0600)
+	if err != nil {
+		t.Fatalf("Open(%q) failed, err=%v", dbname, err)
+	}
 	if db.GetDBName() != dbname {
 		t.Errorf("db.GetDBName() failed, want=%q, got=%v", "testmybolt.db", db.GetDBName())
 	}
@@ -33,7 +42,7 @@ func TestMyBoltDB(t *testing.T) {
 	defer db.Close()
 
 	tn := "test"
-	err := db.CreateTable(tn)
+	err = db.CreateTable(tn)
 	if err != nil {
 		t.Errorf("db.Create(%q) failed, err=%v", "test", err)
 	}
@@ -69,3 +78,310 @@ func TestMyBoltDB(t *testing.T) {
 		}
 	}
 }
+
+// 在临时目录中打开一个数据库，并创建给定的表
+func openTestDB(t testing.TB, tables ...string) BoltDB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), 0600)
+	if err != nil {
+		t.Fatalf("Open() failed, err=%v", err)
+	}
+	t.Cleanup(db.Close)
+	for _, tn := range tables {
+		if err := db.CreateTable(tn); err != nil {
+			t.Fatalf("db.CreateTable(%q) failed, err=%v", tn, err)
+		}
+	}
+	return db
+}
+
+func TestAddWithKey(t *testing.T) {
+	db := openTestDB(t, "events")
+
+	for _, id := range []uint64{100, 7, 300} {
+		if err := db.AddWithKey("events", id, id*2); err != nil {
+			t.Fatalf("db.AddWithKey(%d) failed, err=%v", id, err)
+		}
+	}
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, 7)
+	if got := string(db.Get("events", k)); got != "14" {
+		t.Errorf("db.Get(7) == %q, want %q", got, "14")
+	}
+
+	// 大端编码按数值排序
+	var ids []uint64
+	db.Tarverse("events", func(k, v []byte) []byte {
+		if len(k) == 8 {
+			ids = append(ids, binary.BigEndian.Uint64(k))
+		}
+		return nil
+	})
+	if len(ids) != 3 || ids[0] != 7 || ids[1] != 100 || ids[2] != 300 {
+		t.Errorf("traverse order == %v, want [7 100 300]", ids)
+	}
+
+	// 序列号已推进到300，Add分配301
+	if err := db.Add("events", "next"); err != nil {
+		t.Fatalf("db.Add() failed, err=%v", err)
+	}
+	if got := string(db.Get("events", 301)); got != "next" {
+		t.Errorf("db.Get(301) == %q, want %q", got, "next")
+	}
+}
+
+func TestEmptyKey(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	for _, key := range []interface{}{nil, "", []byte{}} {
+		if err := db.Set("test", key, "v"); !errors.Is(err, ErrEmptyKey) {
+			t.Errorf("db.Set(%#v) err=%v, want ErrEmptyKey", key, err)
+		}
+		if err := db.Delete("test", key); !errors.Is(err, ErrEmptyKey) {
+			t.Errorf("db.Delete(%#v) err=%v, want ErrEmptyKey", key, err)
+		}
+		if got := db.Get("test", key); got != nil {
+			t.Errorf("db.Get(%#v) == %q, want nil", key, got)
+		}
+	}
+}
+
+func TestValueSize(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "big", bytes.Repeat([]byte("x"), 1<<16))
+	db.Set("test", "empty", "")
+
+	if n, err := db.ValueSize("test", "big"); err != nil || n != 1<<16 {
+		t.Errorf("db.ValueSize(big) == %d, err=%v, want %d", n, err, 1<<16)
+	}
+	if n, err := db.ValueSize("test", "empty"); err != nil || n != 0 {
+		t.Errorf("db.ValueSize(empty) == %d, err=%v, want 0", n, err)
+	}
+	if _, err := db.ValueSize("test", "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.ValueSize(missing) err=%v, want ErrKeyNotFound", err)
+	}
+	if _, err := db.ValueSize("test", ""); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("db.ValueSize(\"\") err=%v, want ErrEmptyKey", err)
+	}
+}
+
+func TestCloseWhileInFlight(t *testing.T) {
+	db := openTestDB(t, "test")
+	for i := 0; i < 100; i++ {
+		db.Set("test", i, i)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				err := db.Set("test", g*1000+i%100, i)
+				if err != nil && err != ErrClosed {
+					t.Errorf("db.Set() err=%v, want nil or ErrClosed", err)
+					return
+				}
+				db.Get("test", i%100)
+				db.Tarverse("test", func(k, v []byte) []byte { return nil })
+				if _, err := db.TableHash("test"); err != nil && err != ErrClosed {
+					t.Errorf("db.TableHash() err=%v, want nil or ErrClosed", err)
+					return
+				}
+			}
+		}(g)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	db.Close()
+	if err := db.Set("test", "k", "v"); err != ErrClosed {
+		t.Errorf("db.Set() after Close err=%v, want ErrClosed", err)
+	}
+	if got := db.Get("test", 1); got != nil {
+		t.Errorf("db.Get() after Close == %q, want nil", got)
+	}
+	close(stop)
+	wg.Wait()
+	db.Close()
+}
+
+func benchDB(b *testing.B, n int) BoltDB {
+	db := openTestDB(b, "bench")
+	for i := 0; i < n; i++ {
+		db.Set("bench", i, "value-"+strconv.Itoa(i))
+	}
+	return db
+}
+
+func BenchmarkGet(b *testing.B) {
+	db := benchDB(b, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Get("bench", i%1000)
+	}
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	db := benchDB(b, 1000)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			db.Get("bench", i%1000)
+		}
+	})
+}
+
+// 后台持续写入时的并发读，读事务不应排在写锁之后
+func BenchmarkGetWithWriter(b *testing.B) {
+	db := benchDB(b, 1000)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				db.Set("bench", i%1000, "updated")
+			}
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			db.Get("bench", i%1000)
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+}
+
+func BenchmarkTarverse(b *testing.B) {
+	db := benchDB(b, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Tarverse("bench", func(k, v []byte) []byte { return v })
+	}
+}
+
+func TestGetValue(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "k", "v")
+	db.Set("test", "empty", "")
+
+	if v, err := db.GetValue("test", "k"); err != nil || string(v) != "v" {
+		t.Errorf("db.GetValue(k) == %q, %v, want \"v\", nil", v, err)
+	}
+	if v, err := db.GetValue("test", "empty"); err != nil || v == nil || len(v) != 0 {
+		t.Errorf("db.GetValue(empty) == %#v, %v, want []byte{}, nil", v, err)
+	}
+	if _, err := db.GetValue("test", "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.GetValue(missing) err=%v, want ErrKeyNotFound", err)
+	}
+	if _, err := db.GetValue("missing", "k"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.GetValue(missing table) err=%v, want ErrTableNotFound", err)
+	}
+	if _, err := db.GetValue("test", ""); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("db.GetValue(\"\") err=%v, want ErrEmptyKey", err)
+	}
+}
+
+func TestOpenError(t *testing.T) {
+	dir := t.TempDir()
+	if db, err := Open(filepath.Join(dir, "missing", "test.db"), 0600); err == nil || db != nil {
+		t.Errorf("Open(missing dir) == %v, %v, want nil, error", db, err)
+	}
+
+	// 未打开的连接上的操作返回错误而不是panic
+	var db BoltDB = &dbConnection{}
+	if err := db.CreateTable("test"); !errors.Is(err, ErrNotOpen) {
+		t.Errorf("db.CreateTable() on unopened err=%v, want ErrNotOpen", err)
+	}
+	if _, err := db.GetValue("test", "k"); !errors.Is(err, ErrNotOpen) {
+		t.Errorf("db.GetValue() on unopened err=%v, want ErrNotOpen", err)
+	}
+	if v := db.Get("test", "k"); v != nil {
+		t.Errorf("db.Get() on unopened == %v, want nil", v)
+	}
+	db.Close()
+}
+
+func TestHas(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "k", "v")
+	db.Set("test", "empty", "")
+
+	var tests = []struct {
+		key  interface{}
+		want bool
+	}{
+		{"k", true},
+		{"empty", true},
+		{"missing", false},
+	}
+	for _, test := range tests {
+		if got, err := db.Has("test", test.key); err != nil || got != test.want {
+			t.Errorf("db.Has(%v) == %v, %v, want %v", test.key, got, err, test.want)
+		}
+	}
+	if _, err := db.Has("missing", "k"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.Has(missing table) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestCtxVariants(t *testing.T) {
+	db := openTestDB(t, "test")
+	for i := 0; i < 10; i++ {
+		db.Set("test", i, i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if v, err := db.GetCtx(ctx, "test", 1); err != nil || string(v) != "1" {
+		t.Errorf("db.GetCtx(1) == %q, %v, want \"1\", nil", v, err)
+	}
+	n := 0
+	_, err := db.TarverseCtx(ctx, "test", func(k, v []byte) []byte {
+		if n++; n == 3 {
+			cancel()
+		}
+		return v
+	})
+	if !errors.Is(err, context.Canceled) || n != 3 {
+		t.Errorf("db.TarverseCtx() visited %d, err=%v, want 3, context.Canceled", n, err)
+	}
+
+	if _, err := db.GetCtx(ctx, "test", 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("db.GetCtx() on canceled ctx err=%v, want context.Canceled", err)
+	}
+	if err := db.SetCtx(ctx, "test", "k", "v"); !errors.Is(err, context.Canceled) {
+		t.Errorf("db.SetCtx() on canceled ctx err=%v, want context.Canceled", err)
+	}
+	if ok, _ := db.Has("test", "k"); ok {
+		t.Errorf("db.SetCtx() on canceled ctx wrote the key")
+	}
+	if err := db.DeleteCtx(ctx, "test", 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("db.DeleteCtx() on canceled ctx err=%v, want context.Canceled", err)
+	}
+	if err := db.ScanCtx(ctx, "test", nil, func(k, v []byte) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("db.ScanCtx() on canceled ctx err=%v, want context.Canceled", err)
+	}
+
+	if err := db.SetCtx(context.Background(), "test", "k", "v"); err != nil {
+		t.Errorf("db.SetCtx() err=%v", err)
+	}
+	if err := db.DeleteCtx(context.Background(), "test", "k"); err != nil {
+		t.Errorf("db.DeleteCtx() err=%v", err)
+	}
+	if _, err := db.TarverseCtx(context.Background(), "missing", func(k, v []byte) []byte { return v }); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.TarverseCtx(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
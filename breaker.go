@@ -0,0 +1,115 @@
+package bdb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 熔断期间的写操作直接返回该错误
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// 熔断器状态
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // 正常
+	CircuitOpen                         // 熔断中，写操作快速失败
+	CircuitHalfOpen                     // 冷却结束，放行一次探测写
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// 熔断器统计信息
+type CircuitStats struct {
+	State    CircuitState // 当前状态
+	Failures int          // 连续失败次数
+	OpenedAt time.Time    // 最近一次熔断的时间
+}
+
+// 写熔断器，只统计提交失败(如磁盘满)，调用方参数错误不计入
+type breaker struct {
+	mu        sync.Mutex
+	threshold int           // 连续失败多少次后熔断，0表示关闭
+	cooldown  time.Duration // 熔断持续时长
+	failures  int
+	state     CircuitState
+	openedAt  time.Time
+	probing   bool // 半开状态下是否已有探测写在进行
+}
+
+func (b *dbConnection) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	br := &b.breaker
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.threshold = threshold
+	br.cooldown = cooldown
+	br.failures = 0
+	br.state = CircuitClosed
+	br.probing = false
+}
+
+func (b *dbConnection) CircuitStats() CircuitStats {
+	br := &b.breaker
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return CircuitStats{State: br.state, Failures: br.failures, OpenedAt: br.openedAt}
+}
+
+// 写操作开始前检查是否放行
+func (br *breaker) allow() error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.threshold <= 0 {
+		return nil
+	}
+
+	switch br.state {
+	case CircuitOpen:
+		if time.Since(br.openedAt) < br.cooldown {
+			return ErrCircuitOpen
+		}
+		br.state = CircuitHalfOpen
+		br.probing = true
+	case CircuitHalfOpen:
+		if br.probing {
+			return ErrCircuitOpen
+		}
+		br.probing = true
+	}
+	return nil
+}
+
+// 记录写操作结果，err为事务的返回值，fnErr为事务函数自身的返回值
+func (br *breaker) done(err, fnErr error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.threshold <= 0 {
+		return
+	}
+	br.probing = false
+
+	switch {
+	case fnErr != nil:
+		// 未提交，不能说明存储是否恢复
+	case err != nil:
+		br.failures++
+		if br.state == CircuitHalfOpen || br.failures >= br.threshold {
+			br.state = CircuitOpen
+			br.openedAt = time.Now()
+		}
+	default:
+		br.failures = 0
+		br.state = CircuitClosed
+	}
+}
@@ -0,0 +1,66 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	failed := errors.New("commit failed")
+	br := &breaker{threshold: 2, cooldown: 20 * time.Millisecond}
+
+	for i := 0; i < 2; i++ {
+		if err := br.allow(); err != nil {
+			t.Fatalf("br.allow() #%d err=%v, want nil", i, err)
+		}
+		br.done(failed, nil)
+	}
+	if br.state != CircuitOpen {
+		t.Fatalf("state=%v after 2 failures, want open", br.state)
+	}
+	if err := br.allow(); err != ErrCircuitOpen {
+		t.Errorf("br.allow() while open err=%v, want ErrCircuitOpen", err)
+	}
+
+	// 冷却后只放行一次探测，探测失败重新熔断
+	time.Sleep(25 * time.Millisecond)
+	if err := br.allow(); err != nil {
+		t.Fatalf("probe br.allow() err=%v, want nil", err)
+	}
+	if err := br.allow(); err != ErrCircuitOpen {
+		t.Errorf("second br.allow() during probe err=%v, want ErrCircuitOpen", err)
+	}
+	br.done(failed, nil)
+	if br.state != CircuitOpen {
+		t.Fatalf("state=%v after failed probe, want open", br.state)
+	}
+
+	// 探测成功后恢复
+	time.Sleep(25 * time.Millisecond)
+	if err := br.allow(); err != nil {
+		t.Fatalf("probe br.allow() err=%v, want nil", err)
+	}
+	br.done(nil, nil)
+	if br.state != CircuitClosed || br.failures != 0 {
+		t.Errorf("state=%v failures=%d after successful probe, want closed/0", br.state, br.failures)
+	}
+
+	// 调用方错误不计入失败
+	br.done(failed, failed)
+	br.done(failed, failed)
+	if br.state != CircuitClosed {
+		t.Errorf("state=%v after caller errors, want closed", br.state)
+	}
+}
+
+func TestCircuitStats(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.SetCircuitBreaker(3, time.Second)
+	if err := db.Set("test", "k", "v"); err != nil {
+		t.Fatalf("db.Set() failed, err=%v", err)
+	}
+	if s := db.CircuitStats(); s.State != CircuitClosed || s.Failures != 0 {
+		t.Errorf("db.CircuitStats() == %+v, want closed", s)
+	}
+}
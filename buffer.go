@@ -0,0 +1,283 @@
+package bdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 写缓冲中的一次写操作
+type bufferedOp struct {
+	tn    string
+	key   []byte // Add时为nil，写入时再分配序列号
+	value []byte
+	del   bool
+}
+
+// 写缓冲，用持久性换吞吐：Set/Delete/Add先放进内存，定时或攒够一定条数后
+// 在一个写事务中写入。缓冲中的数据在进程崩溃时会丢失，最多丢失一个interval
+// 或size条的写入。Get会先查缓冲，其他读写操作开始前会先写入缓冲，保证读到自己的写。
+// 写入时被拒绝的写记日志并由Flush返回，不会报给触发写入的读操作
+type writeBuffer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	size     int
+	ops      []bufferedOp
+	latest   map[string]int // 表名+键 -> ops中最后一次写的下标
+	dropped  []error        // 写入时被拒绝而丢弃的写，由下一次Flush返回
+
+	ctlMu sync.Mutex // 保护后台flush协程的启停
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func bufferKey(tn string, k []byte) string {
+	return tn + "\x00" + string(k)
+}
+
+// 查缓冲中最后一次写的值，删除时返回nil
+func (wb *writeBuffer) get(tn string, k []byte) ([]byte, bool) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	i, ok := wb.latest[bufferKey(tn, k)]
+	if !ok {
+		return nil, false
+	}
+	op := wb.ops[i]
+	if op.del || len(op.value) == 0 {
+		return nil, true
+	}
+	return append([]byte{}, op.value...), true
+}
+
+func (b *dbConnection) SetWriteBuffer(interval time.Duration, size int) {
+	b.stopFlusher()
+
+	wb := &b.buffer
+	wb.mu.Lock()
+	wb.interval = interval
+	wb.size = size
+	wb.mu.Unlock()
+
+	if interval <= 0 && size <= 0 {
+		if err := b.Flush(); err != nil && err != ErrClosed {
+			b.log().Error("bdb: flush write buffer failed", "err", err)
+		}
+		return
+	}
+	if interval > 0 {
+		wb.ctlMu.Lock()
+		wb.stop = make(chan struct{})
+		wb.done = make(chan struct{})
+		go b.runFlusher(interval, wb.stop, wb.done)
+		wb.ctlMu.Unlock()
+	}
+}
+
+// 写入缓冲，返回提交失败的错误以及上次Flush之后因被拒绝而丢弃的写
+func (b *dbConnection) Flush() error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+	defer b.release()
+	wb := &b.buffer
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	err := b.flushLocked()
+	dropped := wb.dropped
+	wb.dropped = nil
+	return errors.Join(append([]error{err}, dropped...)...)
+}
+
+// 开启了写缓冲时把op放入缓冲，返回是否已经处理
+func (b *dbConnection) buffered(op bufferedOp) (bool, error) {
+	if err := b.acquire(); err != nil {
+		return true, err
+	}
+	defer b.release()
+	if b.readOnly() {
+		return true, ErrReadOnly
+	}
+
+	wb := &b.buffer
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if wb.interval <= 0 && wb.size <= 0 {
+		return false, nil
+	}
+	// bolt会拒绝的写不能进缓冲，否则要到写入时才报错，调用方已经拿到了nil
+	if err := checkLimits(op); err != nil {
+		return true, err
+	}
+
+	// 调用方可能复用传入的[]byte
+	op.key = append([]byte(nil), op.key...)
+	op.value = append([]byte(nil), op.value...)
+	wb.ops = append(wb.ops, op)
+	if op.key != nil {
+		if wb.latest == nil {
+			wb.latest = make(map[string]int)
+		}
+		wb.latest[bufferKey(op.tn, op.key)] = len(wb.ops) - 1
+	}
+
+	// op已在缓冲中，写入失败时缓冲保留，由Flush或之后的写入重试
+	if wb.size > 0 && len(wb.ops) >= wb.size {
+		if err := b.flushLocked(); err != nil {
+			b.log().Error("bdb: flush write buffer failed", "db", b.name, "err", err)
+		}
+	}
+	return true, nil
+}
+
+// bolt对键值大小的限制
+func checkLimits(op bufferedOp) error {
+	switch {
+	case op.key != nil && len(op.key) == 0:
+		return fmt.Errorf("%v: %w", op.tn, bolt.ErrKeyRequired)
+	case len(op.key) > bolt.MaxKeySize:
+		return fmt.Errorf("%v: %d bytes key: %w", op.tn, len(op.key), bolt.ErrKeyTooLarge)
+	case len(op.value) > bolt.MaxValueSize:
+		return fmt.Errorf("%v.%q: %w", op.tn, op.key, bolt.ErrValueTooLarge)
+	}
+	return nil
+}
+
+// 写入缓冲，调用方须持有lifecycle锁或acquireHandle的占用
+func (b *dbConnection) flushBuffer() error {
+	b.buffer.mu.Lock()
+	defer b.buffer.mu.Unlock()
+	return b.flushLocked()
+}
+
+// 读之前写入缓冲，失败时记日志后照常读，不把写入的错误报给读的调用方
+func (b *dbConnection) flushBeforeRead() {
+	if err := b.flushBuffer(); err != nil {
+		b.log().Error("bdb: flush write buffer before read failed", "db", b.name, "err", err)
+	}
+}
+
+// 调用方须持有lifecycle锁(或acquireHandle的占用)和buffer.mu。某条写被拒绝(键过大、钩子、唯一索引等)时丢弃这一条，
+// 记日志并留给下一次Flush返回，其余的重新写入；表不存在的写同样被丢弃。只返回提交本身失败的错误，此时缓冲保留，下次重试
+func (b *dbConnection) flushLocked() error {
+	wb := &b.buffer
+	for len(wb.ops) > 0 {
+		bad, missing, err := b.writeOps(wb.ops)
+		if err != nil && bad < 0 {
+			return fmt.Errorf("flush write buffer failed: %w", err)
+		}
+		if err != nil {
+			b.log().Error("bdb: buffered write dropped", "db", b.name, "err", err)
+			wb.dropped = append(wb.dropped, fmt.Errorf("flush write buffer: write dropped: %w", err))
+			wb.ops = append(wb.ops[:bad], wb.ops[bad+1:]...)
+			wb.reindex()
+			continue
+		}
+
+		wb.ops = wb.ops[:0]
+		wb.latest = nil
+		if len(missing) > 0 {
+			b.log().Error("bdb: buffered writes to missing tables dropped", "db", b.name, "tables", missing)
+			wb.dropped = append(wb.dropped, fmt.Errorf("flush write buffer: tables %v not exist, writes dropped", missing))
+		}
+	}
+	return nil
+}
+
+// 经由runWrite在一个写事务中写入ops，返回不存在的表。某条写失败时bad是它的下标，提交本身失败时为-1
+func (b *dbConnection) writeOps(ops []bufferedOp) (bad int, missing []string, err error) {
+	// 积压或熔断时fn不会执行，缓冲整体保留
+	bad = -1
+	txs, err := b.runWrite(context.Background(), func(tx *bolt.Tx) error {
+		bad, missing = -1, nil
+		seen := map[string]bool{}
+		for i, op := range ops {
+			op := op
+			bucket, _ := getBucket(tx, op.tn)
+			if bucket == nil && !op.del {
+				bucket, _ = b.writeBucket(tx, op.tn)
+			}
+			if bucket == nil {
+				if !seen[op.tn] {
+					seen[op.tn] = true
+					missing = append(missing, op.tn)
+				}
+				continue
+			}
+
+			var err error
+			switch {
+			case op.del:
+				if err = b.del(tx, bucket, op.tn, op.key); err == nil {
+					err = b.mirrorWrite(tx, func(m BoltDB) error { return m.Delete(op.tn, op.key) })
+				}
+			case op.key == nil:
+				var k, stored []byte
+				if k, stored, err = b.addToBucket(tx, bucket, op.tn, op.value); err == nil {
+					err = b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(op.tn, k, stored) })
+				}
+			default:
+				var stored []byte
+				if stored, err = b.putValue(tx, bucket, op.tn, op.key, op.value); err == nil {
+					err = b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(op.tn, op.key, stored) })
+				}
+			}
+			if err != nil {
+				bad = i
+				return fmt.Errorf("%v.%q: %w", op.tn, op.key, err)
+			}
+		}
+		return nil
+	}, false)
+	if err == nil {
+		b.runAfterLater(txs[0])
+	}
+	return bad, missing, err
+}
+
+// 删除ops中的元素后重建latest
+func (wb *writeBuffer) reindex() {
+	wb.latest = nil
+	for i, op := range wb.ops {
+		if op.key == nil {
+			continue
+		}
+		if wb.latest == nil {
+			wb.latest = make(map[string]int)
+		}
+		wb.latest[bufferKey(op.tn, op.key)] = i
+	}
+}
+
+// 后台定时写入缓冲
+func (b *dbConnection) runFlusher(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if err := b.Flush(); err != nil && err != ErrClosed {
+				b.log().Error("bdb: flush write buffer failed", "err", err)
+			}
+		}
+	}
+}
+
+// 停止后台flush协程并等待其退出
+func (b *dbConnection) stopFlusher() {
+	wb := &b.buffer
+	wb.ctlMu.Lock()
+	defer wb.ctlMu.Unlock()
+	if wb.stop != nil {
+		close(wb.stop)
+		<-wb.done
+		wb.stop, wb.done = nil, nil
+	}
+}
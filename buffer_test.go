@@ -0,0 +1,179 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 绕过缓冲直接读磁盘
+func rawGet(t *testing.T, db BoltDB, tn, key string) []byte {
+	t.Helper()
+	var ret []byte
+	db.(*dbConnection).bdb.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte(tn)).Get([]byte(key)); v != nil {
+			ret = append([]byte{}, v...)
+		}
+		return nil
+	})
+	return ret
+}
+
+func TestWriteBuffer(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "old", "1")
+	db.SetWriteBuffer(time.Hour, 100)
+
+	db.Set("test", "k", "v")
+	db.Delete("test", "old")
+	db.Add("test", "added")
+	if got := string(db.Get("test", "k")); got != "v" {
+		t.Errorf("buffered db.Get(k) == %q, want %q", got, "v")
+	}
+	if got := db.Get("test", "old"); got != nil {
+		t.Errorf("buffered db.Get(old) == %q, want nil after delete", got)
+	}
+	if got := rawGet(t, db, "test", "k"); got != nil {
+		t.Errorf("write reached disk before flush: %q", got)
+	}
+
+	if err := db.Flush(); err != nil {
+		t.Fatalf("db.Flush() failed, err=%v", err)
+	}
+	if got := string(rawGet(t, db, "test", "k")); got != "v" {
+		t.Errorf("after flush disk k == %q, want %q", got, "v")
+	}
+	if got := rawGet(t, db, "test", "old"); got != nil {
+		t.Errorf("after flush disk old == %q, want nil", got)
+	}
+	if got := string(rawGet(t, db, "test", "1")); got != "added" {
+		t.Errorf("after flush disk 1 == %q, want %q", got, "added")
+	}
+
+	// 其他读操作先写入缓冲
+	db.Set("test", "k2", "v2")
+	if m, err := db.AsMap("test", KindString, KindString); err != nil || m["k2"] != "v2" {
+		t.Errorf("db.AsMap() == %v, err=%v, want buffered k2", m, err)
+	}
+
+	// 攒够size条时写入
+	db.SetWriteBuffer(time.Hour, 2)
+	db.Set("test", "s1", "1")
+	db.Set("test", "s2", "2")
+	if got := string(rawGet(t, db, "test", "s2")); got != "2" {
+		t.Errorf("size-triggered flush: disk s2 == %q, want %q", got, "2")
+	}
+
+	// 定时写入
+	db.SetWriteBuffer(5*time.Millisecond, 0)
+	db.Set("test", "tick", "1")
+	deadline := time.Now().Add(time.Second)
+	for rawGet(t, db, "test", "tick") == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := string(rawGet(t, db, "test", "tick")); got != "1" {
+		t.Errorf("interval flush: disk tick == %q, want %q", got, "1")
+	}
+	db.SetWriteBuffer(0, 0)
+}
+
+func TestWriteBufferFlushOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path, 0600)
+	if err != nil {
+		t.Fatalf("Open() failed, err=%v", err)
+	}
+	db.CreateTable("test")
+	db.SetWriteBuffer(time.Hour, 100)
+	db.Set("test", "k", "v")
+	db.Close()
+
+	db, err = Open(path, 0600)
+	if err != nil {
+		t.Fatalf("Open() failed, err=%v", err)
+	}
+	defer db.Close()
+	if got := string(db.Get("test", "k")); got != "v" {
+		t.Errorf("db.Get(k) after reopen == %q, want %q", got, "v")
+	}
+}
+
+func TestWriteBufferRejectedWrite(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.SetWriteBuffer(time.Hour, 1000)
+
+	// bolt会拒绝的写在Set时就返回错误，不进缓冲
+	if err := db.Set("test", bytes.Repeat([]byte("k"), 40000), "v"); !errors.Is(err, bolt.ErrKeyTooLarge) {
+		t.Errorf("db.Set(40000 bytes key) err=%v, want bolt.ErrKeyTooLarge", err)
+	}
+
+	// 写入时才被拒绝的写只丢弃这一条，不会堵住缓冲
+	db.RegisterHook(BeforeSet, func(op *WriteOp) error {
+		if string(op.Value) == "bad" {
+			return errors.New("bad value")
+		}
+		return nil
+	})
+	db.Set("test", "a", "1")
+	db.Set("test", "b", "bad")
+	db.Set("test", "c", "3")
+	// 读操作触发的写入不把别人的写的错误报给读
+	if n, err := db.Count("test"); err != nil || n != 2 {
+		t.Errorf("db.Count() with a rejected buffered write == %d, %v, want 2, nil", n, err)
+	}
+	if err := db.Flush(); err == nil {
+		t.Errorf("db.Flush() with a rejected write err=nil, want error")
+	}
+	if got := string(rawGet(t, db, "test", "c")); got != "3" {
+		t.Errorf("c after Flush == %q, want %q", got, "3")
+	}
+	if got := rawGet(t, db, "test", "b"); got != nil {
+		t.Errorf("rejected b written as %q", got)
+	}
+	if err := db.Flush(); err != nil {
+		t.Errorf("second db.Flush() err=%v, want nil", err)
+	}
+	if v, err := db.GetValue("test", "a"); err != nil || string(v) != "1" {
+		t.Errorf("db.GetValue(a) == %q, %v, want 1, nil", v, err)
+	}
+	if _, err := db.GetValue("test", "b"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.GetValue(b) err=%v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestWriteBufferCommitPath(t *testing.T) {
+	db := openTestDB(t, "test")
+	o := &testObserver{}
+	db.SetObserver(o)
+	db.SetWriteBuffer(time.Hour, 1000)
+	db.Set("test", "a", "1")
+	db.Set("test", "b", "2")
+	if err := db.Flush(); err != nil {
+		t.Fatalf("db.Flush() failed, err=%v", err)
+	}
+	if o.commits != 1 {
+		t.Errorf("%d commits observed for a flush, want 1", o.commits)
+	}
+
+	// 熔断时写入被拒绝，缓冲保留到下次
+	dc := db.(*dbConnection)
+	db.SetCircuitBreaker(1, time.Hour)
+	dc.breaker.mu.Lock()
+	dc.breaker.state, dc.breaker.openedAt = CircuitOpen, time.Now()
+	dc.breaker.mu.Unlock()
+	db.Set("test", "c", "3")
+	if err := db.Flush(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("db.Flush() with an open circuit err=%v, want ErrCircuitOpen", err)
+	}
+	db.SetCircuitBreaker(0, 0)
+	if err := db.Flush(); err != nil {
+		t.Errorf("db.Flush() after closing the circuit err=%v, want nil", err)
+	}
+	if got := string(rawGet(t, db, "test", "c")); got != "3" {
+		t.Errorf("c after Flush == %q, want %q", got, "3")
+	}
+}
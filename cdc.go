@@ -0,0 +1,366 @@
+package bdb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 变更记录的内部表，子表log为 序号 -> JSON编码的ChangeRecord，子表cursors为 消费者名字 -> 已送达的序号
+const cdcTable = "__bdb_cdc"
+
+var (
+	cdcLog     = []byte("log")
+	cdcCursors = []byte("cursors")
+)
+
+// 每次从变更记录读出、送往sink的条数
+const cdcBatch = 100
+
+// sink出错后重试的间隔
+var cdcRetry = time.Second
+
+// 一条已提交的变更
+type ChangeRecord struct {
+	Seq   uint64   `json:"seq"`
+	Op    ChangeOp `json:"op"`
+	Table string   `json:"table"`
+	Key   []byte   `json:"key"`
+	Value []byte   `json:"value,omitempty"`
+}
+
+func (op ChangeOp) String() string {
+	if op == OpDelete {
+		return "delete"
+	}
+	return "put"
+}
+
+func (op ChangeOp) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+func (op *ChangeOp) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "put":
+		*op = OpPut
+	case "delete":
+		*op = OpDelete
+	default:
+		return fmt.Errorf("unknown op %q", text)
+	}
+	return nil
+}
+
+// 接收变更，返回错误时稍后从这一条重新发送
+type ChangeSink interface {
+	Send(r ChangeRecord) error
+}
+
+// 可能阻塞的sink另外实现SendContext，停止发送或关闭数据库时ctx被取消，须尽快返回
+type ContextSink interface {
+	ChangeSink
+	SendContext(ctx context.Context, r ChangeRecord) error
+}
+
+// 函数形式的ChangeSink
+type SinkFunc func(r ChangeRecord) error
+
+func (f SinkFunc) Send(r ChangeRecord) error {
+	return f(r)
+}
+
+// 每条变更写为一行JSON
+func WriterSink(w io.Writer) ChangeSink {
+	enc := json.NewEncoder(w)
+	return SinkFunc(func(r ChangeRecord) error { return enc.Encode(r) })
+}
+
+// 变更送入通道，通道满时阻塞，直到停止发送
+func ChanSink(ch chan<- ChangeRecord) ChangeSink {
+	return chanSink(ch)
+}
+
+type chanSink chan<- ChangeRecord
+
+func (c chanSink) Send(r ChangeRecord) error {
+	c <- r
+	return nil
+}
+
+func (c chanSink) SendContext(ctx context.Context, r ChangeRecord) error {
+	select {
+	case c <- r:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 发送一条变更，sink实现了ContextSink时可以被ctx打断
+func send(ctx context.Context, sink ChangeSink, r ChangeRecord) error {
+	if cs, ok := sink.(ContextSink); ok {
+		return cs.SendContext(ctx, r)
+	}
+	return sink.Send(r)
+}
+
+type cdc struct {
+	enabled atomic.Bool
+
+	mu      sync.Mutex
+	changed chan struct{} // 有新的提交时关闭并换新
+	streams map[*cdcStream]struct{}
+}
+
+type cdcStream struct {
+	ctx  context.Context // 停止时取消
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// 开启或关闭变更记录。开启后单条的写入、删除(包括过期清理)在同一个事务中记入变更记录；
+// 整表的操作不记录
+func (b *dbConnection) SetCDC(enabled bool) {
+	b.cdc.enabled.Store(enabled)
+}
+
+// 在写事务中记录一条变更
+func (b *dbConnection) capture(tx *bolt.Tx, op ChangeOp, tn string, k, v []byte) error {
+	if !b.cdc.enabled.Load() {
+		return nil
+	}
+	root, err := tx.CreateBucketIfNotExists([]byte(cdcTable))
+	if err != nil {
+		return err
+	}
+	bucket, err := root.CreateBucketIfNotExists(cdcLog)
+	if err != nil {
+		return err
+	}
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(ChangeRecord{Seq: seq, Op: op, Table: tn, Key: k, Value: v})
+	if err != nil {
+		return err
+	}
+	if err = bucket.Put(seqKey(seq), data); err != nil {
+		return err
+	}
+	tx.OnCommit(b.cdc.notify)
+	return nil
+}
+
+func (c *cdc) notify() {
+	c.mu.Lock()
+	if c.changed != nil {
+		close(c.changed)
+		c.changed = nil
+	}
+	c.mu.Unlock()
+}
+
+// 下次有变更提交时关闭的通道
+func (c *cdc) wait() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changed == nil {
+		c.changed = make(chan struct{})
+	}
+	return c.changed
+}
+
+// 在后台把名为name的消费者尚未送达的变更按序号依次送往sink，送达后持久化游标，
+// 重启后用同样的名字继续。sink出错或进程在送达与保存游标之间退出时同一条会再次发送。
+// 返回的函数停止发送，Close数据库时也会停止
+func (b *dbConnection) StreamChanges(name string, sink ChangeSink) (func(), error) {
+	if name == "" {
+		return nil, fmt.Errorf("empty stream name:%w", ErrEmptyKey)
+	}
+	if _, err := b.ChangeCursor(name); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &cdcStream{ctx: ctx, stop: cancel, done: make(chan struct{})}
+	b.cdc.mu.Lock()
+	if b.cdc.streams == nil {
+		b.cdc.streams = make(map[*cdcStream]struct{})
+	}
+	b.cdc.streams[s] = struct{}{}
+	b.cdc.mu.Unlock()
+
+	go b.runStream(s, name, sink)
+	return func() { b.stopStream(s) }, nil
+}
+
+func (b *dbConnection) stopStream(s *cdcStream) {
+	s.stop()
+	<-s.done
+	b.cdc.mu.Lock()
+	delete(b.cdc.streams, s)
+	b.cdc.mu.Unlock()
+}
+
+// 停止所有的发送协程
+func (b *dbConnection) stopStreams() {
+	b.cdc.mu.Lock()
+	streams := make([]*cdcStream, 0, len(b.cdc.streams))
+	for s := range b.cdc.streams {
+		streams = append(streams, s)
+	}
+	b.cdc.mu.Unlock()
+	for _, s := range streams {
+		b.stopStream(s)
+	}
+}
+
+func (b *dbConnection) runStream(s *cdcStream, name string, sink ChangeSink) {
+	defer close(s.done)
+	for {
+		// 先取通道再读，读完之后的提交也能唤醒
+		changed := b.cdc.wait()
+		n, err := b.sendChanges(s.ctx, name, sink)
+		if s.ctx.Err() != nil {
+			return
+		}
+		var retry <-chan time.Time
+		if err != nil {
+			b.log().Error("bdb: stream changes failed", "stream", name, "err", err)
+			retry = time.After(cdcRetry)
+			changed = nil
+		} else if n == cdcBatch {
+			continue
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-changed:
+		case <-retry:
+		}
+	}
+}
+
+// 读出一批未送达的变更发送并保存游标，返回送达的条数
+func (b *dbConnection) sendChanges(ctx context.Context, name string, sink ChangeSink) (int, error) {
+	after, err := b.ChangeCursor(name)
+	if err != nil {
+		return 0, err
+	}
+	records, err := b.ReadChanges(after, cdcBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range records {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if err = send(ctx, sink, r); err != nil {
+			break
+		}
+		sent++
+	}
+	if sent > 0 {
+		if serr := b.setChangeCursor(name, records[sent-1].Seq); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	if sent == len(records) && err == nil {
+		return sent, nil
+	}
+	return sent, err
+}
+
+// 按序号返回after之后的最多limit条变更，limit<=0时不限条数
+func (b *dbConnection) ReadChanges(after uint64, limit int) (records []ChangeRecord, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		records = []ChangeRecord{}
+		bucket := cdcBucket(tx, cdcLog)
+		if bucket == nil || after == ^uint64(0) {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.Seek(seqKey(after + 1)); k != nil && (limit <= 0 || len(records) < limit); k, v = c.Next() {
+			var r ChangeRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("decode change %d failed: %v", binary.BigEndian.Uint64(k), err)
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// 消费者name已送达的最后一条变更的序号，从未发送过时为0
+func (b *dbConnection) ChangeCursor(name string) (seq uint64, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		seq = 0
+		if bucket := cdcBucket(tx, cdcCursors); bucket != nil {
+			if v := bucket.Get([]byte(name)); len(v) == 8 {
+				seq = binary.BigEndian.Uint64(v)
+			}
+		}
+		return nil
+	})
+	return seq, err
+}
+
+func (b *dbConnection) setChangeCursor(name string, seq uint64) error {
+	return b.update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(cdcTable))
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists(cdcCursors)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), seqKey(seq))
+	})
+}
+
+// 删除序号不大于upTo的变更记录，返回删除的条数；消费者的游标不受影响
+func (b *dbConnection) TruncateChanges(upTo uint64) (count int, err error) {
+	err = b.update(func(tx *bolt.Tx) error {
+		count = 0
+		bucket := cdcBucket(tx, cdcLog)
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= upTo; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func cdcBucket(tx *bolt.Tx, name []byte) *bolt.Bucket {
+	if root := tx.Bucket([]byte(cdcTable)); root != nil {
+		return root.Bucket(name)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadChanges(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "off", "v")
+	db.SetCDC(true)
+	db.Set("test", "k", "1")
+	db.Delete("test", "k")
+	db.SetWithTTL("test", "t", "2", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	db.PurgeExpired()
+
+	var want = []struct {
+		op         ChangeOp
+		key, value string
+	}{
+		{OpPut, "k", "1"},
+		{OpDelete, "k", ""},
+		{OpPut, "t", "2"},
+		{OpDelete, "t", ""},
+	}
+	records, err := db.ReadChanges(0, 0)
+	if err != nil || len(records) != len(want) {
+		t.Fatalf("db.ReadChanges() == %+v, %v, want %d records", records, err, len(want))
+	}
+	for i, w := range want {
+		r := records[i]
+		if r.Seq != uint64(i+1) || r.Op != w.op || r.Table != "test" || string(r.Key) != w.key || string(r.Value) != w.value {
+			t.Errorf("records[%d] == %+v, want %v", i, r, w)
+		}
+	}
+	if n, err := db.TruncateChanges(2); err != nil || n != 2 {
+		t.Errorf("db.TruncateChanges(2) == %d, %v, want 2, nil", n, err)
+	}
+	if rest, _ := db.ReadChanges(0, 1); len(rest) != 1 || rest[0].Seq != 3 {
+		t.Errorf("db.ReadChanges(0, 1) after truncate == %+v, want seq 3", rest)
+	}
+}
+
+func TestStreamChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path, 0600)
+	if err != nil {
+		t.Fatalf("Open() failed, err=%v", err)
+	}
+	db.CreateTable("test")
+	db.SetCDC(true)
+	db.Set("test", "a", "1")
+
+	ch := make(chan ChangeRecord, 10)
+	stop, err := db.StreamChanges("replica", ChanSink(ch))
+	if err != nil {
+		t.Fatalf("db.StreamChanges() failed, err=%v", err)
+	}
+	db.Set("test", "b", "2")
+	for _, key := range []string{"a", "b"} {
+		select {
+		case r := <-ch:
+			if string(r.Key) != key {
+				t.Errorf("streamed key == %q, want %q", r.Key, key)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("change %q not streamed", key)
+		}
+	}
+	stop()
+	if seq, _ := db.ChangeCursor("replica"); seq != 2 {
+		t.Errorf("db.ChangeCursor() == %d, want 2", seq)
+	}
+	db.Set("test", "c", "3")
+	db.Close()
+
+	// 重启后从游标继续
+	db, err = Open(path, 0600)
+	if err != nil {
+		t.Fatalf("Open() again failed, err=%v", err)
+	}
+	defer db.Close()
+	var buf bytes.Buffer
+	stop, _ = db.StreamChanges("replica", WriterSink(&buf))
+	deadline := time.Now().Add(time.Second)
+	for {
+		if seq, _ := db.ChangeCursor("replica"); seq == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("change c not streamed after reopen")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+	var r ChangeRecord
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil || r.Seq != 3 || r.Op != OpPut || string(r.Key) != "c" {
+		t.Errorf("written change == %q, %v, want seq 3 put c", buf.Bytes(), err)
+	}
+}
+
+func TestStreamChangesRetry(t *testing.T) {
+	cdcRetry = 5 * time.Millisecond
+	defer func() { cdcRetry = time.Second }()
+
+	db := openTestDB(t, "test")
+	db.SetCDC(true)
+	db.Set("test", "a", "1")
+
+	fails := 2
+	got := make(chan string, 10)
+	stop, _ := db.StreamChanges("flaky", SinkFunc(func(r ChangeRecord) error {
+		if fails > 0 {
+			fails--
+			return errors.New("sink unavailable")
+		}
+		got <- string(r.Key)
+		return nil
+	}))
+	defer stop()
+	select {
+	case k := <-got:
+		if k != "a" {
+			t.Errorf("streamed key == %q, want %q", k, "a")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("change not delivered after retries")
+	}
+}
+
+func TestStreamChangesStuckConsumer(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), 0600)
+	if err != nil {
+		t.Fatalf("Open() failed, err=%v", err)
+	}
+	db.CreateTable("test")
+	db.SetCDC(true)
+	for i := 0; i < cdcBatch+10; i++ {
+		db.Set("test", i, i)
+	}
+
+	// 没有人读的通道不会让Close卡住
+	ch := make(chan ChangeRecord)
+	if _, err := db.StreamChanges("stuck", ChanSink(ch)); err != nil {
+		t.Fatalf("db.StreamChanges() failed, err=%v", err)
+	}
+	<-ch
+	closed := make(chan struct{})
+	go func() {
+		db.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("db.Close() blocked by a consumer that stopped reading")
+	}
+}
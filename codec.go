@@ -0,0 +1,107 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// 值的编解码方式，用于存取任意的Go类型
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// 内置的编解码方式，msgpack、protobuf等可自行实现Codec
+var (
+	JSONCodec Codec = jsonCodec{} // encoding/json
+	GobCodec  Codec = gobCodec{}  // encoding/gob，每个值单独编码，自带类型描述
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// 连接的默认编解码方式和按表指定的编解码方式
+type codecs struct {
+	mu     sync.RWMutex
+	def    Codec
+	tables map[string]Codec
+}
+
+// c为nil时恢复为JSONCodec
+func (b *dbConnection) SetCodec(c Codec) {
+	b.codecs.mu.Lock()
+	defer b.codecs.mu.Unlock()
+	b.codecs.def = c
+}
+
+// 为表tn单独指定编解码方式，c为nil时取消，改用连接的默认值
+func (b *dbConnection) SetTableCodec(tn string, c Codec) {
+	b.codecs.mu.Lock()
+	defer b.codecs.mu.Unlock()
+	if c == nil {
+		delete(b.codecs.tables, tn)
+		return
+	}
+	if b.codecs.tables == nil {
+		b.codecs.tables = make(map[string]Codec)
+	}
+	b.codecs.tables[tn] = c
+}
+
+// 表tn使用的编解码方式
+func (b *dbConnection) codec(tn string) Codec {
+	b.codecs.mu.RLock()
+	defer b.codecs.mu.RUnlock()
+	if c, ok := b.codecs.tables[tn]; ok {
+		return c
+	}
+	if b.codecs.def != nil {
+		return b.codecs.def
+	}
+	return JSONCodec
+}
+
+// 用表的编解码方式编码v后写入，v可以是任意类型，如结构体
+func (b *dbConnection) SetObject(tn string, key, v interface{}) error {
+	data, err := b.codec(tn).Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %v.%v failed: %w", tn, key, err)
+	}
+	return b.Set(tn, key, data)
+}
+
+// 读出键值并用表的编解码方式解码到out，out须是指针。键不存在时返回ErrKeyNotFound
+func (b *dbConnection) GetObject(tn string, key, out interface{}) error {
+	data, err := b.GetValue(tn, key)
+	if err != nil {
+		return err
+	}
+	if err = b.codec(tn).Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %v.%v failed: %w", tn, key, err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestObjectCodecs(t *testing.T) {
+	db := openTestDB(t, "json", "gob")
+	db.SetTableCodec("gob", GobCodec)
+
+	for _, tn := range []string{"json", "gob"} {
+		if err := db.SetObject(tn, "p", point{1, 2}); err != nil {
+			t.Fatalf("db.SetObject(%v) failed, err=%v", tn, err)
+		}
+		var p point
+		if err := db.GetObject(tn, "p", &p); err != nil || p != (point{1, 2}) {
+			t.Errorf("db.GetObject(%v) == %+v, %v, want {1 2}", tn, p, err)
+		}
+	}
+	if v := string(db.Get("json", "p")); v != `{"X":1,"Y":2}` {
+		t.Errorf("json value == %s", v)
+	}
+
+	// 换了编解码方式后旧数据无法解码
+	db.SetTableCodec("gob", nil)
+	var p point
+	if err := db.GetObject("gob", "p", &p); err == nil {
+		t.Errorf("db.GetObject(gob) with JSONCodec succeeded")
+	}
+	db.SetCodec(GobCodec)
+	if err := db.GetObject("gob", "p", &p); err != nil || p != (point{1, 2}) {
+		t.Errorf("db.GetObject(gob) with default GobCodec == %+v, %v", p, err)
+	}
+
+	if err := db.GetObject("json", "missing", &p); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.GetObject(missing) err=%v, want ErrKeyNotFound", err)
+	}
+	if err := db.SetObject("json", "ch", make(chan int)); err == nil {
+		t.Errorf("db.SetObject(chan) succeeded")
+	}
+}
@@ -0,0 +1,178 @@
+package bdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/boltdb/bolt"
+)
+
+// 压缩后的页填充率。压缩后的文件多用于读，填得比bolt默认的0.5满，之后的随机写入会引起分裂
+const compactFillPercent = 0.9
+
+// 压缩时每个写事务最多写入的字节数，避免大库在一个事务中占用过多内存
+const compactTxSize = 64 << 20
+
+// 把所有表和键值复制到dstPath的新文件中，空闲页不复制，文件比原来的小。
+// dstPath为空时原地压缩：复制到临时文件后替换当前文件并重新打开，期间其它操作等待
+func (b *dbConnection) Compact(dstPath string) error {
+	if dstPath != "" {
+		if abs, err := filepath.Abs(dstPath); err == nil {
+			if cur, err := filepath.Abs(b.GetDBName()); err == nil && abs == cur {
+				return fmt.Errorf("compact into the open database file %v", dstPath)
+			}
+		}
+		mode := b.fileMode()
+		return b.view(func(tx *bolt.Tx) error {
+			return compactTo(tx, dstPath, mode)
+		})
+	}
+
+	b.lockExclusive(false)
+	defer b.unlockExclusive()
+	if b.closed {
+		return ErrClosed
+	}
+	if b.bdb == nil {
+		return ErrNotOpen
+	}
+	if b.readOnly() {
+		return ErrReadOnly
+	}
+	if err := b.flushBuffer(); err != nil {
+		b.log().Error("bdb: flush write buffer before compact failed", "db", b.name, "err", err)
+	}
+
+	tmp := b.name + ".compact"
+	os.Remove(tmp)
+	mode := b.mode
+	if mode == 0 {
+		mode = 0600
+	}
+	err := b.bdb.View(func(tx *bolt.Tx) error {
+		return compactTo(tx, tmp, mode)
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return b.swapLocked(tmp)
+}
+
+// 把src中的所有内容复制到path处新建的库
+func compactTo(src *bolt.Tx, path string, mode os.FileMode) (err error) {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("compact destination %v already exists", path)
+	}
+	dst, err := bolt.Open(path, mode, nil)
+	if err != nil {
+		return fmt.Errorf("create compact file failed:%w", err)
+	}
+	defer func() {
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	c := &compactor{dst: dst}
+	defer c.rollback()
+	err = src.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+		return c.copyBucket(nil, name, bucket)
+	})
+	if err != nil {
+		return err
+	}
+	return c.commit()
+}
+
+type compactor struct {
+	dst  *bolt.DB
+	tx   *bolt.Tx
+	size int
+}
+
+// 准备写入n字节的写事务，当前事务写入过多时先提交
+func (c *compactor) begin(n int) error {
+	if c.tx != nil && c.size+n > compactTxSize {
+		if err := c.commit(); err != nil {
+			return err
+		}
+	}
+	if c.tx == nil {
+		tx, err := c.dst.Begin(true)
+		if err != nil {
+			return err
+		}
+		c.tx, c.size = tx, 0
+	}
+	c.size += n
+	return nil
+}
+
+// 在当前写事务中取得path处的目标表
+func (c *compactor) bucket(path [][]byte, n int) (*bolt.Bucket, error) {
+	if err := c.begin(n); err != nil {
+		return nil, err
+	}
+	bucket := c.tx.Bucket(path[0])
+	for _, name := range path[1:] {
+		bucket = bucket.Bucket(name)
+	}
+	bucket.FillPercent = compactFillPercent
+	return bucket, nil
+}
+
+func (c *compactor) copyBucket(parent [][]byte, name []byte, src *bolt.Bucket) error {
+	var bucket *bolt.Bucket
+	var err error
+	if parent == nil {
+		if err = c.begin(len(name)); err != nil {
+			return err
+		}
+		bucket, err = c.tx.CreateBucket(name)
+	} else {
+		var p *bolt.Bucket
+		if p, err = c.bucket(parent, len(name)); err != nil {
+			return err
+		}
+		bucket, err = p.CreateBucket(name)
+	}
+	if err != nil {
+		return err
+	}
+	if err = bucket.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+
+	path := append(append([][]byte{}, parent...), append([]byte{}, name...))
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return c.copyBucket(path, k, src.Bucket(k))
+		}
+		bucket, err := c.bucket(path, len(k)+len(v))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(k, v)
+	})
+}
+
+func (c *compactor) commit() error {
+	if c.tx == nil {
+		return nil
+	}
+	tx := c.tx
+	c.tx = nil
+	return tx.Commit()
+}
+
+func (c *compactor) rollback() {
+	if c.tx != nil {
+		c.tx.Rollback()
+		c.tx = nil
+	}
+}
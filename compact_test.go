@@ -0,0 +1,77 @@
+package bdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("os.Stat(%v) failed, err=%v", path, err)
+	}
+	return fi.Size()
+}
+
+func TestCompact(t *testing.T) {
+	db := openTestDB(t, "test", "test/sub")
+	for i := 0; i < 2000; i++ {
+		db.Set("test", i, bytes.Repeat([]byte("x"), 1000))
+	}
+	db.Set("test/sub", "k", "v")
+	db.Add("test", "seq")
+	db.DeleteByPrefix("test", []byte("1"))
+	before := fileSize(t, db.GetDBName())
+
+	dst := filepath.Join(t.TempDir(), "compact.db")
+	if err := db.Compact(dst); err != nil {
+		t.Fatalf("db.Compact(dst) failed, err=%v", err)
+	}
+	if after := fileSize(t, dst); after >= before {
+		t.Errorf("compacted size == %d, want < %d", after, before)
+	}
+	if err := db.Compact(dst); err == nil {
+		t.Errorf("db.Compact(existing dst) err=nil, want error")
+	}
+	if err := db.Compact(db.GetDBName()); err == nil {
+		t.Errorf("db.Compact(self) err=nil, want error")
+	}
+
+	c, err := Open(dst, 0600)
+	if err != nil {
+		t.Fatalf("Open(compacted) failed, err=%v", err)
+	}
+	defer c.Close()
+	for _, db := range []BoltDB{db, c} {
+		if got := string(db.Get("test/sub", "k")); got != "v" {
+			t.Errorf("Get(test/sub, k) == %q, want %q", got, "v")
+		}
+		if ok, _ := db.Has("test", 2); !ok {
+			t.Errorf("Has(test, 2) == false, want true")
+		}
+	}
+	want, _ := db.TableHash("test")
+	if got, _ := c.TableHash("test"); !bytes.Equal(got, want) {
+		t.Errorf("compacted TableHash == %x, want %x", got, want)
+	}
+
+	// 原地压缩后库仍可用，序列号保留
+	if err := db.Compact(""); err != nil {
+		t.Fatalf("db.Compact(\"\") failed, err=%v", err)
+	}
+	if after := fileSize(t, db.GetDBName()); after >= before {
+		t.Errorf("size after in-place compact == %d, want < %d", after, before)
+	}
+	if got, _ := db.TableHash("test"); !bytes.Equal(got, want) {
+		t.Errorf("TableHash after in-place compact == %x, want %x", got, want)
+	}
+	info, _ := db.Describe()
+	for _, ti := range info.Tables {
+		if ti.Name == "test" && ti.Sequence != 1 {
+			t.Errorf("sequence after compact == %d, want 1", ti.Sequence)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package bdb
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/boltdb/bolt"
+)
+
+// 是否开启写入校验，默认关闭
+var debugChecks atomic.Bool
+
+// 开启后每次写入都会校验键值编码能否无损还原、长度是否超限，
+// 发现问题直接返回错误而不是写入错误数据，用于开发调试
+func EnableDebugChecks(on bool) {
+	debugChecks.Store(on)
+}
+
+// 写入前的调试校验，key为nil表示键由内部生成
+func debugCheck(key, value interface{}, k, v []byte) error {
+	if !debugChecks.Load() {
+		return nil
+	}
+
+	if key != nil {
+		if len(k) == 0 {
+			return fmt.Errorf("debug check: key %v encodes to empty bytes", key)
+		}
+		if len(k) > bolt.MaxKeySize {
+			return fmt.Errorf("debug check: key size %d exceeds limit %d", len(k), bolt.MaxKeySize)
+		}
+		if err := checkRoundTrip(key, k); err != nil {
+			return fmt.Errorf("debug check: key %v", err)
+		}
+	}
+	if len(v) > bolt.MaxValueSize {
+		return fmt.Errorf("debug check: value size %d exceeds limit %d", len(v), bolt.MaxValueSize)
+	}
+	if err := checkRoundTrip(value, v); err != nil {
+		return fmt.Errorf("debug check: value %v", err)
+	}
+	return nil
+}
+
+// 校验dataToBytes的结果能还原出原值
+func checkRoundTrip(data interface{}, b []byte) error {
+	var ok bool
+	switch val := data.(type) {
+	case int, int8, int16, int32, int64:
+		n, err := strconv.ParseInt(string(b), 10, 64)
+		ok = err == nil && fmt.Sprint(n) == fmt.Sprint(val)
+	case uint, uint8, uint16, uint32, uint64:
+		n, err := strconv.ParseUint(string(b), 10, 64)
+		ok = err == nil && fmt.Sprint(n) == fmt.Sprint(val)
+	case float64:
+		f, err := strconv.ParseFloat(string(b), 64)
+		ok = err == nil && f == val
+	case float32:
+		f, err := strconv.ParseFloat(string(b), 32)
+		ok = err == nil && float32(f) == val
+	case bool:
+		f, err := strconv.ParseBool(string(b))
+		ok = err == nil && f == val
+	default:
+		// string、[]byte以及Stringer原样写入
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("%v (%T) encodes to %q which does not decode back", data, data, b)
+	}
+	return nil
+}
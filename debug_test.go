@@ -0,0 +1,32 @@
+package bdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDebugChecks(t *testing.T) {
+	db := openTestDB(t, "test")
+
+	// 默认关闭，%f编码的精度损失不会被发现
+	if err := db.Set("test", "pi", 3.14159265); err != nil {
+		t.Fatalf("db.Set() with checks off failed, err=%v", err)
+	}
+
+	EnableDebugChecks(true)
+	defer EnableDebugChecks(false)
+
+	err := db.Set("test", "pi", 3.14159265)
+	if err == nil || !strings.Contains(err.Error(), "debug check") {
+		t.Errorf("db.Set(lossy float) err=%v, want debug check error", err)
+	}
+	if err := db.Add("test", float32(0.1234567)); err == nil {
+		t.Errorf("db.Add(lossy float32) should fail with debug checks on")
+	}
+
+	for _, v := range []interface{}{1.5, -42, uint8(7), true, "s", []byte("b")} {
+		if err := db.Set("test", "ok", v); err != nil {
+			t.Errorf("db.Set(%v) failed, err=%v", v, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package bdb
+
+import (
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// 键被删除时的回调，value为删除前的值
+type EventFunc func(tn string, key, value []byte)
+
+type eventKind int
+
+const (
+	eventDelete eventKind = iota // Delete等删除单个键
+	eventExpire                  // 过期后被清理
+)
+
+// 各表注册的回调
+type events struct {
+	mu       sync.RWMutex
+	handlers [2]map[string][]EventFunc
+	pending  map[*bolt.Tx][]func() // 写事务中触发、等待提交后调用的回调
+}
+
+func (e *events) on(kind eventKind, tn string, fn EventFunc) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.handlers[kind] == nil {
+		e.handlers[kind] = make(map[string][]EventFunc)
+	}
+	e.handlers[kind][tn] = append(e.handlers[kind][tn], fn)
+}
+
+func (e *events) of(kind eventKind, tn string) []EventFunc {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.handlers[kind][tn]
+}
+
+// 表tn中的键过期被清理时调用fn
+func (b *dbConnection) OnExpire(tn string, fn EventFunc) {
+	b.events.on(eventExpire, tn, fn)
+}
+
+// 表tn中的键被Delete、DeleteMulti、Txn等删除时调用fn。
+// 删除表、清空表不逐条触发
+func (b *dbConnection) OnDelete(tn string, fn EventFunc) {
+	b.events.on(eventDelete, tn, fn)
+}
+
+// 在写事务中调用。事务提交后在一个新的协程中按删除的顺序调用这个事务触发的所有回调，
+// 不同事务的回调之间先后顺序不保证
+func (b *dbConnection) fire(tx *bolt.Tx, kind eventKind, tn string, k, old []byte) {
+	handlers := b.events.of(kind, tn)
+	if len(handlers) == 0 {
+		return
+	}
+	k = append([]byte{}, k...)
+	call := func() {
+		for _, fn := range handlers {
+			fn(tn, k, old)
+		}
+	}
+
+	e := &b.events
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending == nil {
+		e.pending = make(map[*bolt.Tx][]func())
+	}
+	calls, started := e.pending[tx]
+	e.pending[tx] = append(calls, call)
+	if started {
+		return
+	}
+	tx.OnCommit(func() {
+		e.mu.Lock()
+		calls := e.pending[tx]
+		delete(e.pending, tx)
+		e.mu.Unlock()
+		go func() {
+			for _, call := range calls {
+				call()
+			}
+		}()
+	})
+}
+
+// 写事务结束后丢弃未提交的回调
+func (e *events) forget(tx *bolt.Tx) {
+	e.mu.Lock()
+	delete(e.pending, tx)
+	e.mu.Unlock()
+}
@@ -0,0 +1,107 @@
+package bdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type event struct {
+	tn, key, value string
+}
+
+// 回调是异步的，等待收到n个事件
+func waitEvents(t *testing.T, ch chan event, n int) []event {
+	t.Helper()
+	var got []event
+	for len(got) < n {
+		select {
+		case e := <-ch:
+			got = append(got, e)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events %v, want %d", len(got), got, n)
+		}
+	}
+	return got
+}
+
+func TestOnDelete(t *testing.T) {
+	db := openTestDB(t, "test", "other")
+	ch := make(chan event, 10)
+	db.OnDelete("test", func(tn string, key, value []byte) {
+		ch <- event{tn, string(key), string(value)}
+	})
+
+	db.Set("test", "k", "v")
+	db.Set("other", "k", "v")
+	db.Delete("test", "k")
+	db.Delete("test", "missing")
+	db.Delete("other", "k")
+	if got := waitEvents(t, ch, 1); got[0] != (event{"test", "k", "v"}) {
+		t.Errorf("event == %v, want {test k v}", got[0])
+	}
+
+	db.SetBatch("test", map[interface{}]interface{}{"a": 1, "b": 2})
+	db.DeleteMulti("test", "a", "b")
+	waitEvents(t, ch, 2)
+
+	// 回滚的删除不触发
+	db.Set("test", "c", "3")
+	txn, _ := db.Begin(true)
+	txn.Delete("test", "c")
+	txn.Rollback()
+	select {
+	case e := <-ch:
+		t.Errorf("unexpected event %v after rollback", e)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestOnExpire(t *testing.T) {
+	db := openTestDB(t, "test")
+	expired := make(chan event, 10)
+	deleted := make(chan event, 10)
+	db.OnExpire("test", func(tn string, key, value []byte) {
+		expired <- event{tn, string(key), string(value)}
+	})
+	db.OnDelete("test", func(tn string, key, value []byte) {
+		deleted <- event{tn, string(key), string(value)}
+	})
+
+	db.SetWithTTL("test", "k", "v", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if n, err := db.PurgeExpired(); err != nil || n != 1 {
+		t.Fatalf("db.PurgeExpired() == %d, %v, want 1, nil", n, err)
+	}
+	if got := waitEvents(t, expired, 1); got[0] != (event{"test", "k", "v"}) {
+		t.Errorf("expire event == %v, want {test k v}", got[0])
+	}
+	select {
+	case e := <-deleted:
+		t.Errorf("unexpected delete event %v on expiry", e)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestOnDeleteOrder(t *testing.T) {
+	db := openTestDB(t, "test")
+	ch := make(chan event, 300)
+	db.OnDelete("test", func(tn string, key, value []byte) {
+		ch <- event{tn, string(key), string(value)}
+	})
+	kvs := map[interface{}]interface{}{}
+	for i := 0; i < 300; i++ {
+		kvs[fmt.Sprintf("k%03d", i)] = i
+	}
+	db.SetBatch("test", kvs)
+
+	// 一次提交删除的键在同一个协程中按删除的顺序回调
+	if n, err := db.DeleteByPrefix("test", []byte("k")); err != nil || n != 300 {
+		t.Fatalf("db.DeleteByPrefix() == %d, %v, want 300, nil", n, err)
+	}
+	for i, e := range waitEvents(t, ch, 300) {
+		if want := fmt.Sprintf("k%03d", i); e.key != want {
+			t.Fatalf("event %d key == %q, want %q", i, e.key, want)
+		}
+	}
+}
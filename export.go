@@ -0,0 +1,204 @@
+package bdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/boltdb/bolt"
+)
+
+// 导入时遇到已有的键如何处理
+type ConflictPolicy int
+
+const (
+	ConflictOverwrite ConflictPolicy = iota // 覆盖已有的键
+	ConflictSkip                            // 保留已有的键
+	ConflictError                           // 返回ErrConflict
+)
+
+// ConflictError策略下导入的键已存在
+var ErrConflict = errors.New("key already exists")
+
+// 二进制导出格式：
+//
+//	header: "BDBX" + 2字节大端版本号
+//	table:  'T' + uvarint名字长度 + 名字 + 8字节大端序列号
+//	entry:  'E' + uvarint键长度 + 键 + uvarint值长度 + 值
+//	end:    'Z'
+//
+// entry属于它前面最近的table，子表不导出
+var binaryMagic = []byte("BDBX")
+
+const binaryVersion = 1
+
+const (
+	recTable = 'T'
+	recEntry = 'E'
+	recEnd   = 'Z'
+)
+
+func (b *dbConnection) ExportBinary(w io.Writer) error {
+	ctx, cancel := b.opContext()
+	defer cancel()
+
+	bw := bufio.NewWriter(w)
+	bw.Write(binaryMagic)
+	binary.Write(bw, binary.BigEndian, uint16(binaryVersion))
+
+	var n [binary.MaxVarintLen64]byte
+	writeBytes := func(p []byte) {
+		bw.Write(n[:binary.PutUvarint(n[:], uint64(len(p)))])
+		bw.Write(p)
+	}
+
+	err := b.view(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			bw.WriteByte(recTable)
+			writeBytes(name)
+			binary.Write(bw, binary.BigEndian, bucket.Sequence())
+
+			return forEach(ctx, bucket, func(k, v []byte) error {
+				if v == nil {
+					return nil
+				}
+				bw.WriteByte(recEntry)
+				writeBytes(k)
+				writeBytes(v)
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteByte(recEnd)
+	// bufio.Writer会记住第一次写错误
+	return bw.Flush()
+}
+
+// 每张表按batchSize分批提交，序列号取现有值与导入值中较大的一个。
+// 出错时已提交的批次不会回滚
+func (b *dbConnection) ImportBinary(r io.Reader, policy ConflictPolicy) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(binaryMagic)+2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("read export header failed: %v", err)
+	}
+	if !bytes.Equal(header[:len(binaryMagic)], binaryMagic) {
+		return fmt.Errorf("not a bdb binary export")
+	}
+	if v := binary.BigEndian.Uint16(header[len(binaryMagic):]); v != binaryVersion {
+		return fmt.Errorf("unsupported binary export version %d, want %d", v, binaryVersion)
+	}
+
+	var tn string
+	var pending []KV
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := b.update(func(tx *bolt.Tx) error {
+			bucket, err := getBucket(tx, tn)
+			if err != nil {
+				return err
+			}
+			return importEntries(bucket, tn, pending, policy)
+		})
+		pending = pending[:0]
+		return err
+	}
+
+	for {
+		rec, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("unexpected end of export: %v", err)
+		}
+
+		switch rec {
+		case recTable:
+			if err = flush(); err != nil {
+				return err
+			}
+			name, err := readBytes(br, bolt.MaxKeySize)
+			if err != nil {
+				return err
+			}
+			var seq uint64
+			if err = binary.Read(br, binary.BigEndian, &seq); err != nil {
+				return fmt.Errorf("read sequence of %q failed: %v", name, err)
+			}
+			tn = string(name)
+			err = b.update(func(tx *bolt.Tx) error {
+				bucket, err := tx.CreateBucketIfNotExists(name)
+				if err != nil {
+					return fmt.Errorf("create bucket (%v) failed: %s", tn, err)
+				}
+				if seq > bucket.Sequence() {
+					return bucket.SetSequence(seq)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		case recEntry:
+			if tn == "" {
+				return fmt.Errorf("entry before any table in export")
+			}
+			k, err := readBytes(br, bolt.MaxKeySize)
+			if err != nil {
+				return err
+			}
+			v, err := readBytes(br, bolt.MaxValueSize)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, KV{Key: k, Value: v})
+			if len(pending) >= batchSize {
+				if err = flush(); err != nil {
+					return err
+				}
+			}
+		case recEnd:
+			return flush()
+		default:
+			return fmt.Errorf("invalid record type %q in export", rec)
+		}
+	}
+}
+
+// 按冲突策略写入一批键值
+func importEntries(bucket *bolt.Bucket, tn string, kvs []KV, policy ConflictPolicy) error {
+	for _, kv := range kvs {
+		if policy != ConflictOverwrite && bucket.Get(kv.Key) != nil {
+			if policy == ConflictSkip {
+				continue
+			}
+			return fmt.Errorf("import %v.%q failed: %w", tn, kv.Key, ErrConflict)
+		}
+		if err := bucket.Put(kv.Key, kv.Value); err != nil {
+			return fmt.Errorf("set %v.%q failed: %w", tn, kv.Key, err)
+		}
+	}
+	return nil
+}
+
+// 读取uvarint长度前缀的字节串
+func readBytes(r *bufio.Reader, max int) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("read length failed: %v", err)
+	}
+	if n > uint64(max) {
+		return nil, fmt.Errorf("length %d exceeds limit %d", n, max)
+	}
+	p := make([]byte, n)
+	if _, err = io.ReadFull(r, p); err != nil {
+		return nil, fmt.Errorf("read data failed: %v", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,83 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBinaryExportImport(t *testing.T) {
+	src := openTestDB(t, "users", "empty", "events")
+	src.Set("users", "alice", "1")
+	src.Set("users", "bob", []byte{0, 1, 2, 0xff})
+	for i := 0; i < batchSize+5; i++ {
+		src.Add("events", i)
+	}
+
+	var buf bytes.Buffer
+	if err := src.ExportBinary(&buf); err != nil {
+		t.Fatalf("src.ExportBinary() failed, err=%v", err)
+	}
+	data := buf.Bytes()
+
+	dst := openTestDB(t, "users")
+	dst.Set("users", "alice", "old")
+	dst.Set("users", "carol", "3")
+
+	// 保留已有的键
+	if err := dst.ImportBinary(bytes.NewReader(data), ConflictSkip); err != nil {
+		t.Fatalf("dst.ImportBinary(skip) failed, err=%v", err)
+	}
+	if got := string(dst.Get("users", "alice")); got != "old" {
+		t.Errorf("ConflictSkip: alice == %q, want %q", got, "old")
+	}
+	if got := dst.Get("users", "bob"); !bytes.Equal(got, []byte{0, 1, 2, 0xff}) {
+		t.Errorf("bob == %v, want binary value", got)
+	}
+	if got := string(dst.Get("users", "carol")); got != "3" {
+		t.Errorf("carol == %q, want %q", got, "3")
+	}
+	srcHash, _ := src.TableHash("events")
+	dstHash, _ := dst.TableHash("events")
+	if !bytes.Equal(srcHash, dstHash) {
+		t.Errorf("events table differs after import")
+	}
+	if _, err := dst.ListCollections("empty"); err != nil {
+		t.Errorf("empty table not imported, err=%v", err)
+	}
+	// 序列号也被导入
+	dst.Add("events", "next")
+	if got := string(dst.Get("events", batchSize+6)); got != "next" {
+		t.Errorf("dst.Add() after import got key %q, want sequence continued", got)
+	}
+
+	if err := dst.ImportBinary(bytes.NewReader(data), ConflictError); !errors.Is(err, ErrConflict) {
+		t.Errorf("dst.ImportBinary(error) err=%v, want ErrConflict", err)
+	}
+	if err := dst.ImportBinary(bytes.NewReader(data), ConflictOverwrite); err != nil {
+		t.Fatalf("dst.ImportBinary(overwrite) failed, err=%v", err)
+	}
+	if got := string(dst.Get("users", "alice")); got != "1" {
+		t.Errorf("ConflictOverwrite: alice == %q, want %q", got, "1")
+	}
+}
+
+func TestBinaryImportInvalid(t *testing.T) {
+	db := openTestDB(t)
+	var tests = [][]byte{
+		[]byte("JSON"),
+		[]byte("BDBX\x00\x02Z"),
+		[]byte("BDBX\x00\x01T\x01a"),
+		[]byte("BDBX\x00\x01E\x01a\x01b"),
+		[]byte("BDBX\x00\x01Q"),
+	}
+	for _, data := range tests {
+		if err := db.ImportBinary(bytes.NewReader(data), ConflictOverwrite); err == nil {
+			t.Errorf("db.ImportBinary(%q) should fail", data)
+		}
+	}
+
+	if err := db.ImportBinary(bytes.NewReader([]byte("BDBX\x00\x01Z")), ConflictOverwrite); err != nil {
+		t.Errorf("db.ImportBinary(empty export) failed, err=%v", err)
+	}
+}
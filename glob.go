@@ -0,0 +1,146 @@
+package bdb
+
+import (
+	"errors"
+)
+
+// 通配符语法错误
+var ErrBadPattern = errors.New("syntax error in pattern")
+
+// 按字节匹配通配符：*匹配任意长度(包括/)，?匹配一个字节，
+// [abc]、[a-z]、[^a-z]或[!a-z]匹配字符集，\转义下一个字符
+func globMatch(pattern string, s []byte) bool {
+	px, sx := 0, 0
+	starPx, starSx := -1, -1
+	for px < len(pattern) || sx < len(s) {
+		if px < len(pattern) {
+			switch c := pattern[px]; c {
+			case '*':
+				starPx, starSx = px, sx
+				px++
+				continue
+			case '?':
+				if sx < len(s) {
+					px++
+					sx++
+					continue
+				}
+			case '[':
+				if sx < len(s) {
+					if ok, n := matchClass(pattern[px:], s[sx]); ok {
+						px += n
+						sx++
+						continue
+					}
+				}
+			case '\\':
+				if sx < len(s) && s[sx] == pattern[px+1] {
+					px += 2
+					sx++
+					continue
+				}
+			default:
+				if sx < len(s) && s[sx] == c {
+					px++
+					sx++
+					continue
+				}
+			}
+		}
+		// 回溯到上一个*，让它多吃一个字节
+		if starPx >= 0 && starSx < len(s) {
+			starSx++
+			px, sx = starPx+1, starSx
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// 匹配以[开头的字符集，返回是否匹配以及字符集在pattern中的长度
+func matchClass(p string, c byte) (matched bool, width int) {
+	i := 1
+	negate := false
+	if i < len(p) && (p[i] == '^' || p[i] == '!') {
+		negate = true
+		i++
+	}
+	for first := true; p[i] != ']' || first; first = false {
+		lo := p[i]
+		if lo == '\\' {
+			i++
+			lo = p[i]
+		}
+		i++
+		hi := lo
+		if p[i] == '-' && p[i+1] != ']' {
+			hi = p[i+1]
+			if hi == '\\' {
+				i++
+				hi = p[i+1]
+			}
+			i += 2
+		}
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+	return matched != negate, i + 1
+}
+
+// 检查通配符语法，返回第一个通配符之前的字面前缀
+func globPrefix(pattern string) (prefix []byte, err error) {
+	literal := true
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '\\':
+			if i+1 >= len(pattern) {
+				return nil, ErrBadPattern
+			}
+			i++
+			if literal {
+				prefix = append(prefix, pattern[i])
+			}
+		case '[':
+			n := classWidth(pattern[i:])
+			if n < 0 {
+				return nil, ErrBadPattern
+			}
+			i += n - 1
+			literal = false
+		case '*', '?':
+			literal = false
+		default:
+			if literal {
+				prefix = append(prefix, c)
+			}
+		}
+	}
+	return prefix, nil
+}
+
+// 字符集的长度，语法错误时返回-1
+func classWidth(p string) int {
+	i := 1
+	if i < len(p) && (p[i] == '^' || p[i] == '!') {
+		i++
+	}
+	for first := true; i < len(p); first = false {
+		if p[i] == ']' && !first {
+			return i + 1
+		}
+		if p[i] == '\\' {
+			i++
+		}
+		i++
+		if i+1 < len(p) && p[i] == '-' && p[i+1] != ']' {
+			i++
+			if p[i] == '\\' {
+				i++
+			}
+			i++
+		}
+	}
+	return -1
+}
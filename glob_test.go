@@ -0,0 +1,102 @@
+package bdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"user:*", "user:1", true},
+		{"user:*", "user:", true},
+		{"user:*", "users:1", false},
+		{"*", "a/b/c", true},
+		{"a*c", "a/b/c", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"?x", "ax", true},
+		{"?x", "x", false},
+		{"[abc]1", "b1", true},
+		{"[abc]1", "d1", false},
+		{"[a-c]1", "c1", true},
+		{"[^a-c]1", "c1", false},
+		{"[!a-c]1", "d1", true},
+		{"[]]", "]", true},
+		{"[a-]", "-", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, test := range tests {
+		if _, err := globPrefix(test.pattern); err != nil {
+			t.Errorf("globPrefix(%q) err=%v", test.pattern, err)
+			continue
+		}
+		if got := globMatch(test.pattern, []byte(test.key)); got != test.want {
+			t.Errorf("globMatch(%q, %q) == %v, want %v", test.pattern, test.key, got, test.want)
+		}
+	}
+}
+
+func TestGlobPrefix(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		want    string
+		bad     bool
+	}{
+		{"user:*", "user:", false},
+		{"user:[0-9]*", "user:", false},
+		{`a\*b*`, "a*b", false},
+		{"abc", "abc", false},
+		{"*abc", "", false},
+		{"[abc", "", true},
+		{`abc\`, "", true},
+	}
+	for _, test := range tests {
+		got, err := globPrefix(test.pattern)
+		if (err != nil) != test.bad {
+			t.Errorf("globPrefix(%q) err=%v, want bad=%v", test.pattern, err, test.bad)
+			continue
+		}
+		if !test.bad && string(got) != test.want {
+			t.Errorf("globPrefix(%q) == %q, want %q", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"order:1", "user:1", "user:2", "user:20", "user:x"} {
+		db.Set("test", k, "v")
+	}
+
+	var got []string
+	err := db.Match("test", "user:[0-9]*", func(k, v []byte) bool {
+		got = append(got, string(k))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("db.Match() failed, err=%v", err)
+	}
+	if fmt.Sprint(got) != "[user:1 user:2 user:20]" {
+		t.Errorf("db.Match() == %v", got)
+	}
+
+	got = got[:0]
+	db.Match("test", "*:1", func(k, v []byte) bool {
+		got = append(got, string(k))
+		return len(got) < 1
+	})
+	if fmt.Sprint(got) != "[order:1]" {
+		t.Errorf("db.Match() with early stop == %v", got)
+	}
+
+	if err := db.Match("test", "[bad", func(k, v []byte) bool { return true }); err != ErrBadPattern {
+		t.Errorf("db.Match(bad pattern) err=%v, want ErrBadPattern", err)
+	}
+}
@@ -0,0 +1,98 @@
+package bdb
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// 绑定在某个事务中的表，只在Update、View的fn执行期间有效
+type Table interface {
+	Name() string                        // 表名
+	Set(key, value interface{}) error    // 设置键值
+	Get(key interface{}) ([]byte, error) // 获取键值的拷贝，不存在时返回ErrKeyNotFound
+	Delete(key interface{}) error        // 删除键
+	Cursor() Iterator                    // 表的迭代器，随事务结束，Close可以不调用
+}
+
+type table struct {
+	b      *dbConnection
+	tx     *bolt.Tx
+	bucket *bolt.Bucket
+	name   string
+}
+
+// 在一个写事务中对表tn执行fn，fn返回错误时回滚。AutoCreateTables时表不存在则创建
+func (b *dbConnection) Update(tn string, fn func(t Table) error) error {
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		return fn(&table{b: b, tx: tx, bucket: bucket, name: tn})
+	})
+}
+
+// 在一个只读事务中对表tn执行fn，其中的写操作返回错误
+func (b *dbConnection) View(tn string, fn func(t Table) error) error {
+	return b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		return fn(&table{b: b, tx: tx, bucket: bucket, name: tn})
+	})
+}
+
+func (t *table) Name() string {
+	return t.name
+}
+
+func (t *table) Set(key, value interface{}) error {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	v, err := dataToBytes(value)
+	if err != nil {
+		return fmt.Errorf("invalid value:%v", err)
+	}
+	if err = debugCheck(key, value, k, v); err != nil {
+		return err
+	}
+
+	stored, err := t.b.putValue(t.tx, t.bucket, t.name, k, v)
+	if err != nil {
+		return fmt.Errorf("set %v.%v failed: %w", t.name, k, err)
+	}
+	return t.b.mirrorWrite(t.tx, func(m BoltDB) error { return m.Set(t.name, k, stored) })
+}
+
+func (t *table) Get(key interface{}) ([]byte, error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+	v := liveValue(t.tx, t.bucket, t.name, k)
+	if v == nil {
+		return nil, ErrKeyNotFound
+	}
+	return append([]byte{}, v...), nil
+}
+
+func (t *table) Delete(key interface{}) error {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	if err = t.b.del(t.tx, t.bucket, t.name, k); err != nil {
+		return fmt.Errorf("delete %v.%v failed: %w", t.name, k, err)
+	}
+	return t.b.mirrorWrite(t.tx, func(m BoltDB) error { return m.Delete(t.name, k) })
+}
+
+func (t *table) Cursor() Iterator {
+	it := &iterator{c: t.bucket.Cursor()}
+	it.First()
+	return it
+}
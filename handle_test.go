@@ -0,0 +1,62 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestTableUpdateView(t *testing.T) {
+	db := openTestDB(t, "counters")
+	db.Set("counters", "a", 1)
+
+	// 读-改-写在同一个事务中完成
+	err := db.Update("counters", func(tb Table) error {
+		v, err := tb.Get("a")
+		if err != nil {
+			return err
+		}
+		n, _ := strconv.Atoi(string(v))
+		if err := tb.Set("a", n+1); err != nil {
+			return err
+		}
+		return tb.Set("b", 10)
+	})
+	if err != nil {
+		t.Fatalf("db.Update() failed, err=%v", err)
+	}
+
+	stop := errors.New("stop")
+	err = db.Update("counters", func(tb Table) error {
+		tb.Delete("a")
+		return stop
+	})
+	if err != stop {
+		t.Errorf("db.Update() err=%v, want stop", err)
+	}
+
+	var got []string
+	err = db.View("counters", func(tb Table) error {
+		if tb.Name() != "counters" {
+			t.Errorf("tb.Name() == %q", tb.Name())
+		}
+		it := tb.Cursor()
+		defer it.Close()
+		for ; it.Valid(); it.Next() {
+			got = append(got, string(it.Key())+"="+string(it.Value()))
+		}
+		if err := tb.Set("c", 1); !errors.Is(err, bolt.ErrTxNotWritable) {
+			t.Errorf("tb.Set() in View err=%v, want ErrTxNotWritable", err)
+		}
+		return nil
+	})
+	if err != nil || fmt.Sprint(got) != "[a=2 b=10]" {
+		t.Errorf("db.View() == %v, %v, want [a=2 b=10]", got, err)
+	}
+	if err := db.View("missing", func(Table) error { return nil }); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.View(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
@@ -0,0 +1,129 @@
+package bdb
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// 哈希存放在键的子表中，每个字段一条记录，修改一个字段不需要重写整条记录
+func (b *dbConnection) HSet(tn string, key, field, value interface{}) error {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	f, err := keyToBytes(field)
+	if err != nil {
+		return fmt.Errorf("invalid field:%w", err)
+	}
+	v, err := dataToBytes(value)
+	if err != nil {
+		return fmt.Errorf("invalid value:%v", err)
+	}
+
+	return b.update(func(tx *bolt.Tx) error {
+		hash, err := b.keyBucket(tx, tn, k, kindHash, true)
+		if err != nil {
+			return err
+		}
+		if err := hash.Put(f, v); err != nil {
+			return fmt.Errorf("set %v.%q field %q failed: %w", tn, k, f, err)
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.HSet(tn, k, f, v) })
+	})
+}
+
+// 字段或键不存在时返回ErrKeyNotFound
+func (b *dbConnection) HGet(tn string, key, field interface{}) (value []byte, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+	f, err := keyToBytes(field)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		hash, err := b.keyBucket(tx, tn, k, kindHash, false)
+		if err != nil {
+			return err
+		}
+		var v []byte
+		if hash != nil {
+			v = hash.Get(f)
+		}
+		if v == nil {
+			return ErrKeyNotFound
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, err
+}
+
+// 返回删除的字段数，哈希空了时连同键一起删除
+func (b *dbConnection) HDel(tn string, key interface{}, fields ...interface{}) (deleted int, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
+	fs, err := encodeMembers(fields)
+	if err != nil {
+		return 0, err
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		deleted = 0
+		hash, err := b.keyBucket(tx, tn, k, kindHash, false)
+		if err != nil || hash == nil {
+			return err
+		}
+		for _, f := range fs {
+			if hash.Get(f) == nil {
+				continue
+			}
+			if err := hash.Delete(f); err != nil {
+				return fmt.Errorf("delete %v.%q field %q failed: %w", tn, k, f, err)
+			}
+			deleted++
+		}
+		if err := hash.dropIfEmpty(); err != nil {
+			return err
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error {
+			_, err := m.HDel(tn, k, fields...)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// 所有字段及值，键不存在时返回空map
+func (b *dbConnection) HGetAll(tn string, key interface{}) (map[string][]byte, error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+
+	ret := map[string][]byte{}
+	err = b.view(func(tx *bolt.Tx) error {
+		hash, err := b.keyBucket(tx, tn, k, kindHash, false)
+		if err != nil || hash == nil {
+			return err
+		}
+		return hash.ForEach(func(f, v []byte) error {
+			if v != nil {
+				ret[string(f)] = append([]byte{}, v...)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,43 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	db := openTestDB(t, "users")
+	db.HSet("users", "u1", "name", "alice")
+	db.HSet("users", "u1", "age", 30)
+	db.HSet("users", "u1", "age", 31)
+
+	if v, err := db.HGet("users", "u1", "age"); err != nil || string(v) != "31" {
+		t.Errorf("db.HGet(age) == %q, %v, want 31", v, err)
+	}
+	if _, err := db.HGet("users", "u1", "email"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.HGet(missing field) err=%v, want ErrKeyNotFound", err)
+	}
+	if _, err := db.HGet("users", "u2", "name"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.HGet(missing key) err=%v, want ErrKeyNotFound", err)
+	}
+	all, err := db.HGetAll("users", "u1")
+	if err != nil || len(all) != 2 || string(all["name"]) != "alice" {
+		t.Errorf("db.HGetAll() == %q, %v, want name and age", all, err)
+	}
+
+	if n, err := db.HDel("users", "u1", "age", "missing"); err != nil || n != 1 {
+		t.Errorf("db.HDel() == %d, %v, want 1, nil", n, err)
+	}
+	db.HDel("users", "u1", "name")
+	if all, err := db.HGetAll("users", "u1"); err != nil || len(all) != 0 {
+		t.Errorf("db.HGetAll() after HDel == %q, %v, want empty", all, err)
+	}
+	if names, _ := db.ListCollections("users"); len(names) != 0 {
+		t.Errorf("empty hash not dropped, collections %v", names)
+	}
+
+	db.Set("users", "plain", "v")
+	if err := db.HSet("users", "plain", "f", "v"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("db.HSet(plain key) err=%v, want ErrWrongType", err)
+	}
+}
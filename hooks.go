@@ -0,0 +1,153 @@
+package bdb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// 钩子的位置
+type HookPoint int
+
+const (
+	BeforeSet    HookPoint = iota // 写入前，可修改Value或返回错误拒绝写入
+	AfterSet                      // 写入的事务提交后
+	BeforeDelete                  // 删除前，返回错误拒绝删除
+	AfterDelete                   // 删除的事务提交后
+)
+
+// 钩子看到的一次写入，删除时Value为nil
+type WriteOp struct {
+	Table string
+	Key   []byte
+	Value []byte
+}
+
+// Before钩子返回错误时写入失败，事务回滚；After钩子的错误只记录日志
+type HookFunc func(op *WriteOp) error
+
+type hooks struct {
+	mu      sync.RWMutex
+	fns     [4][]HookFunc
+	pending map[*bolt.Tx][]func() // 已提交、等待释放锁后调用的After钩子
+	queue   []func()              // 写缓冲提交后等待后台调用的After钩子
+	running bool                  // 是否有协程在调用queue
+}
+
+// 注册钩子，对所有表的单条写入和删除生效，按注册的顺序调用。
+// Before钩子在写事务中调用，不能再操作数据库；After钩子在写操作释放数据库后、返回前调用，
+// 可以操作数据库，写缓冲中的写入的After钩子在写入后由后台协程按顺序调用。
+// 镜像写入的是经过Before钩子修改后的值；删除不存在的键不调用删除的钩子，过期清理也不调用
+func (b *dbConnection) RegisterHook(point HookPoint, fn HookFunc) {
+	if point < BeforeSet || point > AfterDelete {
+		return
+	}
+	b.hooks.mu.Lock()
+	b.hooks.fns[point] = append(b.hooks.fns[point], fn)
+	b.hooks.mu.Unlock()
+}
+
+func (h *hooks) of(point HookPoint) []HookFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.fns[point]
+}
+
+// 依次调用Before钩子，返回可能被修改的值
+func (b *dbConnection) before(point HookPoint, tn string, k, v []byte) ([]byte, error) {
+	fns := b.hooks.of(point)
+	if len(fns) == 0 {
+		return v, nil
+	}
+	op := &WriteOp{Table: tn, Key: append([]byte{}, k...), Value: v}
+	for _, fn := range fns {
+		if err := fn(op); err != nil {
+			return nil, fmt.Errorf("rejected by hook: %w", err)
+		}
+	}
+	if point == BeforeDelete {
+		return nil, nil
+	}
+	if op.Value == nil {
+		op.Value = []byte{}
+	}
+	return op.Value, nil
+}
+
+// 事务提交后依次调用After钩子。提交时只是记下，由commit在释放lifecycle锁后调用runAfter
+func (b *dbConnection) after(tx *bolt.Tx, point HookPoint, tn string, k, v []byte) {
+	fns := b.hooks.of(point)
+	if len(fns) == 0 {
+		return
+	}
+	op := &WriteOp{Table: tn, Key: append([]byte{}, k...)}
+	if v != nil {
+		op.Value = append([]byte{}, v...)
+	}
+	run := func() {
+		for _, fn := range fns {
+			if err := fn(op); err != nil {
+				b.log().Error("bdb: after hook failed", "table", tn, "key", op.Key, "err", err)
+			}
+		}
+	}
+	tx.OnCommit(func() {
+		b.hooks.mu.Lock()
+		defer b.hooks.mu.Unlock()
+		if b.hooks.pending == nil {
+			b.hooks.pending = make(map[*bolt.Tx][]func())
+		}
+		b.hooks.pending[tx] = append(b.hooks.pending[tx], run)
+	})
+}
+
+// 取出tx提交时记下的After钩子
+func (h *hooks) take(tx *bolt.Tx) []func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fns := h.pending[tx]
+	delete(h.pending, tx)
+	return fns
+}
+
+// 调用txs提交时记下的After钩子，调用方不能持有lifecycle锁
+func (b *dbConnection) runAfter(txs ...*bolt.Tx) {
+	for _, tx := range txs {
+		for _, fn := range b.hooks.take(tx) {
+			fn()
+		}
+	}
+}
+
+// 写缓冲在持有lifecycle锁时提交，它的After钩子交给后台协程按提交的顺序调用
+func (b *dbConnection) runAfterLater(tx *bolt.Tx) {
+	fns := b.hooks.take(tx)
+	if len(fns) == 0 {
+		return
+	}
+	h := &b.hooks
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queue = append(h.queue, fns...)
+	if h.running {
+		return
+	}
+	h.running = true
+	go func() {
+		for {
+			h.mu.Lock()
+			fns := h.queue
+			h.queue = nil
+			if len(fns) == 0 {
+				h.running = false
+				h.mu.Unlock()
+				return
+			}
+			h.mu.Unlock()
+			for _, fn := range fns {
+				fn()
+			}
+		}
+	}()
+}
@@ -0,0 +1,149 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegisterHook(t *testing.T) {
+	db := openTestDB(t, "test")
+	errReadOnlyKey := errors.New("read only key")
+
+	db.RegisterHook(BeforeSet, func(op *WriteOp) error {
+		if bytes.HasPrefix(op.Key, []byte("ro:")) {
+			return errReadOnlyKey
+		}
+		op.Value = bytes.ToUpper(op.Value)
+		return nil
+	})
+	db.RegisterHook(BeforeDelete, func(op *WriteOp) error {
+		if string(op.Key) == "keep" {
+			return errReadOnlyKey
+		}
+		return nil
+	})
+	var log []string
+	db.RegisterHook(AfterSet, func(op *WriteOp) error {
+		log = append(log, "set "+string(op.Key)+"="+string(op.Value))
+		return nil
+	})
+	db.RegisterHook(AfterDelete, func(op *WriteOp) error {
+		log = append(log, "delete "+string(op.Key))
+		return nil
+	})
+
+	if err := db.Set("test", "k", "abc"); err != nil {
+		t.Fatalf("db.Set() failed, err=%v", err)
+	}
+	if got := string(db.Get("test", "k")); got != "ABC" {
+		t.Errorf("db.Get(k) == %q, want %q", got, "ABC")
+	}
+	if err := db.Set("test", "ro:1", "v"); !errors.Is(err, errReadOnlyKey) {
+		t.Errorf("db.Set(ro:1) err=%v, want errReadOnlyKey", err)
+	}
+	if ok, _ := db.Has("test", "ro:1"); ok {
+		t.Errorf("rejected key ro:1 was written")
+	}
+
+	db.Set("test", "keep", "v")
+	if err := db.Delete("test", "keep"); !errors.Is(err, errReadOnlyKey) {
+		t.Errorf("db.Delete(keep) err=%v, want errReadOnlyKey", err)
+	}
+	db.Delete("test", "k")
+
+	want := []string{"set k=ABC", "set keep=V", "delete k"}
+	if len(log) != len(want) {
+		t.Fatalf("after hooks == %q, want %q", log, want)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Errorf("after hooks[%d] == %q, want %q", i, log[i], want[i])
+		}
+	}
+
+	// 事务中的写入被拒绝时整个事务回滚
+	err := db.Update("test", func(tb Table) error {
+		if err := tb.Set("a", "1"); err != nil {
+			return err
+		}
+		return tb.Set("ro:2", "2")
+	})
+	if !errors.Is(err, errReadOnlyKey) {
+		t.Errorf("db.Update() err=%v, want errReadOnlyKey", err)
+	}
+	if ok, _ := db.Has("test", "a"); ok {
+		t.Errorf("key a written by a rolled back transaction")
+	}
+}
+
+func TestHookMirrorAndMissingDelete(t *testing.T) {
+	db := openTestDB(t, "test")
+	standby := openTestDB(t, "test")
+	db.SetMirror(standby)
+	db.RegisterHook(BeforeSet, func(op *WriteOp) error {
+		op.Value = bytes.ToUpper(op.Value)
+		return nil
+	})
+	var deletes []string
+	db.RegisterHook(BeforeDelete, func(op *WriteOp) error {
+		deletes = append(deletes, "before "+string(op.Key))
+		return nil
+	})
+	db.RegisterHook(AfterDelete, func(op *WriteOp) error {
+		deletes = append(deletes, "after "+string(op.Key))
+		return nil
+	})
+
+	// 镜像写入钩子修改后的值
+	db.Set("test", "k", "abc")
+	db.Add("test", "added")
+	if got := string(standby.Get("test", "k")); got != "ABC" {
+		t.Errorf("standby.Get(k) == %q, want %q", got, "ABC")
+	}
+	if got := string(standby.Get("test", 1)); got != "ADDED" {
+		t.Errorf("standby.Get(1) == %q, want %q", got, "ADDED")
+	}
+
+	db.Delete("test", "missing")
+	db.Delete("test", "k")
+	want := []string{"before k", "after k"}
+	if len(deletes) != len(want) || deletes[0] != want[0] || deletes[1] != want[1] {
+		t.Errorf("delete hooks == %q, want %q", deletes, want)
+	}
+}
+
+func TestAfterHookDuringClose(t *testing.T) {
+	db := openTestDB(t, "test")
+	closing := make(chan struct{})
+	got := make(chan error, 1)
+	db.RegisterHook(AfterSet, func(op *WriteOp) error {
+		if string(op.Key) != "k" {
+			return nil
+		}
+		close(closing)
+		time.Sleep(50 * time.Millisecond)
+		// Close已经在等待，After钩子中调用db不能死锁
+		_, err := db.GetValue("test", "k")
+		got <- err
+		return nil
+	})
+
+	closed := make(chan struct{})
+	go func() {
+		<-closing
+		db.Close()
+		close(closed)
+	}()
+	go db.Set("test", "k", "v")
+	select {
+	case err := <-got:
+		if err != nil && !errors.Is(err, ErrClosed) {
+			t.Errorf("db.GetValue() in after hook err=%v, want nil or ErrClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("db call in an after hook deadlocked with Close")
+	}
+	<-closed
+}
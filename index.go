@@ -0,0 +1,312 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// 存放索引数据的顶层表，表tn的索引name在其下的子表tn中的子表name里，
+// tn整个作为一层的名字，不按路径拆分
+const indexTable = "__bdb_index"
+
+// 由记录的键值计算索引值，返回的每个值按键的规则编码，nil表示该记录不进索引
+type IndexFunc func(k, v []byte) ([]interface{}, error)
+
+// 唯一索引中的值已被其它键占用
+var ErrDuplicate = errors.New("duplicate index value")
+
+type index struct {
+	name   string
+	fn     IndexFunc
+	unique bool
+	field  reflect.Type // CreateIndexes按字段声明时该字段的类型，Query据此判断能否经由索引查找
+}
+
+// 连接上声明的索引。索引函数无法持久化，每次打开后需要重新声明
+type indexes struct {
+	mu      sync.RWMutex
+	byTable map[string][]*index
+}
+
+func (ix *indexes) of(tn string) []*index {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.byTable[tn]
+}
+
+// 把表tn的索引name设为idx，idx为nil时删除，返回原来的索引
+func (ix *indexes) set(tn, name string, idx *index) (prev *index) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.byTable == nil {
+		ix.byTable = make(map[string][]*index)
+	}
+	list := make([]*index, 0, len(ix.byTable[tn])+1)
+	for _, old := range ix.byTable[tn] {
+		if old.name == name {
+			prev = old
+			continue
+		}
+		list = append(list, old)
+	}
+	if idx != nil {
+		list = append(list, idx)
+	}
+	ix.byTable[tn] = list
+	return prev
+}
+
+// 把oldName及其子表的索引声明改到newName下，newName原有的声明被替换
+func (ix *indexes) rename(oldName, newName string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for tn, list := range ix.byTable {
+		if tn == oldName || strings.HasPrefix(tn, oldName+TableSeparator) {
+			delete(ix.byTable, tn)
+			ix.byTable[newName+tn[len(oldName):]] = list
+		}
+	}
+}
+
+// 为表tn声明索引name，并在一个写事务中用现有数据重建索引。同名索引会被替换。
+// 之后经由本连接的写入(Set、Delete、Add、SetBatch、Txn等)都会同步维护索引；
+// RenameTable、ImportBinary这类整表操作不维护索引
+func (b *dbConnection) CreateIndex(tn, name string, fn IndexFunc) error {
+	return b.createIndex(tn, &index{name: name, fn: fn})
+}
+
+// 同CreateIndex，但一个索引值只能对应一条记录，写入时在同一个写事务中检查，
+// 冲突时写入失败并返回ErrDuplicate；现有数据已有重复时声明失败
+func (b *dbConnection) CreateUniqueIndex(tn, name string, fn IndexFunc) error {
+	return b.createIndex(tn, &index{name: name, fn: fn, unique: true})
+}
+
+func (b *dbConnection) createIndex(tn string, idx *index) error {
+	name := idx.name
+	if name == "" {
+		return fmt.Errorf("invalid index name (%v)", name)
+	}
+	var prev *index
+	err := b.update(func(tx *bolt.Tx) error {
+		// 在写事务内注册，之后的写入都会维护索引；失败时恢复原来的声明
+		prev = b.indexes.set(tn, name, idx)
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		ib, err := resetIndexBucket(tx, tn, name)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			return addIndexEntries(ib, idx, tn, k, v)
+		})
+	})
+	if err != nil {
+		b.indexes.set(tn, name, prev)
+	}
+	return err
+}
+
+// 为sample结构体中每个bdb:"index"字段声明一个以字段名命名的索引，bdb:"unique"字段声明唯一索引，
+// 记录用表的编解码方式解码成sample的类型后取字段值
+func (b *dbConnection) CreateIndexes(tn string, sample interface{}) error {
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return fmt.Errorf("invalid sample %v", sample)
+	}
+	m, err := modelOf(t)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range m.indexes {
+		field := i.field
+		fn := func(k, v []byte) ([]interface{}, error) {
+			obj := reflect.New(t)
+			if err := b.codec(tn).Unmarshal(v, obj.Interface()); err != nil {
+				return nil, err
+			}
+			key, err := fieldKey(obj.Elem().Field(field))
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{key}, nil
+		}
+		if err := b.createIndex(tn, &index{name: t.Field(field).Name, fn: fn, unique: i.unique, field: t.Field(field).Type}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 返回索引name中值为value的所有记录，按主键排序
+func (b *dbConnection) GetByIndex(tn, name string, value interface{}) (kvs []KV, err error) {
+	iv, err := keyToBytes(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index value:%w", err)
+	}
+
+	declared := false
+	for _, idx := range b.indexes.of(tn) {
+		declared = declared || idx.name == name
+	}
+	if !declared {
+		return nil, fmt.Errorf("index (%v) of table (%v) not exists", name, tn)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		kvs = []KV{}
+		ib := indexBucket(tx, tn, name)
+		if ib == nil {
+			// 表被清空或删除后还没有写入过
+			return nil
+		}
+		prefix := indexPrefix(iv)
+		c := ib.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			pk := k[len(prefix):]
+			if v := bucket.Get(pk); v != nil {
+				kvs = append(kvs, copyKV(pk, v))
+			}
+		}
+		return nil
+	})
+	return kvs, err
+}
+
+// 写入记录前调用，把索引从旧值old更新为新值v；v为nil表示删除
+func (b *dbConnection) updateIndexes(tx *bolt.Tx, tn string, k, old, v []byte) error {
+	for _, idx := range b.indexes.of(tn) {
+		ib := indexBucket(tx, tn, idx.name)
+		if ib == nil {
+			var err error
+			if ib, err = resetIndexBucket(tx, tn, idx.name); err != nil {
+				return err
+			}
+		}
+		if old != nil {
+			if err := removeIndexEntries(ib, idx, tn, k, old); err != nil {
+				return err
+			}
+		}
+		if v != nil {
+			if err := addIndexEntries(ib, idx, tn, k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 删除表tn的全部索引数据，索引声明保留
+func dropIndexes(tx *bolt.Tx, tn string) error {
+	root := tx.Bucket([]byte(indexTable))
+	if root == nil || root.Bucket([]byte(tn)) == nil {
+		return nil
+	}
+	return root.DeleteBucket([]byte(tn))
+}
+
+func indexBucket(tx *bolt.Tx, tn, name string) *bolt.Bucket {
+	root := tx.Bucket([]byte(indexTable))
+	if root == nil {
+		return nil
+	}
+	if tb := root.Bucket([]byte(tn)); tb != nil {
+		return tb.Bucket([]byte(name))
+	}
+	return nil
+}
+
+// 清空并返回索引的子表，不存在时创建
+func resetIndexBucket(tx *bolt.Tx, tn, name string) (*bolt.Bucket, error) {
+	root, err := tx.CreateBucketIfNotExists([]byte(indexTable))
+	if err != nil {
+		return nil, err
+	}
+	tb, err := root.CreateBucketIfNotExists([]byte(tn))
+	if err != nil {
+		return nil, err
+	}
+	if tb.Bucket([]byte(name)) != nil {
+		if err := tb.DeleteBucket([]byte(name)); err != nil {
+			return nil, err
+		}
+	}
+	return tb.CreateBucket([]byte(name))
+}
+
+// 索引项的键为uvarint(len(iv)) + iv + 主键，值为空
+func indexPrefix(iv []byte) []byte {
+	var n [binary.MaxVarintLen64]byte
+	return append(n[:binary.PutUvarint(n[:], uint64(len(iv)))], iv...)
+}
+
+func indexValues(idx *index, tn string, k, v []byte) ([][]byte, error) {
+	values, err := idx.fn(k, v)
+	if err != nil {
+		return nil, fmt.Errorf("index (%v) of %v.%q failed: %w", idx.name, tn, k, err)
+	}
+	ivs := make([][]byte, 0, len(values))
+	for _, value := range values {
+		iv, err := keyToBytes(value)
+		if err != nil {
+			return nil, fmt.Errorf("index (%v) of %v.%q failed: invalid index value:%w", idx.name, tn, k, err)
+		}
+		ivs = append(ivs, iv)
+	}
+	return ivs, nil
+}
+
+func addIndexEntries(ib *bolt.Bucket, idx *index, tn string, k, v []byte) error {
+	ivs, err := indexValues(idx, tn, k, v)
+	if err != nil {
+		return err
+	}
+	for _, iv := range ivs {
+		prefix := indexPrefix(iv)
+		if idx.unique {
+			c := ib.Cursor()
+			for ik, _ := c.Seek(prefix); ik != nil && bytes.HasPrefix(ik, prefix); ik, _ = c.Next() {
+				if !bytes.Equal(ik[len(prefix):], k) {
+					return fmt.Errorf("index (%v) of %v.%q: value %q held by %q:%w", idx.name, tn, k, iv, ik[len(prefix):], ErrDuplicate)
+				}
+			}
+		}
+		if err := ib.Put(append(prefix, k...), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeIndexEntries(ib *bolt.Bucket, idx *index, tn string, k, old []byte) error {
+	ivs, err := indexValues(idx, tn, k, old)
+	if err != nil {
+		return err
+	}
+	for _, iv := range ivs {
+		if err := ib.Delete(append(indexPrefix(iv), k...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func keysOf(kvs []KV) string {
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	return fmt.Sprint(keys)
+}
+
+func TestCreateIndex(t *testing.T) {
+	db := openTestDB(t, "users")
+	db.Set("users", "u1", "red,blue")
+	db.Set("users", "u2", "blue")
+
+	// 值是逗号分隔的颜色列表，每种颜色一个索引值
+	colors := func(k, v []byte) ([]interface{}, error) {
+		var ret []interface{}
+		for _, c := range bytes.Split(v, []byte(",")) {
+			if len(c) > 0 {
+				ret = append(ret, string(c))
+			}
+		}
+		return ret, nil
+	}
+	if err := db.CreateIndex("users", "color", colors); err != nil {
+		t.Fatalf("db.CreateIndex() failed, err=%v", err)
+	}
+
+	check := func(color, want string) {
+		t.Helper()
+		kvs, err := db.GetByIndex("users", "color", color)
+		if err != nil || keysOf(kvs) != want {
+			t.Errorf("db.GetByIndex(%v) == %v, %v, want %v", color, keysOf(kvs), err, want)
+		}
+	}
+	check("blue", "[u1 u2]")
+	check("red", "[u1]")
+
+	db.Set("users", "u2", "green")
+	db.Add("users", "red")
+	db.Delete("users", "u1")
+	check("blue", "[]")
+	check("green", "[u2]")
+	check("red", "[1]")
+
+	txn, _ := db.Begin(true)
+	txn.Set("users", "u3", "green")
+	txn.Commit()
+	db.SetBatch("users", map[interface{}]interface{}{"u4": "green"})
+	check("green", "[u2 u3 u4]")
+	db.DeleteByPrefix("users", []byte("u"))
+	check("green", "[]")
+
+	if _, err := db.GetByIndex("users", "missing", "x"); err == nil {
+		t.Errorf("db.GetByIndex(missing index) succeeded")
+	}
+	db.Set("users", "u5", "red")
+	db.TruncateTable("users")
+	check("red", "[]")
+	db.Set("users", "u6", "red")
+	check("red", "[u6]")
+
+	if names, _ := db.ListTables(); fmt.Sprint(names) != "[users]" {
+		t.Errorf("db.ListTables() == %v, want [users]", names)
+	}
+
+	// 索引函数出错时写入失败
+	bad := func(k, v []byte) ([]interface{}, error) {
+		if strings.HasPrefix(string(v), "bad") {
+			return nil, fmt.Errorf("bad value")
+		}
+		return nil, nil
+	}
+	db.CreateIndex("users", "bad", bad)
+	if err := db.Set("users", "u7", "bad"); err == nil {
+		t.Errorf("db.Set() with failing index succeeded")
+	}
+	if db.Get("users", "u7") != nil {
+		t.Errorf("db.Get(u7) after failed index != nil")
+	}
+}
+
+type member struct {
+	ID    string `bdb:"key"`
+	Email string `bdb:"index"`
+	Team  string `bdb:"index"`
+}
+
+func TestCreateIndexes(t *testing.T) {
+	db := openTestDB(t, "members")
+	db.Save("members", member{ID: "1", Email: "a@x", Team: "core"})
+	if err := db.CreateIndexes("members", &member{}); err != nil {
+		t.Fatalf("db.CreateIndexes() failed, err=%v", err)
+	}
+	db.Save("members", member{ID: "2", Email: "b@x", Team: "core"})
+
+	if kvs, err := db.GetByIndex("members", "Team", "core"); err != nil || keysOf(kvs) != "[1 2]" {
+		t.Errorf("db.GetByIndex(Team, core) == %v, %v, want [1 2]", keysOf(kvs), err)
+	}
+	kvs, err := db.GetByIndex("members", "Email", "b@x")
+	if err != nil || keysOf(kvs) != "[2]" {
+		t.Fatalf("db.GetByIndex(Email, b@x) == %v, %v, want [2]", keysOf(kvs), err)
+	}
+	var m member
+	if err := JSONCodec.Unmarshal(kvs[0].Value, &m); err != nil || m.Team != "core" {
+		t.Errorf("decoded member == %+v, %v", m, err)
+	}
+}
+
+type login struct {
+	ID    string `bdb:"key"`
+	Email string `bdb:"unique"`
+}
+
+func TestUniqueIndex(t *testing.T) {
+	db := openTestDB(t, "logins")
+	if err := db.CreateIndexes("logins", login{}); err != nil {
+		t.Fatalf("db.CreateIndexes() failed, err=%v", err)
+	}
+
+	if err := db.Save("logins", login{ID: "1", Email: "a@x"}); err != nil {
+		t.Fatalf("db.Save(1) failed, err=%v", err)
+	}
+	// 同一条记录重写相同的值不算冲突
+	if err := db.Save("logins", login{ID: "1", Email: "a@x"}); err != nil {
+		t.Errorf("db.Save(1) again failed, err=%v", err)
+	}
+	if err := db.Save("logins", login{ID: "2", Email: "a@x"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("db.Save(2, a@x) err=%v, want ErrDuplicate", err)
+	}
+	if db.Get("logins", "2") != nil {
+		t.Errorf("duplicate record was written")
+	}
+	// 1改了邮箱后a@x可以被2使用
+	db.Save("logins", login{ID: "1", Email: "b@x"})
+	if err := db.Save("logins", login{ID: "2", Email: "a@x"}); err != nil {
+		t.Errorf("db.Save(2, a@x) after release failed, err=%v", err)
+	}
+
+	db.CreateTable("dups")
+	db.Set("dups", "1", "same")
+	db.Set("dups", "2", "same")
+	value := func(k, v []byte) ([]interface{}, error) { return []interface{}{v}, nil }
+	if err := db.CreateUniqueIndex("dups", "v", value); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("db.CreateUniqueIndex() on duplicated data err=%v, want ErrDuplicate", err)
+	}
+	if _, err := db.GetByIndex("dups", "v", "same"); err == nil {
+		t.Errorf("failed index is still declared")
+	}
+}
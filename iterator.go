@@ -0,0 +1,114 @@
+package bdb
+
+import (
+	"github.com/boltdb/bolt"
+)
+
+// 迭代器，由一个只读事务支撑。Key、Value返回的切片直接指向mmap，
+// 只在移动游标或Close之前有效，需要保留时自行拷贝。
+// 迭代器打开期间数据库无法Close，长时间不关闭还会阻碍写事务扩容文件，用完须尽快Close
+type Iterator interface {
+	First()          // 定位到第一个键
+	Last()           // 定位到最后一个键
+	Seek(key []byte) // 定位到第一个不小于key的键
+	Next()           // 移到下一个键
+	Prev()           // 移到上一个键
+	Valid() bool     // 当前是否指向一个键
+	Key() []byte     // 当前键
+	Value() []byte   // 当前值，子表为nil
+	Close() error    // 结束只读事务，可重复调用
+}
+
+type iterator struct {
+	b      *dbConnection
+	tx     *bolt.Tx
+	c      *bolt.Cursor
+	k, v   []byte
+	closed bool
+}
+
+// 创建表tn的迭代器，初始指向第一个键
+func (b *dbConnection) NewIterator(tn string) (Iterator, error) {
+	if err := b.acquireHandle(); err != nil {
+		return nil, err
+	}
+	b.flushBeforeRead()
+	tx, err := b.bdb.Begin(false)
+	if err != nil {
+		b.releaseHandle()
+		return nil, err
+	}
+	bucket, err := getBucket(tx, tn)
+	if err != nil {
+		tx.Rollback()
+		b.releaseHandle()
+		return nil, err
+	}
+
+	it := &iterator{b: b, tx: tx, c: bucket.Cursor()}
+	it.First()
+	return it, nil
+}
+
+func (it *iterator) First() {
+	if !it.closed {
+		it.k, it.v = it.c.First()
+	}
+}
+
+func (it *iterator) Last() {
+	if !it.closed {
+		it.k, it.v = it.c.Last()
+	}
+}
+
+func (it *iterator) Seek(key []byte) {
+	if !it.closed {
+		it.k, it.v = it.c.Seek(key)
+	}
+}
+
+func (it *iterator) Next() {
+	if it.Valid() {
+		it.k, it.v = it.c.Next()
+	}
+}
+
+func (it *iterator) Prev() {
+	if it.Valid() {
+		it.k, it.v = it.c.Prev()
+	}
+}
+
+func (it *iterator) Valid() bool {
+	return !it.closed && it.k != nil
+}
+
+func (it *iterator) Key() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.k
+}
+
+func (it *iterator) Value() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.v
+}
+
+func (it *iterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	it.k, it.v = nil, nil
+	if it.tx == nil {
+		// Table.Cursor，事务不归迭代器管
+		return nil
+	}
+	err := it.tx.Rollback()
+	it.b.releaseHandle()
+	return err
+}
@@ -0,0 +1,114 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIterator(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"a", "b", "c", "d"} {
+		db.Set("test", k, "v"+k)
+	}
+
+	it, err := db.NewIterator("test")
+	if err != nil {
+		t.Fatalf("db.NewIterator() failed, err=%v", err)
+	}
+	var got []string
+	for ; it.Valid(); it.Next() {
+		got = append(got, string(it.Key())+"="+string(it.Value()))
+	}
+	if fmt.Sprint(got) != "[a=va b=vb c=vc d=vd]" {
+		t.Errorf("forward iteration == %v", got)
+	}
+
+	got = nil
+	for it.Last(); it.Valid(); it.Prev() {
+		got = append(got, string(it.Key()))
+	}
+	if fmt.Sprint(got) != "[d c b a]" {
+		t.Errorf("reverse iteration == %v", got)
+	}
+
+	it.Seek([]byte("bb"))
+	if !it.Valid() || string(it.Key()) != "c" {
+		t.Errorf("it.Seek(bb) == %q, want c", it.Key())
+	}
+	it.Seek([]byte("z"))
+	if it.Valid() {
+		t.Errorf("it.Seek(z) valid at %q", it.Key())
+	}
+
+	if err := it.Close(); err != nil {
+		t.Errorf("it.Close() failed, err=%v", err)
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("second it.Close() failed, err=%v", err)
+	}
+	it.First()
+	if it.Valid() || it.Key() != nil {
+		t.Errorf("closed iterator still valid")
+	}
+
+	if _, err := db.NewIterator("missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.NewIterator(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestIteratorBlocksClose(t *testing.T) {
+	db := openTestDB(t, "test")
+	it, err := db.NewIterator("test")
+	if err != nil {
+		t.Fatalf("db.NewIterator() failed, err=%v", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		db.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatalf("db.Close() returned while an iterator was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	it.Close()
+	<-closed
+}
+
+func TestIteratorNestedCallDuringClose(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "k", "v")
+	it, err := db.NewIterator("test")
+	if err != nil {
+		t.Fatalf("db.NewIterator() failed, err=%v", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		db.Close()
+		close(closed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// Close等待迭代器期间，持有迭代器的一方仍能调用db而不会死锁
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got := string(db.Get("test", "k")); got != "v" {
+			t.Errorf("db.Get() during Close == %q, want v", got)
+		}
+		if _, err := db.NewIterator("test"); !errors.Is(err, ErrClosed) {
+			t.Errorf("db.NewIterator() during Close err=%v, want ErrClosed", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("nested call while Close was waiting did not return")
+	}
+	it.Close()
+	<-closed
+}
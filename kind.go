@@ -0,0 +1,93 @@
+package bdb
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// 键值解码类型
+type Kind int
+
+const (
+	KindString  Kind = iota // string
+	KindInt64               // int64
+	KindFloat64             // float64
+	KindBytes               // []byte
+	KindBool                // bool
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindInt64:
+		return "int64"
+	case KindFloat64:
+		return "float64"
+	case KindBytes:
+		return "bytes"
+	case KindBool:
+		return "bool"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// 按dataToBytes的编码方式解码
+func decodeKind(data []byte, kind Kind) (interface{}, error) {
+	switch kind {
+	case KindString:
+		return string(data), nil
+	case KindInt64:
+		return strconv.ParseInt(string(data), 10, 64)
+	case KindFloat64:
+		return strconv.ParseFloat(string(data), 64)
+	case KindBytes:
+		v := make([]byte, len(data))
+		copy(v, data)
+		return v, nil
+	case KindBool:
+		return strconv.ParseBool(string(data))
+	}
+	return nil, fmt.Errorf("non supported kind %v", kind)
+}
+
+// 按指定类型解码一条键值放入ret
+func putKind(ret map[interface{}]interface{}, k, v []byte, keyKind, valKind Kind) error {
+	var key interface{} = string(k)
+	if keyKind != KindBytes {
+		var err error
+		if key, err = decodeKind(k, keyKind); err != nil {
+			return fmt.Errorf("decode key %q failed: %v", k, err)
+		}
+	}
+
+	val, err := decodeKind(v, valKind)
+	if err != nil {
+		return fmt.Errorf("decode value of key %q failed: %v", k, err)
+	}
+	ret[key] = val
+	return nil
+}
+
+// []byte不能作为map的键，KindBytes的键以string返回
+func (b *dbConnection) AsMap(tn string, keyKind, valKind Kind) (map[interface{}]interface{}, error) {
+	ret := make(map[interface{}]interface{})
+	ctx, cancel := b.opContext()
+	defer cancel()
+	err := b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		return forEach(ctx, bucket, func(k, v []byte) error {
+			return putKind(ret, k, v, keyKind, valKind)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
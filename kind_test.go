@@ -0,0 +1,48 @@
+package bdb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAsMap(t *testing.T) {
+	db := openTestDB(t, "nums", "flags")
+	db.Set("nums", 1, 1.5)
+	db.Set("nums", 2, -3.25)
+	db.Set("flags", "on", true)
+	db.Set("flags", "off", false)
+
+	m, err := db.AsMap("nums", KindInt64, KindFloat64)
+	if err != nil {
+		t.Fatalf("db.AsMap(nums) failed, err=%v", err)
+	}
+	if len(m) != 2 || m[int64(1)] != 1.5 || m[int64(2)] != -3.25 {
+		t.Errorf("db.AsMap(nums) == %v", m)
+	}
+
+	m, err = db.AsMap("flags", KindBytes, KindBool)
+	if err != nil {
+		t.Fatalf("db.AsMap(flags) failed, err=%v", err)
+	}
+	if m["on"] != true || m["off"] != false {
+		t.Errorf("db.AsMap(flags) == %v", m)
+	}
+
+	m, err = db.AsMap("flags", KindString, KindBytes)
+	if err != nil {
+		t.Fatalf("db.AsMap(flags) failed, err=%v", err)
+	}
+	if v, ok := m["on"].([]byte); !ok || !bytes.Equal(v, []byte("true")) {
+		t.Errorf("db.AsMap(flags)[on] == %v, want %q", m["on"], "true")
+	}
+
+	_, err = db.AsMap("flags", KindInt64, KindBool)
+	if err == nil || !strings.Contains(err.Error(), `"off"`) {
+		t.Errorf("db.AsMap(flags, KindInt64) err=%v, want key in error", err)
+	}
+
+	if _, err = db.AsMap("missing", KindString, KindString); err == nil {
+		t.Errorf("db.AsMap(missing) should fail")
+	}
+}
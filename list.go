@@ -0,0 +1,237 @@
+package bdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// 列表存放在键的子表中，元素的键是8字节大端序号，从listStart开始向两端分配。
+// 只从两端增删，序号总是连续的，按下标读取不需要遍历
+const listStart = 1 << 63
+
+func listKey(n uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, n)
+	return k
+}
+
+// 列表首尾元素的序号，列表为空时ok为false
+func listBounds(list *bolt.Bucket) (first, last uint64, ok bool) {
+	c := list.Cursor()
+	fk, _ := c.First()
+	lk, _ := c.Last()
+	if len(fk) != 8 || len(lk) != 8 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(fk), binary.BigEndian.Uint64(lk), true
+}
+
+// 依次把values插入到表头，插入后第一个元素是最后一个value，返回列表的长度
+func (b *dbConnection) LPush(tn string, key interface{}, values ...interface{}) (int, error) {
+	return b.push(tn, key, values, true)
+}
+
+// 依次把values追加到表尾，返回列表的长度
+func (b *dbConnection) RPush(tn string, key interface{}, values ...interface{}) (int, error) {
+	return b.push(tn, key, values, false)
+}
+
+func (b *dbConnection) push(tn string, key interface{}, values []interface{}, head bool) (n int, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
+	vs := make([][]byte, len(values))
+	for i, value := range values {
+		if vs[i], err = dataToBytes(value); err != nil {
+			return 0, fmt.Errorf("invalid value:%v", err)
+		}
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		n = 0
+		list, err := b.keyBucket(tx, tn, k, kindList, len(vs) > 0)
+		if err != nil || list == nil {
+			return err
+		}
+		first, last, ok := listBounds(list.Bucket)
+		if !ok {
+			first, last = listStart, listStart-1
+		}
+		for _, v := range vs {
+			var seq uint64
+			if head {
+				if first == 0 {
+					return errors.New("list sequence exhausted")
+				}
+				first--
+				seq = first
+			} else {
+				if last == ^uint64(0) {
+					return errors.New("list sequence exhausted")
+				}
+				last++
+				seq = last
+			}
+			if err := list.Put(listKey(seq), v); err != nil {
+				return fmt.Errorf("push %v.%q failed: %w", tn, k, err)
+			}
+		}
+		n = int(last - first + 1)
+		return b.mirrorWrite(tx, func(m BoltDB) error {
+			var err error
+			if head {
+				_, err = m.LPush(tn, k, values...)
+			} else {
+				_, err = m.RPush(tn, k, values...)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// 取出并删除第一个元素，列表为空或不存在时返回ErrEmpty
+func (b *dbConnection) LPop(tn string, key interface{}) ([]byte, error) {
+	return b.pop(tn, key, true)
+}
+
+// 取出并删除最后一个元素，列表为空或不存在时返回ErrEmpty
+func (b *dbConnection) RPop(tn string, key interface{}) ([]byte, error) {
+	return b.pop(tn, key, false)
+}
+
+func (b *dbConnection) pop(tn string, key interface{}, head bool) (value []byte, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		list, err := b.keyBucket(tx, tn, k, kindList, false)
+		if err != nil {
+			return err
+		}
+		if list == nil {
+			return ErrEmpty
+		}
+		first, last, ok := listBounds(list.Bucket)
+		if !ok {
+			return ErrEmpty
+		}
+		seq := last
+		if head {
+			seq = first
+		}
+		lk := listKey(seq)
+		value = append([]byte{}, list.Get(lk)...)
+		if err := list.Delete(lk); err != nil {
+			return fmt.Errorf("pop %v.%q failed: %w", tn, k, err)
+		}
+		if err := list.dropIfEmpty(); err != nil {
+			return err
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error {
+			var err error
+			if head {
+				_, err = m.LPop(tn, k)
+			} else {
+				_, err = m.RPop(tn, k)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// 第i个元素，负数从表尾数起，-1是最后一个。超出范围时返回ErrKeyNotFound
+func (b *dbConnection) LIndex(tn string, key interface{}, i int) (value []byte, err error) {
+	err = b.viewList(tn, key, func(list *bolt.Bucket, first, last uint64) error {
+		n := int(last - first + 1)
+		if i < 0 {
+			i += n
+		}
+		if i < 0 || i >= n {
+			return ErrKeyNotFound
+		}
+		value = append([]byte{}, list.Get(listKey(first+uint64(i)))...)
+		return nil
+	})
+	return value, err
+}
+
+// 下标在[start, stop]内的元素，负数从表尾数起，与Redis的LRANGE相同；超出部分忽略
+func (b *dbConnection) LRange(tn string, key interface{}, start, stop int) (values [][]byte, err error) {
+	err = b.viewList(tn, key, func(list *bolt.Bucket, first, last uint64) error {
+		s, e, ok := listRange(first, last, start, stop)
+		if !ok {
+			return nil
+		}
+		c := list.Cursor()
+		for lk, v := c.Seek(listKey(first + uint64(s))); lk != nil && len(values) <= e-s; lk, v = c.Next() {
+			values = append(values, append([]byte{}, v...))
+		}
+		return nil
+	})
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	return values, err
+}
+
+// 列表的长度，键不存在时为0
+func (b *dbConnection) LLen(tn string, key interface{}) (n int, err error) {
+	err = b.viewList(tn, key, func(list *bolt.Bucket, first, last uint64) error {
+		n = int(last - first + 1)
+		return nil
+	})
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// 在只读事务中读取列表，列表为空或不存在时返回ErrKeyNotFound
+func (b *dbConnection) viewList(tn string, key interface{}, fn func(list *bolt.Bucket, first, last uint64) error) error {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	return b.view(func(tx *bolt.Tx) error {
+		list, err := b.keyBucket(tx, tn, k, kindList, false)
+		if err != nil {
+			return err
+		}
+		if list == nil {
+			return ErrKeyNotFound
+		}
+		first, last, ok := listBounds(list.Bucket)
+		if !ok {
+			return ErrKeyNotFound
+		}
+		return fn(list.Bucket, first, last)
+	})
+}
+
+// 把可能为负的下标换算成[0, 长度)内的闭区间，区间为空时ok为false
+func listRange(first, last uint64, start, stop int) (s, e int, ok bool) {
+	n := int(last - first + 1)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	return start, stop, start <= stop
+}
@@ -0,0 +1,62 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	db := openTestDB(t, "history")
+	if n, err := db.RPush("history", "u1", "b", "c"); err != nil || n != 2 {
+		t.Errorf("db.RPush() == %d, %v, want 2, nil", n, err)
+	}
+	if n, err := db.LPush("history", "u1", "a", "z"); err != nil || n != 4 {
+		t.Errorf("db.LPush() == %d, %v, want 4, nil", n, err)
+	}
+	// [z a b c]
+	var tests = []struct {
+		i    int
+		want string
+	}{
+		{0, "z"}, {1, "a"}, {3, "c"}, {-1, "c"}, {-4, "z"},
+	}
+	for _, test := range tests {
+		if v, err := db.LIndex("history", "u1", test.i); err != nil || string(v) != test.want {
+			t.Errorf("db.LIndex(%d) == %q, %v, want %q", test.i, v, err, test.want)
+		}
+	}
+	if _, err := db.LIndex("history", "u1", 4); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.LIndex(4) err=%v, want ErrKeyNotFound", err)
+	}
+
+	if got, err := db.LRange("history", "u1", 1, -1); err != nil || len(got) != 3 || string(got[0]) != "a" || string(got[2]) != "c" {
+		t.Errorf("db.LRange(1, -1) == %q, %v, want [a b c]", got, err)
+	}
+	if got, _ := db.LRange("history", "u1", -100, 100); len(got) != 4 {
+		t.Errorf("db.LRange(-100, 100) == %q, want 4 items", got)
+	}
+	if got, _ := db.LRange("history", "u1", 3, 1); len(got) != 0 {
+		t.Errorf("db.LRange(3, 1) == %q, want none", got)
+	}
+
+	if v, err := db.LPop("history", "u1"); err != nil || string(v) != "z" {
+		t.Errorf("db.LPop() == %q, %v, want z", v, err)
+	}
+	if v, err := db.RPop("history", "u1"); err != nil || string(v) != "c" {
+		t.Errorf("db.RPop() == %q, %v, want c", v, err)
+	}
+	if n, _ := db.LLen("history", "u1"); n != 2 {
+		t.Errorf("db.LLen() == %d, want 2", n)
+	}
+	db.LPop("history", "u1")
+	db.LPop("history", "u1")
+	if _, err := db.LPop("history", "u1"); !errors.Is(err, ErrEmpty) {
+		t.Errorf("db.LPop() on empty err=%v, want ErrEmpty", err)
+	}
+	if n, err := db.LLen("history", "u1"); err != nil || n != 0 {
+		t.Errorf("db.LLen(empty) == %d, %v, want 0, nil", n, err)
+	}
+	if got, err := db.LRange("history", "missing", 0, -1); err != nil || len(got) != 0 {
+		t.Errorf("db.LRange(missing) == %q, %v, want none", got, err)
+	}
+}
@@ -0,0 +1,25 @@
+package bdb
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// 结构化日志的输出，默认为slog.Default()
+type logger struct {
+	p atomic.Pointer[slog.Logger]
+}
+
+// 设置bdb内部的日志输出，nil恢复为slog.Default()。
+// 后台任务的失败(写缓冲刷新、镜像、变更流、定时备份等)记为Error，
+// 过期清理和备份完成记为Info，读接口吞掉的错误(如Get的表不存在)记为Debug
+func (b *dbConnection) SetLogger(l *slog.Logger) {
+	b.logger.p.Store(l)
+}
+
+func (b *dbConnection) log() *slog.Logger {
+	if l := b.logger.p.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
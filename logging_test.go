@@ -0,0 +1,27 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetLogger(t *testing.T) {
+	db := openTestDB(t, "test")
+	var buf bytes.Buffer
+	db.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	db.Get("missing", "k")
+	if out := buf.String(); !strings.Contains(out, "level=DEBUG") || !strings.Contains(out, "table=missing") {
+		t.Errorf("log for Get(missing) == %q, want debug record with table", out)
+	}
+
+	buf.Reset()
+	db.RegisterHook(AfterSet, func(op *WriteOp) error { return errors.New("boom") })
+	db.Set("test", "k", "v")
+	if out := buf.String(); !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "err=boom") {
+		t.Errorf("log for failed hook == %q, want error record", out)
+	}
+}
@@ -0,0 +1,161 @@
+package bdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// 按名字打开并缓存连接，同一个文件只打开一次。Get返回的连接共用底层连接，
+// 各自Close后引用计数归零时才真正关闭
+type Manager struct {
+	dir  string
+	mode os.FileMode
+	opts *Options
+
+	mu     sync.Mutex
+	conns  map[string]*managedConn
+	closed bool
+}
+
+type managedConn struct {
+	db    BoltDB
+	refs  int
+	ready chan struct{} // 打开结束后关闭，之后db和err不再改变
+	err   error
+}
+
+// 相对路径的名字在dir下打开，mode和opts用于所有连接
+func NewManager(dir string, mode os.FileMode, opts *Options) *Manager {
+	return &Manager{dir: dir, mode: mode, opts: opts, conns: map[string]*managedConn{}}
+}
+
+// 返回名为name的连接，未打开时打开。不同的名字指向同一个文件时(如"a.db"与"./a.db")返回同一个连接。
+// 打开文件时不持有锁，同时Get同一个文件的等待这次打开的结果，不影响其它文件。
+// 用完须Close，CloseAll之后返回ErrClosed
+func (m *Manager) Get(name string) (BoltDB, error) {
+	path, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c, ok := m.conns[path]
+	if !ok {
+		c = &managedConn{ready: make(chan struct{})}
+		m.conns[path] = c
+	}
+	c.refs++
+	m.mu.Unlock()
+
+	if !ok {
+		m.open(path, c)
+	}
+	<-c.ready
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &managedDB{BoltDB: c.db, m: m, path: path}, nil
+}
+
+// 打开c对应的文件，失败或期间CloseAll了时撤销登记
+func (m *Manager) open(path string, c *managedConn) {
+	db, err := OpenWithOptions(path, m.mode, m.opts)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer close(c.ready)
+	if err == nil && m.closed {
+		db.Close()
+		err = ErrClosed
+	}
+	if err != nil {
+		c.err = err
+		if m.conns[path] == c {
+			delete(m.conns, path)
+		}
+		return
+	}
+	c.db = db
+}
+
+// 当前打开的文件路径，按字母排序
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.conns))
+	for path, c := range m.conns {
+		if c.db != nil {
+			names = append(names, path)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 关闭所有连接，不论是否还有引用；之后Get返回ErrClosed，已返回的连接上的操作返回ErrClosed。
+// 正在打开的连接打开后随即关闭
+func (m *Manager) CloseAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for path, c := range m.conns {
+		if c.db != nil {
+			c.db.Close()
+		}
+		delete(m.conns, path)
+	}
+}
+
+// 名字对应的绝对路径并解析符号链接，文件还不存在时只解析所在目录
+func (m *Manager) path(name string) (string, error) {
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(m.dir, name)
+	}
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real, nil
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path)), nil
+	}
+	return path, nil
+}
+
+func (m *Manager) release(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.conns[path]
+	if !ok {
+		return
+	}
+	if c.refs--; c.refs == 0 {
+		c.db.Close()
+		delete(m.conns, path)
+	}
+}
+
+// Manager返回的连接，Close只释放引用，不能Open其它文件
+type managedDB struct {
+	BoltDB
+	m    *Manager
+	path string
+	once sync.Once
+}
+
+func (d *managedDB) Close() {
+	d.once.Do(func() { d.m.release(d.path) })
+}
+
+// 底层连接由其它持有者共用，不能重新打开
+func (d *managedDB) Open(dbname string, mode os.FileMode) error {
+	return fmt.Errorf("connection to %v is shared by the manager, cannot open %v", d.path, dbname)
+}
@@ -0,0 +1,109 @@
+package bdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir, 0600, nil)
+	defer m.CloseAll()
+
+	a, err := m.Get("users.db")
+	if err != nil {
+		t.Fatalf("m.Get(users.db) failed, err=%v", err)
+	}
+	a.CreateTable("test")
+	a.Set("test", "k", "v")
+
+	// 同一个文件只打开一次，否则第二次会等待文件锁
+	b, err := m.Get(filepath.Join(dir, ".", "users.db"))
+	if err != nil {
+		t.Fatalf("m.Get(abs path) failed, err=%v", err)
+	}
+	if got := string(b.Get("test", "k")); got != "v" {
+		t.Errorf("b.Get(k) == %q, want %q", got, "v")
+	}
+	os.Symlink(filepath.Join(dir, "users.db"), filepath.Join(dir, "link.db"))
+	c, err := m.Get("link.db")
+	if err != nil {
+		t.Fatalf("m.Get(link.db) failed, err=%v", err)
+	}
+	if names := m.Names(); len(names) != 1 {
+		t.Errorf("m.Names() == %v, want one file", names)
+	}
+
+	// 引用计数归零时才关闭
+	a.Close()
+	a.Close()
+	b.Close()
+	if got := string(c.Get("test", "k")); got != "v" {
+		t.Errorf("c.Get(k) after other Close == %q, want %q", got, "v")
+	}
+	c.Close()
+	if names := m.Names(); len(names) != 0 {
+		t.Errorf("m.Names() after Close == %v, want none", names)
+	}
+	if err := c.Set("test", "k", "x"); err != ErrClosed {
+		t.Errorf("c.Set() after Close err=%v, want ErrClosed", err)
+	}
+
+	d, err := m.Get("orders.db")
+	if err != nil {
+		t.Fatalf("m.Get(orders.db) failed, err=%v", err)
+	}
+	m.CloseAll()
+	if err := d.CreateTable("test"); err != ErrClosed {
+		t.Errorf("d.CreateTable() after CloseAll err=%v, want ErrClosed", err)
+	}
+	d.Close()
+	if _, err := m.Get("orders.db"); err != ErrClosed {
+		t.Errorf("m.Get() after CloseAll err=%v, want ErrClosed", err)
+	}
+}
+
+func TestManagerLockedFile(t *testing.T) {
+	dir := t.TempDir()
+	held, err := Open(filepath.Join(dir, "locked.db"), 0600)
+	if err != nil {
+		t.Fatalf("Open(locked.db) failed, err=%v", err)
+	}
+	defer held.Close()
+
+	m := NewManager(dir, 0600, &Options{Timeout: 500 * time.Millisecond})
+	defer m.CloseAll()
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := m.Get("locked.db")
+			errs <- err
+		}()
+	}
+
+	// 等待文件锁时其它文件照常打开
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	other, err := m.Get("other.db")
+	if err != nil || time.Since(start) > 200*time.Millisecond {
+		t.Fatalf("m.Get(other.db) took %v, err=%v", time.Since(start), err)
+	}
+	if err := other.Open(filepath.Join(dir, "third.db"), 0600); err == nil {
+		t.Errorf("other.Open() on managed connection err=nil, want error")
+	}
+	if got := other.GetDBName(); got != filepath.Join(dir, "other.db") {
+		t.Errorf("other.GetDBName() == %q after Open, want other.db", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, ErrLocked) {
+			t.Errorf("m.Get(locked.db) err=%v, want ErrLocked", err)
+		}
+	}
+	if names := m.Names(); len(names) != 1 {
+		t.Errorf("m.Names() == %v, want only other.db", names)
+	}
+}
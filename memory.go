@@ -0,0 +1,35 @@
+package bdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 打开一个用于测试的临时数据库。文件放在临时目录中(有/dev/shm时放在内存中)，
+// 提交时不fsync，Close时连同目录一起删除。崩溃时数据不保证完整，不要用于需要持久化的数据
+func OpenMemory() (BoltDB, error) {
+	dir, err := os.MkdirTemp(memoryDir(), "bdb-")
+	if err != nil {
+		// /dev/shm不可写时退回到os.TempDir()
+		dir, err = os.MkdirTemp("", "bdb-")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create memory db dir failed:%w", err)
+	}
+	path := filepath.Join(dir, "memory.db")
+	b := &dbConnection{name: path, opts: &Options{NoSync: true, NoGrowSync: true}, tmpdir: dir}
+	if err := b.Open(path, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return b, nil
+}
+
+// tmpfs上的目录，没有时为""，即os.TempDir()
+func memoryDir() string {
+	if fi, err := os.Stat("/dev/shm"); err == nil && fi.IsDir() {
+		return "/dev/shm"
+	}
+	return ""
+}
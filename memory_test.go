@@ -0,0 +1,38 @@
+package bdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMemory(t *testing.T) {
+	db, err := OpenMemory()
+	if err != nil {
+		t.Fatalf("OpenMemory() failed, err=%v", err)
+	}
+	dir := filepath.Dir(db.GetDBName())
+	if err := db.CreateTable("test"); err != nil {
+		t.Fatalf("db.CreateTable() failed, err=%v", err)
+	}
+	db.Set("test", "k", "v")
+	if got := string(db.Get("test", "k")); got != "v" {
+		t.Errorf("db.Get(k) == %q, want %q", got, "v")
+	}
+
+	// 各自独立
+	other, err := OpenMemory()
+	if err != nil {
+		t.Fatalf("second OpenMemory() failed, err=%v", err)
+	}
+	if _, err := other.GetValue("test", "k"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("other.GetValue() err=%v, want ErrTableNotFound", err)
+	}
+	other.Close()
+
+	db.Close()
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("memory db dir %v still exists after Close, err=%v", dir, err)
+	}
+}
@@ -0,0 +1,101 @@
+package bdb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// 接收操作耗时的观察者，用于接入监控系统，见RegisterPrometheus(需要prometheus编译标签)。
+// 方法在操作的协程中同步调用，应当很快返回
+type Observer interface {
+	// Get、Set、Delete等单条操作和Scan结束时调用，op为小写的操作名
+	ObserveOp(op, tn string, d time.Duration, err error)
+	// Set、Update等的写事务结束时调用，d为从开始事务到提交完成的时间；Begin开始的事务不在其中
+	ObserveCommit(d time.Duration, err error)
+}
+
+// 接收操作的起止时间，用于生成追踪的span，见EnableTracing(需要otel编译标签)。
+// 操作结束后同步调用，ctx为调用方传入的上下文，span应以其中的span为父；
+// 没有ctx参数的接口传入context.Background()。写事务的span与操作的span同级
+type Tracer interface {
+	// op为小写的操作名，写事务为commit，此时tn为空；大小为编码后的字节数，未知时为0
+	TraceOp(ctx context.Context, op, tn string, start, end time.Time, keySize, valueSize int, err error)
+}
+
+type observer struct {
+	p atomic.Pointer[Observer]
+	t atomic.Pointer[Tracer]
+}
+
+// 设置观察者，nil表示不观察
+func (b *dbConnection) SetObserver(o Observer) {
+	if o == nil {
+		b.metrics.p.Store(nil)
+		return
+	}
+	b.metrics.p.Store(&o)
+}
+
+// 设置追踪，nil表示不追踪
+func (b *dbConnection) SetTracer(t Tracer) {
+	if t == nil {
+		b.metrics.t.Store(nil)
+		return
+	}
+	b.metrics.t.Store(&t)
+}
+
+func (b *dbConnection) tracing() Tracer {
+	if t := b.metrics.t.Load(); t != nil {
+		return *t
+	}
+	return nil
+}
+
+func (b *dbConnection) observing() Observer {
+	if o := b.metrics.p.Load(); o != nil {
+		return *o
+	}
+	return nil
+}
+
+// 用法：defer b.observeOp(ctx, "get", tn, time.Now(), key, nil, &err)，err为nil表示操作不返回错误。
+// 键值的大小只在追踪时重新编码计算
+func (b *dbConnection) observeOp(ctx context.Context, op, tn string, start time.Time, key, value interface{}, err *error) {
+	o, t := b.observing(), b.tracing()
+	if o == nil && t == nil {
+		return
+	}
+	var e error
+	if err != nil {
+		e = *err
+	}
+	end := time.Now()
+	if o != nil {
+		o.ObserveOp(op, tn, end.Sub(start), e)
+	}
+	if t != nil {
+		var ks, vs int
+		if k, kerr := keyToBytes(key); kerr == nil {
+			ks = len(k)
+		}
+		if value != nil {
+			if v, verr := dataToBytes(value); verr == nil {
+				vs = len(v)
+			}
+		}
+		t.TraceOp(ctx, op, tn, start, end, ks, vs, e)
+	}
+}
+
+// 写事务结束时调用
+func (b *dbConnection) observeCommit(ctx context.Context, start time.Time, err error) {
+	end := time.Now()
+	if o := b.observing(); o != nil {
+		o.ObserveCommit(end.Sub(start), err)
+	}
+	if t := b.tracing(); t != nil {
+		t.TraceOp(ctx, "commit", "", start, end, 0, 0, err)
+	}
+}
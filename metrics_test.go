@@ -0,0 +1,154 @@
+package bdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testObserver struct {
+	mu      sync.Mutex
+	ops     []string
+	errs    int
+	commits int
+}
+
+func (o *testObserver) ObserveOp(op, tn string, d time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ops = append(o.ops, op+" "+tn)
+	if err != nil {
+		o.errs++
+	}
+}
+
+func (o *testObserver) ObserveCommit(d time.Duration, err error) {
+	o.mu.Lock()
+	o.commits++
+	o.mu.Unlock()
+}
+
+func TestObserver(t *testing.T) {
+	db := openTestDB(t, "test")
+	o := &testObserver{}
+	db.SetObserver(o)
+
+	db.Set("test", "k", "v")
+	db.Get("test", "k")
+	db.GetValue("test", "missing")
+	db.Delete("test", "k")
+	if err := db.Set("missing", "k", "v"); !errors.Is(err, ErrTableNotFound) {
+		t.Fatalf("db.Set(missing) err=%v, want ErrTableNotFound", err)
+	}
+
+	want := []string{"set test", "get test", "get test", "delete test", "set missing"}
+	if len(o.ops) != len(want) {
+		t.Fatalf("observed ops == %q, want %q", o.ops, want)
+	}
+	for i := range want {
+		if o.ops[i] != want[i] {
+			t.Errorf("ops[%d] == %q, want %q", i, o.ops[i], want[i])
+		}
+	}
+	if o.errs != 2 || o.commits != 3 {
+		t.Errorf("errors == %d, commits == %d, want 2, 3", o.errs, o.commits)
+	}
+
+	db.SetObserver(nil)
+	db.Set("test", "k", "v")
+	if len(o.ops) != len(want) {
+		t.Errorf("op observed after SetObserver(nil)")
+	}
+}
+
+type traced struct {
+	op, tn     string
+	key, value int
+	err        bool
+}
+
+type parentKey struct{}
+
+type testTracer struct {
+	mu      sync.Mutex
+	spans   []traced
+	parents []interface{}
+}
+
+func (t *testTracer) TraceOp(ctx context.Context, op, tn string, start, end time.Time, keySize, valueSize int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if end.Before(start) {
+		op += " (end before start)"
+	}
+	t.spans = append(t.spans, traced{op, tn, keySize, valueSize, err != nil})
+	t.parents = append(t.parents, ctx.Value(parentKey{}))
+}
+
+func TestTracer(t *testing.T) {
+	db := openTestDB(t, "test")
+	tr := &testTracer{}
+	db.SetTracer(tr)
+
+	db.Set("test", "key", "value")
+	db.Get("test", "key")
+	db.Has("test", "missing")
+	db.Delete("missing", "k")
+	db.SetTracer(nil)
+	db.Set("test", "k", "v")
+
+	want := []traced{
+		{"commit", "", 0, 0, false},
+		{"set", "test", 3, 5, false},
+		{"get", "test", 3, 0, false},
+		{"has", "test", 7, 0, false},
+		{"commit", "", 0, 0, true},
+		{"delete", "missing", 1, 0, true},
+	}
+	if len(tr.spans) != len(want) {
+		t.Fatalf("spans == %+v, want %+v", tr.spans, want)
+	}
+	for i := range want {
+		if tr.spans[i] != want[i] {
+			t.Errorf("spans[%d] == %+v, want %+v", i, tr.spans[i], want[i])
+		}
+	}
+}
+
+func TestTracerContext(t *testing.T) {
+	db := openTestDB(t, "test")
+	tr := &testTracer{}
+	db.SetTracer(tr)
+	ctx := context.WithValue(context.Background(), parentKey{}, "req")
+
+	db.SetCtx(ctx, "test", "key", "value")
+	db.GetCtx(ctx, "test", "key")
+	db.ScanCtx(ctx, "test", []byte("k"), func(k, v []byte) error { return nil })
+	db.DeleteCtx(ctx, "test", "key")
+	db.Has("test", "key")
+
+	want := []traced{
+		{"commit", "", 0, 0, false},
+		{"set", "test", 3, 5, false},
+		{"get", "test", 3, 0, false},
+		{"scan", "test", 1, 0, false},
+		{"commit", "", 0, 0, false},
+		{"delete", "test", 3, 0, false},
+		{"has", "test", 3, 0, false},
+	}
+	if len(tr.spans) != len(want) {
+		t.Fatalf("spans == %+v, want %+v", tr.spans, want)
+	}
+	for i := range want {
+		var parent interface{} = "req"
+		if want[i].op == "has" {
+			// 没有ctx参数的接口
+			parent = nil
+		}
+		if tr.spans[i] != want[i] || tr.parents[i] != parent {
+			t.Errorf("spans[%d] == %+v under %v, want %+v under %v", i, tr.spans[i], tr.parents[i], want[i], parent)
+		}
+	}
+}
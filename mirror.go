@@ -0,0 +1,103 @@
+package bdb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// 写镜像，用作简单的本地热备
+//
+// 默认尽力而为：主库提交成功后再写镜像，镜像失败只记录日志，两边可能不一致，
+// 且主库与镜像之间没有原子性，进程在两次写之间崩溃会丢失镜像写。
+// 严格模式把镜像写排队到主库事务提交之前再按顺序写入，镜像失败则主库回滚；
+// 事务自己回滚(fn返回错误、Txn.Rollback)时镜像写不会发生。但镜像已写入一部分后失败，
+// 或镜像已写入而主库提交失败时，镜像会比主库多出这些写。
+type mirror struct {
+	mu     sync.RWMutex
+	db     BoltDB
+	strict bool
+
+	pendMu  sync.Mutex
+	pending map[*bolt.Tx][]func() error // 严格模式下等待提交前写入的镜像写
+}
+
+func (b *dbConnection) SetMirror(other BoltDB) {
+	if other != nil && b.isSelf(other) {
+		// 镜像到自身会在写事务里再开写事务，直接死锁
+		other = nil
+	}
+	b.mirror.mu.Lock()
+	b.mirror.db = other
+	b.mirror.mu.Unlock()
+}
+
+// other是否就是b本身，Manager返回的连接先取出底层连接再比较，同一个文件也算
+func (b *dbConnection) isSelf(other BoltDB) bool {
+	other = unwrapManaged(other)
+	return other == BoltDB(b) || (b.name != "" && other.GetDBName() == b.name)
+}
+
+// 取出Manager返回的连接底层的连接
+func unwrapManaged(db BoltDB) BoltDB {
+	for {
+		d, ok := db.(*managedDB)
+		if !ok {
+			return db
+		}
+		db = d.BoltDB
+	}
+}
+
+func (b *dbConnection) SetMirrorStrict(strict bool) {
+	b.mirror.mu.Lock()
+	b.mirror.strict = strict
+	b.mirror.mu.Unlock()
+}
+
+// 在写事务中调用，把同样的写应用到镜像库
+func (b *dbConnection) mirrorWrite(tx *bolt.Tx, fn func(m BoltDB) error) error {
+	b.mirror.mu.RLock()
+	m, strict := b.mirror.db, b.mirror.strict
+	b.mirror.mu.RUnlock()
+	if m == nil {
+		return nil
+	}
+
+	if strict {
+		b.mirror.pendMu.Lock()
+		if b.mirror.pending == nil {
+			b.mirror.pending = make(map[*bolt.Tx][]func() error)
+		}
+		b.mirror.pending[tx] = append(b.mirror.pending[tx], func() error { return fn(m) })
+		b.mirror.pendMu.Unlock()
+		return nil
+	}
+
+	tx.OnCommit(func() {
+		if err := fn(m); err != nil {
+			b.log().Error("bdb: mirror write failed", "mirror", m.GetDBName(), "err", err)
+		}
+	})
+	return nil
+}
+
+// 在写事务提交前调用，写入严格模式下排队的镜像写，失败时返回错误让主库回滚
+func (b *dbConnection) applyMirror(tx *bolt.Tx) error {
+	for _, w := range b.mirror.forget(tx) {
+		if err := w(); err != nil {
+			return fmt.Errorf("mirror write failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// 取出并丢弃tx排队的镜像写
+func (mr *mirror) forget(tx *bolt.Tx) []func() error {
+	mr.pendMu.Lock()
+	defer mr.pendMu.Unlock()
+	ws := mr.pending[tx]
+	delete(mr.pending, tx)
+	return ws
+}
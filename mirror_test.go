@@ -0,0 +1,154 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	primary := openTestDB(t)
+	standby := openTestDB(t)
+	primary.SetMirror(standby)
+
+	if err := primary.CreateTable("test"); err != nil {
+		t.Fatalf("primary.CreateTable() failed, err=%v", err)
+	}
+	primary.Set("test", "k1", "v1")
+	primary.Set("test", "k2", "v2")
+	primary.Delete("test", "k2")
+	primary.Add("test", "added")
+
+	if got := string(standby.Get("test", "k1")); got != "v1" {
+		t.Errorf("standby.Get(k1) == %q, want %q", got, "v1")
+	}
+	if got := standby.Get("test", "k2"); got != nil {
+		t.Errorf("standby.Get(k2) == %q, want nil", got)
+	}
+	if got := string(standby.Get("test", 1)); got != "added" {
+		t.Errorf("standby.Get(1) == %q, want %q", got, "added")
+	}
+
+	// 尽力而为模式下镜像失败不影响主库
+	standby.Close()
+	if err := primary.Set("test", "k3", "v3"); err != nil {
+		t.Errorf("best-effort primary.Set() failed, err=%v", err)
+	}
+
+	// 严格模式下镜像失败，主库回滚
+	primary.SetMirrorStrict(true)
+	if err := primary.Set("test", "k4", "v4"); err == nil {
+		t.Errorf("strict primary.Set() should fail when mirror fails")
+	}
+	if got := primary.Get("test", "k4"); got != nil {
+		t.Errorf("primary.Get(k4) == %q after failed strict write, want nil", got)
+	}
+
+	// 取消镜像
+	primary.SetMirror(nil)
+	if err := primary.Set("test", "k5", "v5"); err != nil {
+		t.Errorf("primary.Set() without mirror failed, err=%v", err)
+	}
+}
+
+func TestMirrorStrictRollback(t *testing.T) {
+	primary := openTestDB(t, "test")
+	standby := openTestDB(t, "test")
+	primary.SetMirror(standby)
+	primary.SetMirrorStrict(true)
+
+	tx, err := primary.Begin(true)
+	if err != nil {
+		t.Fatalf("primary.Begin() failed, err=%v", err)
+	}
+	tx.Set("test", "k1", "v1")
+	tx.Rollback()
+	if got := standby.Get("test", "k1"); got != nil {
+		t.Errorf("standby.Get(k1) == %q after rollback, want nil", got)
+	}
+
+	failed := errors.New("failed")
+	err = primary.Update("test", func(tb Table) error {
+		tb.Set("k2", "v2")
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("primary.Update() err=%v, want %v", err, failed)
+	}
+	if got := standby.Get("test", "k2"); got != nil {
+		t.Errorf("standby.Get(k2) == %q after failed Update, want nil", got)
+	}
+
+	tx, _ = primary.Begin(true)
+	tx.Set("test", "k3", "v3")
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("tx.Commit() failed, err=%v", err)
+	}
+	if got := string(standby.Get("test", "k3")); got != "v3" {
+		t.Errorf("standby.Get(k3) == %q after commit, want v3", got)
+	}
+}
+
+func TestMirrorSelfThroughManager(t *testing.T) {
+	m := NewManager(t.TempDir(), 0600, nil)
+	defer m.CloseAll()
+	a, _ := m.Get("a.db")
+	same, _ := m.Get("a.db")
+	a.CreateTable("test")
+	a.SetMirror(same)
+
+	done := make(chan error, 1)
+	go func() { done <- a.Set("test", "k", "v") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("a.Set() with a self mirror err=%v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("a.Set() deadlocked on a self mirror")
+	}
+}
+
+// 批量改写表的方法同样写到镜像
+func TestMirrorBulkWrites(t *testing.T) {
+	primary := openTestDB(t, "all")
+	standby := openTestDB(t, "all")
+	primary.SetMirror(standby)
+	primary.Set("all", "a1", "1")
+	primary.Set("all", "b1", "2")
+
+	primary.Shard("all", func(k, v []byte) string { return "shard_" + string(k[:1]) })
+	if got := string(standby.Get("shard_b", "b1")); got != "2" {
+		t.Errorf("standby.Get(shard_b, b1) == %q after Shard, want 2", got)
+	}
+
+	primary.TransformValues("all", func(k, v []byte) ([]byte, error) { return append(v, '0'), nil })
+	if got := string(standby.Get("all", "a1")); got != "10" {
+		t.Errorf("standby.Get(all, a1) == %q after TransformValues, want 10", got)
+	}
+
+	primary.InitTable("seeded", map[interface{}]interface{}{"k": "v"})
+	if got := string(standby.Get("seeded", "k")); got != "v" {
+		t.Errorf("standby.Get(seeded, k) == %q after InitTable, want v", got)
+	}
+
+	primary.ExtractRange("all", "archive", "a", "b")
+	if got := string(standby.Get("archive", "a1")); got != "10" {
+		t.Errorf("standby.Get(archive, a1) == %q after ExtractRange, want 10", got)
+	}
+
+	primary.UpdateAll("all", func(k, v []byte) (interface{}, bool, error) {
+		return "x", string(k) == "b1", nil
+	})
+	if got := string(standby.Get("all", "a1")); got != "x" {
+		t.Errorf("standby.Get(all, a1) == %q after UpdateAll, want x", got)
+	}
+	if got := standby.Get("all", "b1"); got != nil {
+		t.Errorf("standby.Get(all, b1) == %q after UpdateAll deleted it, want nil", got)
+	}
+
+	primary.CopyTable("all", "copy")
+	if got := string(standby.Get("copy", "a1")); got != "x" {
+		t.Errorf("standby.Get(copy, a1) == %q after CopyTable, want x", got)
+	}
+}
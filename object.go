@@ -0,0 +1,104 @@
+package bdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 由结构体字段的bdb标签得到的信息：
+//
+//	ID    string `bdb:"key"`    // 主键，必须有且只有一个
+//	Team  string `bdb:"index"`  // 需要建索引的字段
+//	Email string `bdb:"unique"` // 需要建唯一索引的字段
+type model struct {
+	key     int          // 主键字段的下标
+	indexes []modelIndex // 索引字段
+}
+
+type modelIndex struct {
+	field  int // 字段的下标
+	unique bool
+}
+
+var models sync.Map // reflect.Type -> *model
+
+// 解析结构体类型t的标签，结果会被缓存
+func modelOf(t reflect.Type) (*model, error) {
+	if m, ok := models.Load(t); ok {
+		return m.(*model), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v is not a struct", t)
+	}
+
+	m := &model{key: -1}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("bdb")
+		if !ok {
+			continue
+		}
+		for _, opt := range strings.Split(tag, ",") {
+			switch opt {
+			case "key":
+				if m.key >= 0 {
+					return nil, fmt.Errorf("%v has more than one key field", t)
+				}
+				m.key = i
+			case "index", "unique":
+				m.indexes = append(m.indexes, modelIndex{field: i, unique: opt == "unique"})
+			default:
+				return nil, fmt.Errorf("unknown bdb tag %q on %v.%v", opt, t, f.Name)
+			}
+		}
+	}
+	if m.key < 0 {
+		return nil, fmt.Errorf("%v has no field tagged bdb:\"key\"", t)
+	}
+	models.Store(t, m)
+	return m, nil
+}
+
+// 取结构体或结构体指针v中的主键，转换成Set所支持的类型
+func objectKey(v interface{}) (interface{}, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("invalid object %v", v)
+	}
+	m, err := modelOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	return fieldKey(rv.Field(m.key))
+}
+
+// 把字段的值转换成string、int64、uint64或[]byte
+func fieldKey(f reflect.Value) (interface{}, error) {
+	switch {
+	case f.Kind() == reflect.String:
+		return f.String(), nil
+	case f.CanInt():
+		return f.Int(), nil
+	case f.CanUint():
+		return f.Uint(), nil
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8:
+		return f.Bytes(), nil
+	}
+	return nil, fmt.Errorf("non supported key field type %v", f.Type())
+}
+
+// 以v中bdb:"key"字段为键，用表的编解码方式把整个v写入表tn
+func (b *dbConnection) Save(tn string, v interface{}) error {
+	key, err := objectKey(v)
+	if err != nil {
+		return err
+	}
+	return b.SetObject(tn, key, v)
+}
+
+// 按主键读出对象并解码到out，out须是结构体指针
+func (b *dbConnection) Load(tn string, key, out interface{}) error {
+	return b.GetObject(tn, key, out)
+}
@@ -0,0 +1,51 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+)
+
+type account struct {
+	ID    int64  `bdb:"key"`
+	Email string `bdb:"index"`
+	Name  string
+}
+
+func TestSaveLoad(t *testing.T) {
+	db := openTestDB(t, "accounts")
+	a := account{ID: 7, Email: "a@x", Name: "alice"}
+	if err := db.Save("accounts", &a); err != nil {
+		t.Fatalf("db.Save() failed, err=%v", err)
+	}
+
+	var got account
+	if err := db.Load("accounts", 7, &got); err != nil || got != a {
+		t.Errorf("db.Load(7) == %+v, %v, want %+v", got, err, a)
+	}
+	if err := db.Load("accounts", 8, &got); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.Load(8) err=%v, want ErrKeyNotFound", err)
+	}
+
+	var tests = []interface{}{
+		struct{ Name string }{"no key"},
+		struct {
+			A string `bdb:"key"`
+			B string `bdb:"key"`
+		}{"a", "b"},
+		struct {
+			A string `bdb:"primary"`
+		}{"a"},
+		struct {
+			A float64 `bdb:"key"`
+		}{1},
+		struct {
+			A string `bdb:"key"`
+		}{""},
+		42,
+	}
+	for _, v := range tests {
+		if err := db.Save("accounts", v); err == nil {
+			t.Errorf("db.Save(%#v) succeeded", v)
+		}
+	}
+}
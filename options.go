@@ -0,0 +1,120 @@
+package bdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 打开数据库的选项，对应bolt.Options。bolt的页大小固定为操作系统页大小，不能配置
+type Options struct {
+	Timeout         time.Duration // 等待文件锁的时长，0为10秒，负数表示一直等待，超时返回ErrLocked
+	ReadOnly        bool          // 只读打开，写操作返回ErrReadOnly
+	NoSync          bool          // 提交时不fsync，崩溃可能丢失最近的写
+	NoGrowSync      bool          // 扩展文件时不fsync
+	InitialMmapSize int           // 初始mmap大小，足够大时读事务不会阻塞写事务扩容
+	MmapFlags       int           // 传给mmap的额外标志，如syscall.MAP_POPULATE
+
+	AutoCreateTables bool // Set、Add等写入不存在的表时自动创建，否则返回ErrTableNotFound
+
+	// Batch为true时Set、Delete经由bolt的DB.Batch提交，多个goroutine并发的小写入合并到
+	// 同一个事务中，减少fsync次数，但单个调用要等待MaxBatchDelay。合并的事务中有一个失败时
+	// 其余的会单独重试一次
+	Batch         bool
+	MaxBatchSize  int           // 每个合并事务最多的写入数，0为bolt的默认值
+	MaxBatchDelay time.Duration // 合并前最多等待的时长，0为bolt的默认值
+
+	TTLSweepInterval time.Duration // 后台删除过期键的间隔，0为1分钟，负数表示不在后台清理
+}
+
+// 等待文件锁超时，errors.Is(err, bolt.ErrTimeout)同样成立
+var ErrLocked = fmt.Errorf("database locked by another process: %w", bolt.ErrTimeout)
+
+// Options.Timeout为0时等待文件锁的时长
+var defaultLockTimeout = 10 * time.Second
+
+// 只读打开时写操作返回的错误，errors.Is(err, bolt.ErrDatabaseReadOnly)同样成立
+var ErrReadOnly = fmt.Errorf("database opened read-only: %w", bolt.ErrDatabaseReadOnly)
+
+// 只读打开数据库，相当于Options{ReadOnly: true}。只读打开使用共享文件锁，
+// 多个进程可以同时只读打开同一个文件，但会等待持有写锁的进程关闭。文件不存在时返回错误
+func OpenReadOnly(db string) (BoltDB, error) {
+	// bolt只读打开时也带O_CREATE，先检查避免留下空文件
+	if _, err := os.Stat(db); err != nil {
+		return nil, err
+	}
+	return OpenWithOptions(db, 0400, &Options{ReadOnly: true})
+}
+
+// 以指定选项打开数据库，opts为nil时与Open相同。之后通过Open方法重新打开时沿用这些选项
+func OpenWithOptions(db string, mode os.FileMode, opts *Options) (BoltDB, error) {
+	bdb := &dbConnection{name: db, opts: opts}
+	if err := bdb.Open(db, mode); err != nil {
+		return nil, err
+	}
+	return bdb, nil
+}
+
+// 与OpenWithOptions相同，等待其它进程释放文件锁期间ctx结束时返回结束的原因
+func OpenCtx(ctx context.Context, db string, mode os.FileMode, opts *Options) (BoltDB, error) {
+	bdb := &dbConnection{name: db, opts: opts}
+	if err := bdb.open(ctx, db, mode); err != nil {
+		return nil, err
+	}
+	return bdb, nil
+}
+
+// 每次等待文件锁的时长，bolt内部每50ms重试一次
+const lockPoll = 100 * time.Millisecond
+
+// 以lockPoll为超时反复打开，直到成功、ctx结束或超过opts.Timeout
+func openPolling(ctx context.Context, path string, mode os.FileMode, opts *bolt.Options) (*bolt.DB, error) {
+	poll := *opts
+	poll.Timeout = lockPoll
+	start := time.Now()
+	for {
+		if err := ctxErr(ctx); err != nil {
+			return nil, err
+		}
+		db, err := bolt.Open(path, mode, &poll)
+		if !errors.Is(err, bolt.ErrTimeout) {
+			return db, err
+		}
+		if opts.Timeout > 0 && time.Since(start) >= opts.Timeout {
+			return nil, err
+		}
+	}
+}
+
+func (o *Options) boltOptions() *bolt.Options {
+	if o == nil {
+		return &bolt.Options{Timeout: o.lockTimeout()}
+	}
+	return &bolt.Options{
+		Timeout:         o.lockTimeout(),
+		ReadOnly:        o.ReadOnly,
+		NoGrowSync:      o.NoGrowSync,
+		InitialMmapSize: o.InitialMmapSize,
+		MmapFlags:       o.MmapFlags,
+	}
+}
+
+// 只读打开的连接上写操作不进入bolt，直接返回ErrReadOnly
+func (b *dbConnection) readOnly() bool {
+	return b.opts != nil && b.opts.ReadOnly
+}
+
+// 传给bolt的等待文件锁时长，0表示一直等待
+func (o *Options) lockTimeout() time.Duration {
+	switch {
+	case o == nil || o.Timeout == 0:
+		return defaultLockTimeout
+	case o.Timeout < 0:
+		return 0
+	}
+	return o.Timeout
+}
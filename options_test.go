@@ -0,0 +1,238 @@
+package bdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestOpenWithOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := OpenWithOptions(path, 0600, &Options{NoSync: true, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenWithOptions() failed, err=%v", err)
+	}
+	if !db.(*dbConnection).bdb.NoSync {
+		t.Errorf("NoSync not applied")
+	}
+	db.CreateTable("test")
+	db.Set("test", "k", "v")
+
+	// 文件已被锁定，第二次打开等待Timeout后失败
+	if _, err := OpenWithOptions(path, 0600, &Options{ReadOnly: true, Timeout: 50 * time.Millisecond}); !errors.Is(err, bolt.ErrTimeout) {
+		t.Errorf("OpenWithOptions() on locked file err=%v, want bolt.ErrTimeout", err)
+	}
+	db.Close()
+
+	ro, err := OpenWithOptions(path, 0600, &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("OpenWithOptions(ReadOnly) failed, err=%v", err)
+	}
+	defer ro.Close()
+	if got := string(ro.Get("test", "k")); got != "v" {
+		t.Errorf("ro.Get(k) == %q, want %q", got, "v")
+	}
+	if err := ro.Set("test", "k", "x"); !errors.Is(err, bolt.ErrDatabaseReadOnly) {
+		t.Errorf("ro.Set() err=%v, want bolt.ErrDatabaseReadOnly", err)
+	}
+}
+
+func TestAutoCreateTables(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Set("lazy", "k", "v"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.Set() on missing table err=%v, want ErrTableNotFound", err)
+	}
+	if err := db.Delete("lazy", "k"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.Delete() on missing table err=%v, want ErrTableNotFound", err)
+	}
+
+	db, err := OpenWithOptions(filepath.Join(t.TempDir(), "test.db"), 0600, &Options{AutoCreateTables: true})
+	if err != nil {
+		t.Fatalf("OpenWithOptions() failed, err=%v", err)
+	}
+	defer db.Close()
+	if err := db.Set("lazy", "k", "v"); err != nil {
+		t.Errorf("db.Set() failed, err=%v", err)
+	}
+	if err := db.Add("queue", "v"); err != nil {
+		t.Errorf("db.Add() failed, err=%v", err)
+	}
+	if err := db.AddWithKey("ids", 7, "v"); err != nil {
+		t.Errorf("db.AddWithKey() failed, err=%v", err)
+	}
+	if got := string(db.Get("lazy", "k")); got != "v" {
+		t.Errorf("db.Get(lazy, k) == %q, want %q", got, "v")
+	}
+	if got := string(db.Get("queue", "1")); got != "v" {
+		t.Errorf("db.Get(queue, 1) == %q, want %q", got, "v")
+	}
+
+	db.SetWriteBuffer(time.Hour, 100)
+	db.Set("buffered", "k", "v")
+	if err := db.Flush(); err != nil {
+		t.Errorf("db.Flush() failed, err=%v", err)
+	}
+	if _, err := db.GetValue("buffered", "k"); err != nil {
+		t.Errorf("db.GetValue(buffered, k) err=%v", err)
+	}
+}
+
+func TestBatchOption(t *testing.T) {
+	db, err := OpenWithOptions(filepath.Join(t.TempDir(), "test.db"), 0600,
+		&Options{Batch: true, MaxBatchSize: 50, MaxBatchDelay: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenWithOptions() failed, err=%v", err)
+	}
+	defer db.Close()
+	if bdb := db.(*dbConnection).bdb; bdb.MaxBatchSize != 50 || bdb.MaxBatchDelay != 5*time.Millisecond {
+		t.Errorf("batch options not applied: size=%d delay=%v", bdb.MaxBatchSize, bdb.MaxBatchDelay)
+	}
+	db.CreateTable("test")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := db.Set("test", i, i); err != nil {
+				errs <- err
+			}
+			if i%2 == 0 {
+				if err := db.Delete("test", i); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent write failed, err=%v", err)
+	}
+	if n, _ := db.Count("test"); n != 100 {
+		t.Errorf("db.Count() == %d, want 100", n)
+	}
+	if err := db.Set("missing", "k", "v"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.Set(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	if _, err := OpenReadOnly(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenReadOnly(missing) err=%v, want os.ErrNotExist", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenReadOnly(missing) left a file behind, err=%v", err)
+	}
+
+	db, err := Open(path, 0600)
+	if err != nil {
+		t.Fatalf("Open() failed, err=%v", err)
+	}
+	db.CreateTable("test")
+	db.Set("test", "k", "v")
+	db.Close()
+
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly() failed, err=%v", err)
+	}
+	defer ro.Close()
+	if got := string(ro.Get("test", "k")); got != "v" {
+		t.Errorf("ro.Get(k) == %q, want %q", got, "v")
+	}
+
+	// 共享锁，可以同时只读打开
+	ro2, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("second OpenReadOnly() failed, err=%v", err)
+	}
+	ro2.Close()
+
+	for name, write := range map[string]func() error{
+		"Set":         func() error { return ro.Set("test", "k", "x") },
+		"Delete":      func() error { return ro.Delete("test", "k") },
+		"CreateTable": func() error { return ro.CreateTable("other") },
+		"Begin": func() error {
+			_, err := ro.Begin(true)
+			return err
+		},
+		"Compact": func() error { return ro.Compact("") },
+	} {
+		if err := write(); !errors.Is(err, ErrReadOnly) || !errors.Is(err, bolt.ErrDatabaseReadOnly) {
+			t.Errorf("ro.%s() err=%v, want ErrReadOnly", name, err)
+		}
+	}
+	if txn, err := ro.Begin(false); err != nil {
+		t.Errorf("ro.Begin(false) failed, err=%v", err)
+	} else {
+		txn.Rollback()
+	}
+}
+
+func TestOpenCtx(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := OpenCtx(context.Background(), path, 0600, nil)
+	if err != nil {
+		t.Fatalf("OpenCtx() failed, err=%v", err)
+	}
+	defer db.Close()
+
+	// 文件被锁定，ctx到期时返回而不是一直等待
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := OpenCtx(ctx, path, 0600, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("OpenCtx() on locked file err=%v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("OpenCtx() on locked file took %v", d)
+	}
+}
+
+func TestOpenLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path, 0600)
+	if err != nil {
+		t.Fatalf("Open() failed, err=%v", err)
+	}
+	defer db.Close()
+
+	// 未指定Timeout时不会一直等待
+	defer func(d time.Duration) { defaultLockTimeout = d }(defaultLockTimeout)
+	defaultLockTimeout = 50 * time.Millisecond
+	if _, err := Open(path, 0600); !errors.Is(err, ErrLocked) || !errors.Is(err, bolt.ErrTimeout) {
+		t.Errorf("Open() on locked file err=%v, want ErrLocked", err)
+	}
+	if _, err := OpenWithOptions(path, 0600, &Options{Timeout: 50 * time.Millisecond}); !errors.Is(err, ErrLocked) {
+		t.Errorf("OpenWithOptions() on locked file err=%v, want ErrLocked", err)
+	}
+
+	// 负数一直等待，直到其它连接关闭
+	opened := make(chan error, 1)
+	go func() {
+		db2, err := OpenWithOptions(path, 0600, &Options{Timeout: -1})
+		if err == nil {
+			db2.Close()
+		}
+		opened <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	db.Close()
+	select {
+	case err := <-opened:
+		if err != nil {
+			t.Errorf("OpenWithOptions(Timeout: -1) err=%v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OpenWithOptions(Timeout: -1) did not return after the file was released")
+	}
+}
@@ -0,0 +1,42 @@
+//go:build otel
+
+package bdb
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 用tp为db的Get、GetValue、Has、Set、Delete、Scan和写事务生成span，需要用-tags otel编译。
+// span名为bdb.操作名，带有表名和键值大小的属性；GetCtx、SetCtx等传入的ctx中的span为父span。
+// 会替换db已设置的Tracer
+func EnableTracing(db BoltDB, tp trace.TracerProvider) {
+	db.SetTracer(&otelTracer{tracer: tp.Tracer("github.com/betterjun/bdb")})
+}
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+func (t *otelTracer) TraceOp(ctx context.Context, op, tn string, start, end time.Time, keySize, valueSize int, err error) {
+	attrs := []attribute.KeyValue{attribute.String("db.system", "boltdb")}
+	if tn != "" {
+		attrs = append(attrs,
+			attribute.String("bdb.table", tn),
+			attribute.Int("bdb.key_size", keySize),
+			attribute.Int("bdb.value_size", valueSize))
+	}
+	_, span := t.tracer.Start(ctx, "bdb."+op,
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
@@ -0,0 +1,194 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 分区的时间跨度
+type Period int
+
+const (
+	Hourly  Period = iota // base_2006_01_02_15
+	Daily                 // base_2006_01_02
+	Monthly               // base_2006_01
+	Yearly                // base_2006
+)
+
+// 分区名中时间部分的格式
+func (p Period) layout() string {
+	switch p {
+	case Hourly:
+		return "2006_01_02_15"
+	case Daily:
+		return "2006_01_02"
+	case Monthly:
+		return "2006_01"
+	case Yearly:
+		return "2006"
+	}
+	return ""
+}
+
+// t所在分区的起始时间
+func (p Period) start(t time.Time) time.Time {
+	t = t.UTC()
+	switch p {
+	case Hourly:
+		return t.Truncate(time.Hour)
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// 下一个分区的起始时间
+func (p Period) next(start time.Time) time.Time {
+	switch p {
+	case Hourly:
+		return start.Add(time.Hour)
+	case Daily:
+		return start.AddDate(0, 0, 1)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(1, 0, 0)
+}
+
+// 按时间分区的表，写入按时间落到base_<时间>表中，分区在第一次写入时创建。
+// 分区按UTC划分；base须是顶层表名，不能包含"."
+type PartitionedTable struct {
+	db     BoltDB
+	base   string
+	period Period
+
+	created sync.Map // 已创建的分区名 -> struct{}
+}
+
+// 以period为跨度分区的表base
+func OpenPartitioned(db BoltDB, base string, period Period) (*PartitionedTable, error) {
+	if period.layout() == "" {
+		return nil, fmt.Errorf("invalid partition period %d", period)
+	}
+	if base == "" || strings.Contains(base, ".") {
+		return nil, fmt.Errorf("invalid partitioned table name %q", base)
+	}
+	return &PartitionedTable{db: db, base: base, period: period}, nil
+}
+
+// t所在分区的表名
+func (p *PartitionedTable) Partition(t time.Time) string {
+	return p.base + "_" + p.period.start(t).Format(p.period.layout())
+}
+
+// 解析分区表名的起始时间，不是本表的分区时ok为false
+func (p *PartitionedTable) parse(tn string) (start time.Time, ok bool) {
+	stamp, found := strings.CutPrefix(tn, p.base+"_")
+	if !found {
+		return start, false
+	}
+	start, err := time.Parse(p.period.layout(), stamp)
+	return start, err == nil
+}
+
+// 写入t所在的分区，分区不存在时创建。分区被其它途径删除后再写入时重新创建
+func (p *PartitionedTable) Set(t time.Time, key, value interface{}) error {
+	tn := p.Partition(t)
+	if _, ok := p.created.Load(tn); ok {
+		err := p.db.Set(tn, key, value)
+		if !errors.Is(err, ErrTableNotFound) {
+			return err
+		}
+		p.created.Delete(tn)
+	}
+	if err := p.db.CreateTable(tn); err != nil {
+		return err
+	}
+	p.created.Store(tn, struct{}{})
+	return p.db.Set(tn, key, value)
+}
+
+// 读取t所在分区中的键，分区不存在时返回ErrTableNotFound
+func (p *PartitionedTable) Get(t time.Time, key interface{}) ([]byte, error) {
+	return p.db.GetValue(p.Partition(t), key)
+}
+
+// 删除t所在分区中的键
+func (p *PartitionedTable) Delete(t time.Time, key interface{}) error {
+	return p.db.Delete(p.Partition(t), key)
+}
+
+// 与[from, to)有交集的已有分区，按时间先后排序
+func (p *PartitionedTable) Partitions(from, to time.Time) ([]string, error) {
+	tables, err := p.db.ListTables()
+	if err != nil {
+		return nil, err
+	}
+
+	type part struct {
+		name  string
+		start time.Time
+	}
+	var parts []part
+	for _, tn := range tables {
+		start, ok := p.parse(tn)
+		if ok && start.Before(to) && p.period.next(start).After(from) {
+			parts = append(parts, part{tn, start})
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].start.Before(parts[j].start) })
+
+	names := make([]string, len(parts))
+	for i, part := range parts {
+		names[i] = part.name
+	}
+	return names, nil
+}
+
+// 按时间先后遍历与[from, to)有交集的分区，分区内按键序。分区整体参与遍历，
+// 不按键过滤时间，键中带时间时由fn自行判断。fn返回错误时停止并返回该错误
+func (p *PartitionedTable) Scan(from, to time.Time, fn func(tn string, k, v []byte) error) error {
+	names, err := p.Partitions(from, to)
+	if err != nil {
+		return err
+	}
+	for _, tn := range names {
+		err := p.db.Scan(tn, nil, func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			return fn(tn, k, v)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 删除整个分区都早于cutoff的分区，返回删除的分区数
+func (p *PartitionedTable) DropPartitionsOlderThan(cutoff time.Time) (int, error) {
+	tables, err := p.db.ListTables()
+	if err != nil {
+		return 0, err
+	}
+	dropped := 0
+	for _, tn := range tables {
+		start, ok := p.parse(tn)
+		if !ok || p.period.next(start).After(cutoff) {
+			continue
+		}
+		if err := p.db.DeleteTable(tn); err != nil {
+			return dropped, err
+		}
+		p.created.Delete(tn)
+		dropped++
+	}
+	return dropped, nil
+}
@@ -0,0 +1,78 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPartitionedTable(t *testing.T) {
+	db := openTestDB(t, "events_other")
+	p, err := OpenPartitioned(db, "events", Monthly)
+	if err != nil {
+		t.Fatalf("OpenPartitioned() failed, err=%v", err)
+	}
+
+	jun := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	jul := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	sep := time.Date(2024, 9, 30, 23, 0, 0, 0, time.UTC)
+	if got := p.Partition(jun); got != "events_2024_06" {
+		t.Errorf("p.Partition(jun) == %q, want events_2024_06", got)
+	}
+	for _, ts := range []time.Time{sep, jun, jul} {
+		if err := p.Set(ts, ts.Format(time.RFC3339), ts.Month().String()); err != nil {
+			t.Fatalf("p.Set(%v) failed, err=%v", ts, err)
+		}
+	}
+	if v, err := p.Get(jun, jun.Format(time.RFC3339)); err != nil || string(v) != "June" {
+		t.Errorf("p.Get(jun) == %q, %v, want June, nil", v, err)
+	}
+	if _, err := p.Get(time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC), "k"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("p.Get(aug) err=%v, want ErrTableNotFound", err)
+	}
+
+	names, err := p.Partitions(time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), sep)
+	if err != nil || len(names) != 3 || names[0] != "events_2024_06" || names[2] != "events_2024_09" {
+		t.Errorf("p.Partitions() == %v, %v, want 2024_06, 2024_07, 2024_09", names, err)
+	}
+
+	var months []string
+	p.Scan(jul, sep.Add(time.Hour), func(tn string, k, v []byte) error {
+		months = append(months, string(v))
+		return nil
+	})
+	if len(months) != 2 || months[0] != "July" || months[1] != "September" {
+		t.Errorf("p.Scan(jul, sep) == %v, want [July September]", months)
+	}
+
+	// 7月的分区到8月1日才整体过期
+	if n, err := p.DropPartitionsOlderThan(time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)); err != nil || n != 1 {
+		t.Errorf("p.DropPartitionsOlderThan(jul 31) == %d, %v, want 1, nil", n, err)
+	}
+	if names, _ := p.Partitions(time.Time{}, sep.Add(time.Hour)); len(names) != 2 {
+		t.Errorf("p.Partitions() after drop == %v, want 2", names)
+	}
+	if ok, err := db.Has("events_other", "k"); err != nil || ok {
+		t.Errorf("unrelated table touched, has=%v err=%v", ok, err)
+	}
+
+	// 删除的分区再次写入时重新创建
+	if err := p.Set(jun, "again", "v"); err != nil {
+		t.Errorf("p.Set() into dropped partition err=%v", err)
+	}
+	// 在p之外删除的分区也一样
+	db.DeleteTable("events_2024_09")
+	if err := p.Set(sep, "again", "v"); err != nil {
+		t.Errorf("p.Set() after DeleteTable err=%v", err)
+	}
+	if v, err := p.Get(sep, "again"); err != nil || string(v) != "v" {
+		t.Errorf("p.Get() after recreate == %q, %v, want v, nil", v, err)
+	}
+
+	if _, err := OpenPartitioned(db, "a.b", Daily); err == nil {
+		t.Errorf("OpenPartitioned(a.b) err=nil, want error")
+	}
+	if got := (&PartitionedTable{base: "x", period: Hourly}).Partition(jun); got != "x_2024_06_15_12" {
+		t.Errorf("Hourly partition == %q, want x_2024_06_15_12", got)
+	}
+}
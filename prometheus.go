@@ -0,0 +1,103 @@
+//go:build prometheus
+
+package bdb
+
+import (
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 把db的指标注册到reg，需要用-tags prometheus编译。
+// 包括单条操作和写事务的耗时直方图、出错次数，以及空闲页数和文件大小，
+// 所有指标带有值为文件路径的db标签，同一个reg可以注册多个db。
+// 注册失败时撤销已注册的指标；成功时会替换db已设置的观察者
+func RegisterPrometheus(db BoltDB, reg prometheus.Registerer) error {
+	labels := prometheus.Labels{"db": db.GetDBName()}
+	m := &promObserver{
+		ops: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   "bdb",
+			ConstLabels: labels,
+			Name:        "op_duration_seconds",
+			Help:        "Latency of single-record operations.",
+			Buckets:     prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"op", "table"}),
+		errs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "bdb",
+			ConstLabels: labels,
+			Name:        "op_errors_total",
+			Help:        "Failed single-record operations.",
+		}, []string{"op", "table"}),
+		commits: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   "bdb",
+			ConstLabels: labels,
+			Name:        "tx_commit_duration_seconds",
+			Help:        "Duration of write transactions including commit.",
+			Buckets:     prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}),
+		commitErrs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "bdb",
+			ConstLabels: labels,
+			Name:        "tx_commit_errors_total",
+			Help:        "Failed write transactions.",
+		}),
+	}
+	freePages := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   "bdb",
+		ConstLabels: labels,
+		Name:        "free_pages",
+		Help:        "Free and pending pages in the database file.",
+	}, func() float64 {
+		_, _, free, err := db.PageInfo()
+		if err != nil {
+			return 0
+		}
+		return float64(free)
+	})
+	size := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   "bdb",
+		ConstLabels: labels,
+		Name:        "db_size_bytes",
+		Help:        "Size of the database file.",
+	}, func() float64 {
+		fi, err := os.Stat(db.GetDBName())
+		if err != nil {
+			return 0
+		}
+		return float64(fi.Size())
+	})
+
+	collectors := []prometheus.Collector{m.ops, m.errs, m.commits, m.commitErrs, freePages, size}
+	for i, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			for _, done := range collectors[:i] {
+				reg.Unregister(done)
+			}
+			return err
+		}
+	}
+	db.SetObserver(m)
+	return nil
+}
+
+type promObserver struct {
+	ops        *prometheus.HistogramVec
+	errs       *prometheus.CounterVec
+	commits    prometheus.Histogram
+	commitErrs prometheus.Counter
+}
+
+func (m *promObserver) ObserveOp(op, tn string, d time.Duration, err error) {
+	m.ops.WithLabelValues(op, tn).Observe(d.Seconds())
+	if err != nil {
+		m.errs.WithLabelValues(op, tn).Inc()
+	}
+}
+
+func (m *promObserver) ObserveCommit(d time.Duration, err error) {
+	m.commits.Observe(d.Seconds())
+	if err != nil {
+		m.commitErrs.Inc()
+	}
+}
@@ -0,0 +1,267 @@
+package bdb
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/boltdb/bolt"
+)
+
+// 对表中存储的结构体按字段筛选、排序，值用表的编解码方式解码。
+// 用法为db.Query("users").Where("Age", ">", 30).Limit(50).Run(&results)
+type Query struct {
+	b     *dbConnection
+	tn    string
+	conds []condition
+	order string
+	desc  bool
+	limit int
+	err   error // Where等构造时的第一个错误，Run时返回
+}
+
+type condition struct {
+	field string
+	op    string
+	value interface{}
+}
+
+// 开始一个对表tn的查询
+func (b *dbConnection) Query(tn string) *Query {
+	return &Query{b: b, tn: tn}
+}
+
+// 增加一个条件，多个条件之间为且。op为=、==、!=、<、<=、>、>=，字段按Go的字段名指定。
+// 有CreateIndexes为该字段声明的索引、且value能无损地转换为字段的类型时，=条件经由索引查找，
+// 否则扫描全表，两种方式的结果相同
+func (q *Query) Where(field, op string, value interface{}) *Query {
+	switch op {
+	case "=", "==", "!=", "<", "<=", ">", ">=":
+	default:
+		if q.err == nil {
+			q.err = fmt.Errorf("invalid query operator %q", op)
+		}
+	}
+	q.conds = append(q.conds, condition{field: field, op: op, value: value})
+	return q
+}
+
+// 按字段排序，desc为true时倒序。不指定时按主键的字节序
+func (q *Query) OrderBy(field string, desc bool) *Query {
+	q.order, q.desc = field, desc
+	return q
+}
+
+// 最多返回n条，n<=0表示不限
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// 执行查询，out须是结构体切片或结构体指针切片的指针
+func (q *Query) Run(out interface{}) error {
+	if q.err != nil {
+		return q.err
+	}
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("query result must be a pointer to slice, got %T", out)
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("query result element must be a struct, got %v", elemType)
+	}
+	for _, c := range q.conds {
+		if _, ok := structType.FieldByName(c.field); !ok {
+			return fmt.Errorf("%v has no field %v", structType, c.field)
+		}
+	}
+	if _, ok := structType.FieldByName(q.order); q.order != "" && !ok {
+		return fmt.Errorf("%v has no field %v", structType, q.order)
+	}
+
+	// 不排序时扫描到limit条即可停止
+	max := q.limit
+	if q.order != "" {
+		max = 0
+	}
+	codec := q.b.codec(q.tn)
+	var matched []reflect.Value
+	match := func(k, v []byte) (bool, error) {
+		obj := reflect.New(structType)
+		if err := codec.Unmarshal(v, obj.Interface()); err != nil {
+			return false, fmt.Errorf("decode %v.%q failed: %w", q.tn, k, err)
+		}
+		for _, c := range q.conds {
+			ok, err := c.match(obj.Elem().FieldByName(c.field))
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		matched = append(matched, obj)
+		return max > 0 && len(matched) >= max, nil
+	}
+
+	var err error
+	if c, iv, ok := q.indexed(structType); ok {
+		var kvs []KV
+		if kvs, err = q.b.GetByIndex(q.tn, c.field, iv); err == nil {
+			for _, kv := range kvs {
+				if done, err2 := match(kv.Key, kv.Value); err2 != nil || done {
+					err = err2
+					break
+				}
+			}
+		}
+	} else {
+		err = q.b.view(func(tx *bolt.Tx) error {
+			bucket, err := getBucket(tx, q.tn)
+			if err != nil {
+				return err
+			}
+			c := bucket.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				if v == nil {
+					continue
+				}
+				if done, err := match(k, v); err != nil || done {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if q.order != "" {
+		var sortErr error
+		sort.SliceStable(matched, func(i, j int) bool {
+			n, err := compare(matched[i].Elem().FieldByName(q.order), matched[j].Elem().FieldByName(q.order).Interface())
+			if err != nil {
+				sortErr = err
+			}
+			if q.desc {
+				return n > 0
+			}
+			return n < 0
+		})
+		if sortErr != nil {
+			return sortErr
+		}
+	}
+	if q.limit > 0 && len(matched) > q.limit {
+		matched = matched[:q.limit]
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(matched))
+	for _, obj := range matched {
+		if elemType.Kind() == reflect.Ptr {
+			result = reflect.Append(result, obj)
+		} else {
+			result = reflect.Append(result, obj.Elem())
+		}
+	}
+	slice.Set(result)
+	return nil
+}
+
+// 返回可以经由索引查找的=条件和按索引的方式编码的值
+func (q *Query) indexed(structType reflect.Type) (condition, interface{}, bool) {
+	for _, c := range q.conds {
+		if c.op != "=" && c.op != "==" {
+			continue
+		}
+		sf, _ := structType.FieldByName(c.field)
+		for _, idx := range q.b.indexes.of(q.tn) {
+			if idx.name != c.field || idx.field != sf.Type {
+				continue
+			}
+			if iv, ok := indexValue(sf.Type, c.value); ok {
+				return c, iv, true
+			}
+		}
+	}
+	return condition{}, nil, false
+}
+
+// 把value转换为字段类型t后按fieldKey编码，转换有损(如30.5转为int)或不支持时返回false
+func indexValue(t reflect.Type, value interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !v.Type().ConvertibleTo(t) {
+		return nil, false
+	}
+	f := v.Convert(t)
+	if n, err := compare(f, value); err != nil || n != 0 {
+		return nil, false
+	}
+	key, err := fieldKey(f)
+	if err != nil {
+		return nil, false
+	}
+	return key, true
+}
+
+func (c condition) match(f reflect.Value) (bool, error) {
+	n, err := compare(f, c.value)
+	if err != nil {
+		return false, fmt.Errorf("field %v: %v", c.field, err)
+	}
+	switch c.op {
+	case "=", "==":
+		return n == 0, nil
+	case "!=":
+		return n != 0, nil
+	case "<":
+		return n < 0, nil
+	case "<=":
+		return n <= 0, nil
+	case ">":
+		return n > 0, nil
+	default:
+		return n >= 0, nil
+	}
+}
+
+// 比较字段值f与value，支持字符串、整数、浮点数和bool(只区分相等与否)
+func compare(f reflect.Value, value interface{}) (int, error) {
+	v := reflect.ValueOf(value)
+	switch {
+	case f.Kind() == reflect.String && v.Kind() == reflect.String:
+		return cmp.Compare(f.String(), v.String()), nil
+	case f.Kind() == reflect.Bool && v.Kind() == reflect.Bool:
+		if f.Bool() == v.Bool() {
+			return 0, nil
+		}
+		return 1, nil
+	case f.CanInt() && v.CanInt():
+		return cmp.Compare(f.Int(), v.Int()), nil
+	case f.CanUint() && v.CanUint():
+		return cmp.Compare(f.Uint(), v.Uint()), nil
+	}
+	if a, ok := toFloat(f); ok {
+		if b, ok := toFloat(v); ok {
+			return cmp.Compare(a, b), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %v with %T", f.Type(), value)
+}
+
+func toFloat(v reflect.Value) (float64, bool) {
+	switch {
+	case v.CanInt():
+		return float64(v.Int()), true
+	case v.CanUint():
+		return float64(v.Uint()), true
+	case v.CanFloat():
+		return v.Float(), true
+	}
+	return 0, false
+}
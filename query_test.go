@@ -0,0 +1,141 @@
+package bdb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type person struct {
+	ID   string `bdb:"key"`
+	Name string
+	Age  int
+	City string `bdb:"index"`
+}
+
+func TestQuery(t *testing.T) {
+	db := openTestDB(t, "people")
+	for _, p := range []person{
+		{"1", "ann", 31, "paris"},
+		{"2", "bob", 25, "rome"},
+		{"3", "cat", 40, "paris"},
+		{"4", "dan", 35, "oslo"},
+	} {
+		db.Save("people", p)
+	}
+
+	names := func(ps []person) string {
+		var ret []string
+		for _, p := range ps {
+			ret = append(ret, p.Name)
+		}
+		return fmt.Sprint(ret)
+	}
+
+	var tests = []struct {
+		q    *Query
+		want string
+	}{
+		{db.Query("people").Where("Age", ">", 30), "[ann cat dan]"},
+		{db.Query("people").Where("Age", ">", 30).Limit(2), "[ann cat]"},
+		{db.Query("people").Where("Age", ">=", 25).OrderBy("Age", true).Limit(2), "[cat dan]"},
+		{db.Query("people").Where("City", "=", "paris").Where("Age", "<", 35), "[ann]"},
+		{db.Query("people").Where("Name", "!=", "bob").OrderBy("Name", false), "[ann cat dan]"},
+		{db.Query("people").Where("Age", ">", 30.5), "[ann cat dan]"},
+		{db.Query("people").Where("Age", ">", 100), "[]"},
+	}
+	for i, test := range tests {
+		var got []person
+		if err := test.q.Run(&got); err != nil || names(got) != test.want {
+			t.Errorf("query %d == %v, %v, want %v", i, names(got), err, test.want)
+		}
+	}
+
+	// 有索引时=条件走索引
+	if err := db.CreateIndexes("people", person{}); err != nil {
+		t.Fatalf("db.CreateIndexes() failed, err=%v", err)
+	}
+	q := db.Query("people").Where("City", "=", "paris")
+	if _, _, ok := q.indexed(reflect.TypeOf(person{})); !ok {
+		t.Errorf("query on City does not use the index")
+	}
+	var ptrs []*person
+	if err := q.OrderBy("Age", true).Run(&ptrs); err != nil || len(ptrs) != 2 || ptrs[0].Name != "cat" {
+		t.Errorf("indexed query == %v, %v", ptrs, err)
+	}
+
+	var got []person
+	for _, q := range []*Query{
+		db.Query("people").Where("Age", "~", 1),
+		db.Query("people").Where("Missing", "=", 1),
+		db.Query("people").Where("Age", "=", "x"),
+		db.Query("people").OrderBy("Missing", false),
+	} {
+		if err := q.Run(&got); err == nil {
+			t.Errorf("bad query succeeded")
+		}
+	}
+	if err := db.Query("people").Run(got); err == nil {
+		t.Errorf("Run(non-pointer) succeeded")
+	}
+}
+
+type ageRecord struct {
+	ID   string `bdb:"key"`
+	Name string
+	Age  int `bdb:"index"`
+}
+
+func TestQueryIndexMatchesScan(t *testing.T) {
+	db := openTestDB(t, "records")
+	for _, m := range []ageRecord{{"1", "ann", 30}, {"2", "bob", 31}, {"3", "cat", 30}} {
+		db.Save("records", m)
+	}
+	queries := func() []*Query {
+		return []*Query{
+			db.Query("records").Where("Age", "=", 30),
+			db.Query("records").Where("Age", "=", 30.0),
+			db.Query("records").Where("Age", "==", int64(30)),
+			db.Query("records").Where("Age", "=", uint8(31)),
+			db.Query("records").Where("Age", "=", 30.5),
+			db.Query("records").Where("Name", "=", "ann"),
+		}
+	}
+	run := func(qs []*Query) []string {
+		var ret []string
+		for i, q := range qs {
+			var got []ageRecord
+			if err := q.Run(&got); err != nil {
+				t.Fatalf("query %d failed, err=%v", i, err)
+			}
+			var ids []string
+			for _, m := range got {
+				ids = append(ids, m.ID)
+			}
+			ret = append(ret, fmt.Sprint(ids))
+		}
+		return ret
+	}
+
+	scanned := run(queries())
+	if err := db.CreateIndexes("records", ageRecord{}); err != nil {
+		t.Fatalf("db.CreateIndexes() failed, err=%v", err)
+	}
+	// 与字段同名但不是按字段声明的索引不能用来查找
+	db.CreateIndex("records", "Name", func(k, v []byte) ([]interface{}, error) {
+		return []interface{}{"other"}, nil
+	})
+	qs := queries()
+	if _, _, ok := qs[1].indexed(reflect.TypeOf(ageRecord{})); !ok {
+		t.Errorf("query on Age = 30.0 does not use the index")
+	}
+	if _, _, ok := qs[5].indexed(reflect.TypeOf(ageRecord{})); ok {
+		t.Errorf("query on Name uses an index not declared for the field")
+	}
+	indexed := run(qs)
+	for i := range scanned {
+		if indexed[i] != scanned[i] {
+			t.Errorf("query %d with index == %v, scan == %v", i, indexed[i], scanned[i])
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// 在一个写事务中从srcQueue头部取出最多n项，按原来的顺序追加到dstTable的队尾，返回移动的条数。
+// 两张表的键都是Queue的8字节大端序号，srcQueue可以是Queue或AddWithKey写入的表，
+// dstTable取出的项可以用Queue接着读。其他长度的键不属于队列，跳过
+func (b *dbConnection) DrainBatch(srcQueue, dstTable string, n int) (moved int, err error) {
+	if srcQueue == dstTable {
+		return 0, fmt.Errorf("cannot drain %v into itself", srcQueue)
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		moved = 0
+		src, err := getBucket(tx, srcQueue)
+		if err != nil {
+			return err
+		}
+		dst, err := getBucket(tx, dstTable)
+		if err != nil {
+			return err
+		}
+
+		keys, err := frontSeqKeys(src, srcQueue, n)
+		if err != nil {
+			return err
+		}
+		last := lastSeq(&iterator{c: dst.Cursor()})
+		for _, k := range keys {
+			dk, err := nextSeqKey(last)
+			if err != nil {
+				return err
+			}
+			if dst.Get(dk) != nil {
+				return fmt.Errorf("%v.%q already exists", dstTable, dk)
+			}
+			stored, err := b.putValue(tx, dst, dstTable, dk, src.Get(k))
+			if err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", dstTable, dk, err)
+			}
+			if err := b.del(tx, src, srcQueue, k); err != nil {
+				return fmt.Errorf("delete %v.%q failed: %w", srcQueue, k, err)
+			}
+			err = b.mirrorWrite(tx, func(m BoltDB) error {
+				if err := m.Set(dstTable, dk, stored); err != nil {
+					return err
+				}
+				return m.Delete(srcQueue, k)
+			})
+			if err != nil {
+				return err
+			}
+			last = dk
+			moved++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// 表中前n个8字节序号键的拷贝，只读取到第n个为止
+func frontSeqKeys(bucket *bolt.Bucket, tn string, n int) ([][]byte, error) {
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil && len(keys) < n; k, v = c.Next() {
+		if len(k) != 8 {
+			continue
+		}
+		if v == nil {
+			return nil, fmt.Errorf("%v.%q is a sub table", tn, k)
+		}
+		keys = append(keys, append([]byte{}, k...))
+	}
+	return keys, nil
+}
+
+// Queue、Stack中没有元素时返回
+var ErrEmpty = errors.New("no items")
+
+// 先进先出的持久队列，每项的键是8字节大端序号，按键序就是入队顺序。
+// 序号接着当前最大的序号递增，队列清空后从1重新开始，不会因十进制键的排序而乱序。
+// 与DrainBatch、AddWithKey的键相同。表中其他长度的键不属于队列，各个方法都跳过它们
+type Queue struct {
+	db   BoltDB
+	name string
+}
+
+// 打开名为name的队列，表不存在时创建
+func OpenQueue(db BoltDB, name string) (*Queue, error) {
+	if err := db.CreateTable(name); err != nil {
+		return nil, err
+	}
+	return &Queue{db: db, name: name}, nil
+}
+
+// 表名
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// 追加到队尾
+func (q *Queue) Enqueue(value interface{}) error {
+	return q.db.Update(q.name, func(t Table) error {
+		k, err := nextSeqKey(lastSeq(t.Cursor()))
+		if err != nil {
+			return err
+		}
+		if _, err := t.Get(k); err == nil {
+			return fmt.Errorf("%v.%q already exists", q.name, k)
+		}
+		return t.Set(k, value)
+	})
+}
+
+// 在一个写事务中取出并删除队头，队列为空时返回ErrEmpty
+func (q *Queue) Dequeue() (value []byte, err error) {
+	err = q.db.Update(q.name, func(t Table) error {
+		k, v := seqItem(t.Cursor(), true)
+		if k == nil {
+			return ErrEmpty
+		}
+		if v == nil {
+			return fmt.Errorf("%v.%q is a sub table", q.name, k)
+		}
+		value = append([]byte{}, v...)
+		return t.Delete(k)
+	})
+	return value, err
+}
+
+// 返回队头但不取出，队列为空时返回ErrEmpty
+func (q *Queue) Peek() (value []byte, err error) {
+	err = q.db.View(q.name, func(t Table) error {
+		k, v := seqItem(t.Cursor(), true)
+		if k == nil {
+			return ErrEmpty
+		}
+		if v == nil {
+			return fmt.Errorf("%v.%q is a sub table", q.name, k)
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, err
+}
+
+// 队列中的项数，需要遍历整个表
+func (q *Queue) Len() (n int, err error) {
+	err = q.db.View(q.name, func(t Table) error {
+		for it := t.Cursor(); it.Valid(); it.Next() {
+			if len(it.Key()) == 8 {
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// 从游标当前位置起向前或向后第一个8字节的序号键，其他长度的键跳过
+func seqItem(it Iterator, forward bool) (k, v []byte) {
+	for it.Valid() {
+		if len(it.Key()) == 8 {
+			return it.Key(), it.Value()
+		}
+		if forward {
+			it.Next()
+		} else {
+			it.Prev()
+		}
+	}
+	return nil, nil
+}
+
+var maxSeqKey = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// 表中最大的8字节序号键，没有时为nil。比它大的只可能是以8个0xff开头的更长的键
+func lastSeq(it Iterator) []byte {
+	it.Seek(maxSeqKey)
+	if !it.Valid() {
+		it.Last()
+	} else if !bytes.Equal(it.Key(), maxSeqKey) {
+		it.Prev()
+	}
+	k, _ := seqItem(it, false)
+	return k
+}
+
+// last之后的序号键，last不是8字节序号(如nil)时从1开始
+func nextSeqKey(last []byte) ([]byte, error) {
+	var n uint64
+	if len(last) == 8 {
+		n = binary.BigEndian.Uint64(last)
+	}
+	if n == ^uint64(0) {
+		return nil, errors.New("sequence exhausted")
+	}
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, n+1)
+	return k, nil
+}
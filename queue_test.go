@@ -0,0 +1,142 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestDrainBatch(t *testing.T) {
+	db := openTestDB(t, "jobs", "claimed")
+	src, _ := OpenQueue(db, "jobs")
+	for i := 1; i <= 5; i++ {
+		src.Enqueue(fmt.Sprintf("job%d", i))
+	}
+	dst, _ := OpenQueue(db, "claimed")
+
+	moved, err := db.DrainBatch("jobs", "claimed", 3)
+	if err != nil || moved != 3 {
+		t.Fatalf("db.DrainBatch(3) == %d, err=%v, want 3", moved, err)
+	}
+	for _, want := range []string{"job1", "job2"} {
+		if got, err := dst.Dequeue(); err != nil || string(got) != want {
+			t.Errorf("dst.Dequeue() == %q, %v, want %q", got, err, want)
+		}
+	}
+	if got, _ := src.Peek(); string(got) != "job4" {
+		t.Errorf("src.Peek() == %q after drain, want job4", got)
+	}
+
+	moved, err = db.DrainBatch("jobs", "claimed", 10)
+	if err != nil || moved != 2 {
+		t.Errorf("db.DrainBatch(10) == %d, err=%v, want 2", moved, err)
+	}
+	// 接在dst剩下的job3之后
+	for _, want := range []string{"job3", "job4", "job5"} {
+		if got, err := dst.Dequeue(); err != nil || string(got) != want {
+			t.Errorf("dst.Dequeue() == %q, %v, want %q", got, err, want)
+		}
+	}
+
+	moved, err = db.DrainBatch("jobs", "claimed", 10)
+	if err != nil || moved != 0 {
+		t.Errorf("db.DrainBatch(empty) == %d, err=%v, want 0", moved, err)
+	}
+	if _, err = db.DrainBatch("jobs", "missing", 1); err == nil {
+		t.Errorf("db.DrainBatch(missing dst) should fail")
+	}
+}
+
+func TestDrainBatchOrder(t *testing.T) {
+	db := openTestDB(t, "jobs", "claimed")
+	src, _ := OpenQueue(db, "jobs")
+	for i := 1; i <= 25; i++ {
+		src.Enqueue(fmt.Sprintf("job%d", i))
+	}
+	// 不是队列的键和AddWithKey写入的键
+	db.Set("jobs", "x", "plain")
+	db.AddWithKey("jobs", 1000, "external")
+
+	for _, n := range []int{3, 9, 14} {
+		if moved, err := db.DrainBatch("jobs", "claimed", n); err != nil || moved != n {
+			t.Fatalf("db.DrainBatch(%d) == %d, err=%v, want %d", n, moved, err, n)
+		}
+	}
+	dst, _ := OpenQueue(db, "claimed")
+	for i := 1; i <= 25; i++ {
+		want := fmt.Sprintf("job%d", i)
+		if got, err := dst.Dequeue(); err != nil || string(got) != want {
+			t.Errorf("dst.Dequeue() == %q, %v, want %q", got, err, want)
+		}
+	}
+	if got, err := dst.Dequeue(); err != nil || string(got) != "external" {
+		t.Errorf("dst.Dequeue() == %q, %v, want external", got, err)
+	}
+	if got := string(db.Get("jobs", "x")); got != "plain" {
+		t.Errorf("db.Get(jobs, x) == %q, want plain", got)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	db := openTestDB(t)
+	q, err := OpenQueue(db, "jobs")
+	if err != nil {
+		t.Fatalf("OpenQueue() failed, err=%v", err)
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("q.Dequeue() on empty err=%v, want ErrEmpty", err)
+	}
+	if _, err := q.Peek(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("q.Peek() on empty err=%v, want ErrEmpty", err)
+	}
+
+	// 超过一位数后仍按入队顺序
+	for i := 0; i < 12; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatalf("q.Enqueue(%d) failed, err=%v", i, err)
+		}
+	}
+	if n, err := q.Len(); err != nil || n != 12 {
+		t.Errorf("q.Len() == %d, %v, want 12, nil", n, err)
+	}
+	if v, err := q.Peek(); err != nil || string(v) != "0" {
+		t.Errorf("q.Peek() == %q, %v, want \"0\", nil", v, err)
+	}
+	for i := 0; i < 12; i++ {
+		v, err := q.Dequeue()
+		if err != nil || string(v) != strconv.Itoa(i) {
+			t.Fatalf("q.Dequeue() == %q, %v, want %d", v, err, i)
+		}
+	}
+	if n, _ := q.Len(); n != 0 {
+		t.Errorf("q.Len() after drain == %d, want 0", n)
+	}
+}
+
+func TestQueueForeignKeys(t *testing.T) {
+	db := openTestDB(t)
+	q, _ := OpenQueue(db, "jobs")
+	q.Enqueue("first")
+	q.Enqueue("second")
+	// 不是队列序号的键不影响序号的分配和出队
+	db.Set("jobs", "x", "plain")
+	db.Set("jobs", "\x00", "low")
+	db.Add("jobs", "decimal")
+	q.Enqueue("third")
+	if n, err := q.Len(); err != nil || n != 3 {
+		t.Errorf("q.Len() == %d, %v, want 3", n, err)
+	}
+
+	for _, want := range []string{"first", "second", "third"} {
+		if v, err := q.Dequeue(); err != nil || string(v) != want {
+			t.Errorf("q.Dequeue() == %q, %v, want %q", v, err, want)
+		}
+	}
+	if _, err := q.Dequeue(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("q.Dequeue() after drain err=%v, want ErrEmpty", err)
+	}
+	if got := string(db.Get("jobs", "x")); got != "plain" {
+		t.Errorf("db.Get(x) == %q, want plain", got)
+	}
+}
@@ -0,0 +1,126 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// 写入超出表的配额
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// 表的配额，为0的项不限制
+type Quota struct {
+	MaxKeys      int   // 最多的键数，子表及其中的键也计算在内
+	MaxBytes     int64 // 键值最多占用的字节数，按页中已用的字节估算
+	MaxValueSize int   // 单个值的最大长度
+}
+
+// 各表的配额
+type quotas struct {
+	mu     sync.RWMutex
+	tables map[string]Quota
+
+	// bucket.Stats()只统计已提交的页，同一事务中之前的写入由这里累计
+	usageMu sync.Mutex
+	usage   map[*bolt.Tx]map[string]*quotaUsage
+}
+
+type quotaUsage struct {
+	keys  int
+	bytes int64
+}
+
+// 设置表tn的配额，Quota{}取消限制。只检查之后的单条写入，已有的数据不受影响；
+// MaxKeys和MaxBytes需要在每次写入时统计表的页，有配额的表写入变慢
+func (b *dbConnection) SetQuota(tn string, q Quota) {
+	b.quotas.mu.Lock()
+	defer b.quotas.mu.Unlock()
+	if q == (Quota{}) {
+		delete(b.quotas.tables, tn)
+		return
+	}
+	if b.quotas.tables == nil {
+		b.quotas.tables = make(map[string]Quota)
+	}
+	b.quotas.tables[tn] = q
+}
+
+func (qs *quotas) of(tn string) (Quota, bool) {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	q, ok := qs.tables[tn]
+	return q, ok
+}
+
+// 在put中调用，检查把k写为v之后是否超出配额，old为原来的值
+func (b *dbConnection) checkQuota(bucket *bolt.Bucket, tn string, k, old, v []byte) error {
+	q, ok := b.quotas.of(tn)
+	if !ok {
+		return nil
+	}
+	if q.MaxValueSize > 0 && len(v) > q.MaxValueSize {
+		return fmt.Errorf("value of %d bytes exceeds %d in table %v:%w", len(v), q.MaxValueSize, tn, ErrQuotaExceeded)
+	}
+	if q.MaxKeys <= 0 && q.MaxBytes <= 0 {
+		return nil
+	}
+
+	u := b.quotas.usageOf(bucket, tn)
+	keys, grow := 0, int64(len(v))
+	if old == nil {
+		keys, grow = 1, grow+int64(len(k))
+	} else {
+		grow -= int64(len(old))
+	}
+	if q.MaxKeys > 0 && u.keys+keys > q.MaxKeys {
+		return fmt.Errorf("table %v already has %d keys:%w", tn, u.keys, ErrQuotaExceeded)
+	}
+	if q.MaxBytes > 0 && grow > 0 && u.bytes+grow > q.MaxBytes {
+		return fmt.Errorf("table %v uses %d bytes, limit %d:%w", tn, u.bytes, q.MaxBytes, ErrQuotaExceeded)
+	}
+	u.keys += keys
+	u.bytes += grow
+	return nil
+}
+
+// 在remove中调用，删除了值为old的键k
+func (b *dbConnection) releaseQuota(bucket *bolt.Bucket, tn string, k, old []byte) {
+	if _, ok := b.quotas.of(tn); !ok || old == nil {
+		return
+	}
+	u := b.quotas.usageOf(bucket, tn)
+	u.keys--
+	u.bytes -= int64(len(k) + len(old))
+}
+
+// 表在当前事务中的用量，第一次访问时从页统计得到
+func (qs *quotas) usageOf(bucket *bolt.Bucket, tn string) *quotaUsage {
+	qs.usageMu.Lock()
+	defer qs.usageMu.Unlock()
+	tx := bucket.Tx()
+	if qs.usage == nil {
+		qs.usage = make(map[*bolt.Tx]map[string]*quotaUsage)
+	}
+	tables := qs.usage[tx]
+	if tables == nil {
+		tables = make(map[string]*quotaUsage)
+		qs.usage[tx] = tables
+	}
+	u := tables[tn]
+	if u == nil {
+		st := bucket.Stats()
+		u = &quotaUsage{keys: st.KeyN, bytes: int64(st.BranchInuse + st.LeafInuse + st.InlineBucketInuse)}
+		tables[tn] = u
+	}
+	return u
+}
+
+// 写事务结束后丢弃其用量
+func (qs *quotas) forget(tx *bolt.Tx) {
+	qs.usageMu.Lock()
+	delete(qs.usage, tx)
+	qs.usageMu.Unlock()
+}
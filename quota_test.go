@@ -0,0 +1,123 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	db := openTestDB(t, "test", "other")
+	db.SetQuota("test", Quota{MaxKeys: 3, MaxValueSize: 10})
+
+	for i := 0; i < 3; i++ {
+		if err := db.Set("test", i, "v"); err != nil {
+			t.Fatalf("db.Set(%d) failed, err=%v", i, err)
+		}
+	}
+	if err := db.Set("test", 3, "v"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("db.Set(4th key) err=%v, want ErrQuotaExceeded", err)
+	}
+	// 覆盖已有的键不增加键数
+	if err := db.Set("test", 0, "updated"); err != nil {
+		t.Errorf("db.Set(existing) err=%v, want nil", err)
+	}
+	if err := db.Set("test", 1, bytes.Repeat([]byte("x"), 11)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("db.Set(large value) err=%v, want ErrQuotaExceeded", err)
+	}
+	db.Delete("test", 2)
+	if err := db.Set("test", 3, "v"); err != nil {
+		t.Errorf("db.Set() after Delete err=%v, want nil", err)
+	}
+	if err := db.Set("other", "k", bytes.Repeat([]byte("x"), 100)); err != nil {
+		t.Errorf("db.Set(other) err=%v, want nil", err)
+	}
+
+	db.SetQuota("test", Quota{})
+	if err := db.Set("test", 9, "v"); err != nil {
+		t.Errorf("db.Set() after removing quota err=%v, want nil", err)
+	}
+}
+
+func TestQuotaBytes(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.SetQuota("test", Quota{MaxBytes: 4096})
+
+	var err error
+	n := 0
+	for ; n < 100; n++ {
+		if err = db.Set("test", n, bytes.Repeat([]byte("x"), 100)); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrQuotaExceeded) || n < 20 || n > 40 {
+		t.Errorf("writes before quota == %d, err=%v, want about 4096/100 and ErrQuotaExceeded", n, err)
+	}
+	// 变小的写入总是允许
+	if err := db.Set("test", 0, "x"); err != nil {
+		t.Errorf("db.Set(smaller) err=%v, want nil", err)
+	}
+	// 批量写入中有一条超出时全部不写
+	err = db.SetBatch("test", map[interface{}]interface{}{"a": "1", "b": bytes.Repeat([]byte("x"), 4096)})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("db.SetBatch() err=%v, want ErrQuotaExceeded", err)
+	}
+	if ok, _ := db.Has("test", "a"); ok {
+		t.Errorf("key a written by a failed batch")
+	}
+}
+
+// 同一事务中的多次写入累计计算
+func TestQuotaInTransaction(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.SetQuota("test", Quota{MaxKeys: 3})
+
+	err := db.SetBatch("test", map[interface{}]interface{}{1: 1, 2: 2, 3: 3, 4: 4})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("db.SetBatch(4 keys) err=%v, want ErrQuotaExceeded", err)
+	}
+	err = db.Update("test", func(tb Table) error {
+		for i := 0; i < 3; i++ {
+			if err := tb.Set(i, i); err != nil {
+				return err
+			}
+		}
+		tb.Delete(0)
+		return tb.Set(3, 3)
+	})
+	if err != nil {
+		t.Errorf("db.Update() err=%v, want nil", err)
+	}
+	if n, _ := db.Count("test"); n != 3 {
+		t.Errorf("db.Count() == %d, want 3", n)
+	}
+
+	txn, _ := db.Begin(true)
+	if err := txn.Set("test", 9, 9); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("txn.Set() err=%v, want ErrQuotaExceeded", err)
+	}
+	txn.Rollback()
+}
+
+func TestQuotaBufferedFlush(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.SetQuota("test", Quota{MaxKeys: 2})
+	db.SetWriteBuffer(time.Hour, 100)
+	db.Set("test", "a", "v")
+	db.Set("test", "b", "v")
+	db.Set("test", "c", "v")
+	if err := db.Flush(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("db.Flush() err=%v, want ErrQuotaExceeded", err)
+	}
+	if n, _ := db.Count("test"); n != 2 {
+		t.Errorf("db.Count() == %d, want 2", n)
+	}
+	// 写缓冲的事务结束后同样丢弃用量
+	qs := &db.(*dbConnection).quotas
+	qs.usageMu.Lock()
+	defer qs.usageMu.Unlock()
+	if len(qs.usage) != 0 {
+		t.Errorf("quota usage kept for %d finished transactions, want 0", len(qs.usage))
+	}
+}
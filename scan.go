@@ -0,0 +1,336 @@
+package bdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// afterKey为nil时从头开始。fn返回错误时停止，返回的lastKey是最后一个处理成功的键，
+// 调用方保存它作为断点，重启后传入即可续跑；一个都没有处理时返回afterKey
+func (b *dbConnection) ForEachResumable(tn string, afterKey []byte, fn func(k, v []byte) error) (lastKey []byte, err error) {
+	lastKey = append([]byte(nil), afterKey...)
+	ctx, cancel := b.opContext()
+	defer cancel()
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		c := bucket.Cursor()
+		k, v := seekAfter(c, afterKey)
+		for ; k != nil; k, v = c.Next() {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+			lastKey = append(lastKey[:0], k...)
+		}
+		return nil
+	})
+	return lastKey, err
+}
+
+// 借助游标Seek只遍历以prefix开头的键，prefix为空时遍历全表。fn返回错误时停止并返回该错误
+func (b *dbConnection) Scan(tn string, prefix []byte, fn func(k, v []byte) error) error {
+	return b.ScanCtx(context.Background(), tn, prefix, fn)
+}
+
+// ctx结束时停止并返回结束的原因
+func (b *dbConnection) ScanCtx(ctx context.Context, tn string, prefix []byte, fn func(k, v []byte) error) (err error) {
+	defer b.observeOp(ctx, "scan", tn, time.Now(), prefix, nil, &err)
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+	return b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 键按字节序比较，start、end为nil表示不限。fn返回错误时停止并返回该错误
+func (b *dbConnection) GetRange(tn string, start, end interface{}, fn func(k, v []byte) error) error {
+	s, e, err := rangeBounds(start, end)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext()
+	defer cancel()
+	return b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		return walkRange(ctx, bucket, s, e, fn)
+	})
+}
+
+// 从最后一个键开始倒序遍历，如取最新的N条。limit<=0表示不限，fn返回错误时停止并返回该错误
+func (b *dbConnection) ForEachReverse(tn string, limit int, fn func(k, v []byte) error) error {
+	ctx, cancel := b.opContext()
+	defer cancel()
+	return b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		c := bucket.Cursor()
+		n := 0
+		for k, v := c.Last(); k != nil && (limit <= 0 || n < limit); k, v = c.Prev() {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+}
+
+// afterKey为nil时取第一页，之后把返回的nextKey原样传回即取下一页；
+// 没有更多数据时nextKey为nil
+func (b *dbConnection) Page(tn string, afterKey []byte, limit int) (items []KV, nextKey []byte, err error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page limit:%d", limit)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		items = make([]KV, 0, limit)
+		c := bucket.Cursor()
+		k, v := seekAfter(c, afterKey)
+		for ; k != nil && len(items) < limit; k, v = c.Next() {
+			items = append(items, copyKV(k, v))
+		}
+		if k != nil {
+			nextKey = items[len(items)-1].Key
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, nextKey, nil
+}
+
+// 只拷贝键不拷贝值，limit<=0表示不限。子表不在其中，用ListCollections列出
+func (b *dbConnection) Keys(tn string, limit int) ([][]byte, error) {
+	return b.KeysWithPrefix(tn, nil, limit)
+}
+
+// 同Keys，只列出以prefix开头的键
+func (b *dbConnection) KeysWithPrefix(tn string, prefix []byte, limit int) (keys [][]byte, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		keys = [][]byte{}
+		c := bucket.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if limit > 0 && len(keys) >= limit {
+				break
+			}
+			if v != nil {
+				keys = append(keys, append([]byte{}, k...))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// 定位到严格大于after的第一个键，after为nil时定位到第一个键
+func seekAfter(c *bolt.Cursor, after []byte) ([]byte, []byte) {
+	if after == nil {
+		return c.First()
+	}
+	k, v := c.Seek(after)
+	if k != nil && bytes.Equal(k, after) {
+		k, v = c.Next()
+	}
+	return k, v
+}
+
+// 直接把存储的值按原样用逗号拼接成JSON数组写到w，省去解码再编码。
+// 默认信任值都是合法的JSON；validate为true时逐个用json.Valid校验，
+// 遇到非法值返回错误，此时w中已写出部分内容
+func (b *dbConnection) ScanJSON(tn string, w io.Writer, validate bool) error {
+	ctx, cancel := b.opContext()
+	defer cancel()
+	return b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		first := true
+		err = forEach(ctx, bucket, func(k, v []byte) error {
+			if v == nil {
+				// 子表
+				return nil
+			}
+			if validate && !json.Valid(v) {
+				return fmt.Errorf("value of %v.%q is not valid JSON", tn, k)
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err := w.Write(v)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "]")
+		return err
+	})
+}
+
+// 先定位到通配符的字面前缀，只检查带该前缀的键
+func (b *dbConnection) Match(tn string, pattern string, fn func(k, v []byte) bool) error {
+	prefix, err := globPrefix(pattern)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext()
+	defer cancel()
+	return b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			if globMatch(pattern, k) && !fn(k, v) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// 在一个只读事务中定位到pivot，prev为小于pivot的最多before条，next为从pivot(含)开始的最多after条，
+// 两者都按键升序排列，拼起来就是以pivot为中心的一段连续记录
+func (b *dbConnection) Around(tn string, pivot interface{}, before, after int) (prev, next []KV, err error) {
+	p, err := keyToBytes(pivot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(p); k != nil && len(next) < after; k, v = c.Next() {
+			next = append(next, copyKV(k, v))
+		}
+
+		c = bucket.Cursor()
+		k, v := c.Seek(p)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && len(prev) < before; k, v = c.Prev() {
+			prev = append(prev, copyKV(k, v))
+		}
+		for i, j := 0, len(prev)-1; i < j; i, j = i+1, j-1 {
+			prev[i], prev[j] = prev[j], prev[i]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prev, next, nil
+}
+
+// 复制事务内的键值，子表的值为nil
+func copyKV(k, v []byte) KV {
+	kv := KV{Key: append([]byte(nil), k...)}
+	if v != nil {
+		kv.Value = append([]byte{}, v...)
+	}
+	return kv
+}
+
+// 范围的边界，nil表示不限
+func rangeBounds(start, end interface{}) (s, e []byte, err error) {
+	if start != nil {
+		if s, err = keyToBytes(start); err != nil {
+			return nil, nil, fmt.Errorf("invalid start key:%w", err)
+		}
+	}
+	if end != nil {
+		if e, err = keyToBytes(end); err != nil {
+			return nil, nil, fmt.Errorf("invalid end key:%w", err)
+		}
+	}
+	return s, e, nil
+}
+
+// 从start开始遍历[start, end)内的键，fn返回错误时停止
+func walkRange(ctx context.Context, bucket *bolt.Bucket, start, end []byte, fn func(k, v []byte) error) error {
+	c := bucket.Cursor()
+	k, v := c.First()
+	if start != nil {
+		k, v = c.Seek(start)
+	}
+	for ; k != nil && (end == nil || bytes.Compare(k, end) < 0); k, v = c.Next() {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestForEachResumable(t *testing.T) {
+	db := openTestDB(t, "test")
+	for i := 0; i < 5; i++ {
+		db.Set("test", fmt.Sprintf("k%d", i), i)
+	}
+
+	// 处理到k2时模拟崩溃
+	crash := errors.New("crash")
+	var got []string
+	last, err := db.ForEachResumable("test", nil, func(k, v []byte) error {
+		if string(k) == "k2" {
+			return crash
+		}
+		got = append(got, string(k))
+		return nil
+	})
+	if err != crash {
+		t.Fatalf("db.ForEachResumable() err=%v, want %v", err, crash)
+	}
+	if string(last) != "k1" {
+		t.Fatalf("db.ForEachResumable() lastKey=%q, want %q", last, "k1")
+	}
+
+	// 从断点续跑
+	last, err = db.ForEachResumable("test", last, func(k, v []byte) error {
+		got = append(got, string(k))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("db.ForEachResumable() failed, err=%v", err)
+	}
+	if fmt.Sprint(got) != "[k0 k1 k2 k3 k4]" || string(last) != "k4" {
+		t.Errorf("resumed scan got=%v lastKey=%q", got, last)
+	}
+
+	// 没有新数据时断点不变
+	last, err = db.ForEachResumable("test", last, func(k, v []byte) error {
+		t.Errorf("unexpected key %q", k)
+		return nil
+	})
+	if err != nil || string(last) != "k4" {
+		t.Errorf("db.ForEachResumable(k4) lastKey=%q err=%v, want %q", last, err, "k4")
+	}
+}
+
+func TestScanJSON(t *testing.T) {
+	db := openTestDB(t, "test", "empty")
+	db.Set("test", "a", `{"id":1}`)
+	db.Set("test", "b", `[2,3]`)
+	db.Set("test", "c", `"s"`)
+
+	var buf bytes.Buffer
+	if err := db.ScanJSON("test", &buf, true); err != nil {
+		t.Fatalf("db.ScanJSON() failed, err=%v", err)
+	}
+	if want := `[{"id":1},[2,3],"s"]`; buf.String() != want {
+		t.Errorf("db.ScanJSON() == %s, want %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := db.ScanJSON("empty", &buf, true); err != nil || buf.String() != "[]" {
+		t.Errorf("db.ScanJSON(empty) == %s, err=%v, want []", buf.String(), err)
+	}
+
+	db.Set("test", "d", `{broken`)
+	if err := db.ScanJSON("test", io.Discard, false); err != nil {
+		t.Errorf("db.ScanJSON(validate=false) failed, err=%v", err)
+	}
+	if err := db.ScanJSON("test", io.Discard, true); err == nil {
+		t.Errorf("db.ScanJSON(validate=true) should reject invalid JSON")
+	}
+}
+
+func TestAround(t *testing.T) {
+	db := openTestDB(t, "msgs")
+	for i := 1; i <= 9; i++ {
+		db.Set("msgs", fmt.Sprintf("m%d", i), i)
+	}
+	keys := func(kvs []KV) string {
+		var ks []string
+		for _, kv := range kvs {
+			ks = append(ks, string(kv.Key))
+		}
+		return fmt.Sprint(ks)
+	}
+
+	var tests = []struct {
+		pivot         string
+		before, after int
+		prev, next    string
+	}{
+		{"m5", 2, 3, "[m3 m4]", "[m5 m6 m7]"},
+		{"m1", 2, 1, "[]", "[m1]"},
+		{"m9", 10, 10, "[m1 m2 m3 m4 m5 m6 m7 m8]", "[m9]"},
+		{"m45", 1, 1, "[m4]", "[m5]"},
+		{"z", 2, 2, "[m8 m9]", "[]"},
+		{"a", 2, 2, "[]", "[m1 m2]"},
+	}
+	for _, test := range tests {
+		prev, next, err := db.Around("msgs", test.pivot, test.before, test.after)
+		if err != nil {
+			t.Fatalf("db.Around(%q) failed, err=%v", test.pivot, err)
+		}
+		if keys(prev) != test.prev || keys(next) != test.next {
+			t.Errorf("db.Around(%q, %d, %d) == %v %v, want %v %v", test.pivot, test.before, test.after,
+				keys(prev), keys(next), test.prev, test.next)
+		}
+	}
+
+	if _, next, _ := db.Around("msgs", "m5", 0, 1); string(next[0].Value) != "5" {
+		t.Errorf("db.Around() value == %q, want %q", next[0].Value, "5")
+	}
+}
+
+func TestScan(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"a", "user:1", "user:2", "users", "z"} {
+		db.Set("test", k, k)
+	}
+
+	var got []string
+	err := db.Scan("test", []byte("user:"), func(k, v []byte) error {
+		got = append(got, string(k))
+		return nil
+	})
+	if err != nil || fmt.Sprint(got) != "[user:1 user:2]" {
+		t.Errorf("db.Scan(user:) == %v, %v, want [user:1 user:2]", got, err)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = db.Scan("test", nil, func(k, v []byte) error {
+		n++
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 3 {
+		t.Errorf("db.Scan(nil) stopped after %d, err=%v, want 3, stop", n, err)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"2024-01-01", "2024-01-15", "2024-02-01", "2024-02-10", "2024-03-01"} {
+		db.Set("test", k, k)
+	}
+
+	var tests = []struct {
+		start, end interface{}
+		want       string
+	}{
+		{"2024-01-10", "2024-02-10", "[2024-01-15 2024-02-01]"},
+		{"2024-02-01", nil, "[2024-02-01 2024-02-10 2024-03-01]"},
+		{nil, "2024-01-15", "[2024-01-01]"},
+		{"2024-04", nil, "[]"},
+	}
+	for _, test := range tests {
+		got := []string{}
+		err := db.GetRange("test", test.start, test.end, func(k, v []byte) error {
+			got = append(got, string(k))
+			return nil
+		})
+		if err != nil || fmt.Sprint(got) != test.want {
+			t.Errorf("db.GetRange(%v, %v) == %v, %v, want %v", test.start, test.end, got, err, test.want)
+		}
+	}
+	if err := db.GetRange("test", "", nil, func(k, v []byte) error { return nil }); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("db.GetRange(\"\") err=%v, want ErrEmptyKey", err)
+	}
+}
+
+func TestForEachReverse(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"a", "b", "c", "d"} {
+		db.Set("test", k, k)
+	}
+
+	var tests = []struct {
+		limit int
+		want  string
+	}{
+		{2, "[d c]"},
+		{0, "[d c b a]"},
+		{10, "[d c b a]"},
+	}
+	for _, test := range tests {
+		var got []string
+		err := db.ForEachReverse("test", test.limit, func(k, v []byte) error {
+			got = append(got, string(k))
+			return nil
+		})
+		if err != nil || fmt.Sprint(got) != test.want {
+			t.Errorf("db.ForEachReverse(%d) == %v, %v, want %v", test.limit, got, err, test.want)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		db.Set("test", k, k)
+	}
+
+	var pages []string
+	var after []byte
+	for {
+		items, next, err := db.Page("test", after, 2)
+		if err != nil {
+			t.Fatalf("db.Page(%q) failed, err=%v", after, err)
+		}
+		var keys []string
+		for _, kv := range items {
+			keys = append(keys, string(kv.Key))
+		}
+		pages = append(pages, fmt.Sprint(keys))
+		if next == nil {
+			break
+		}
+		after = next
+	}
+	if fmt.Sprint(pages) != "[[a b] [c d] [e]]" {
+		t.Errorf("pages == %v, want [[a b] [c d] [e]]", pages)
+	}
+
+	// 最后一页刚好满时nextKey也为nil
+	if items, next, err := db.Page("test", []byte("c"), 2); err != nil || len(items) != 2 || next != nil {
+		t.Errorf("db.Page(c, 2) == %d items, next=%q, err=%v, want 2 items, nil", len(items), next, err)
+	}
+	if _, _, err := db.Page("test", nil, 0); err == nil {
+		t.Errorf("db.Page(limit 0) succeeded")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"a", "user:1", "user:2", "user:3", "z"} {
+		db.Set("test", k, k)
+	}
+
+	var tests = []struct {
+		prefix string
+		limit  int
+		want   string
+	}{
+		{"", 0, "[a user:1 user:2 user:3 z]"},
+		{"", 2, "[a user:1]"},
+		{"user:", 0, "[user:1 user:2 user:3]"},
+		{"user:", 2, "[user:1 user:2]"},
+		{"x", 0, "[]"},
+	}
+	for _, test := range tests {
+		keys, err := db.KeysWithPrefix("test", []byte(test.prefix), test.limit)
+		var got []string
+		for _, k := range keys {
+			got = append(got, string(k))
+		}
+		if err != nil || fmt.Sprint(got) != test.want {
+			t.Errorf("db.KeysWithPrefix(%q, %d) == %v, %v, want %v", test.prefix, test.limit, got, err, test.want)
+		}
+	}
+	if keys, err := db.Keys("test", 1); err != nil || len(keys) != 1 || string(keys[0]) != "a" {
+		t.Errorf("db.Keys(1) == %q, %v, want [a]", keys, err)
+	}
+}
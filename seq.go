@@ -0,0 +1,43 @@
+package bdb
+
+import (
+	"bytes"
+	"iter"
+
+	"github.com/boltdb/bolt"
+)
+
+// 表中所有键值的迭代器，用法为for k, v := range db.All(tn)。
+// 整个循环在一个只读事务中进行，k、v只在当次循环内有效，需要保留时自行拷贝；
+// 循环体内不要写同一个库。表不存在或出错时不产生元素，需要错误时用Scan
+func (b *dbConnection) All(tn string) iter.Seq2[[]byte, []byte] {
+	return b.Prefix(tn, nil)
+}
+
+// 以p开头的键值的迭代器，其余同All
+func (b *dbConnection) Prefix(tn string, p []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func(k, v []byte) bool) {
+		ctx, cancel := b.opContext()
+		defer cancel()
+		// yield执行调用方的代码，与迭代器一样只计数，不持有lifecycle读锁
+		if b.acquireHandle() != nil {
+			return
+		}
+		defer b.releaseHandle()
+		b.flushBeforeRead()
+		b.bdb.View(func(tx *bolt.Tx) error {
+			bucket, err := getBucket(tx, tn)
+			if err != nil {
+				return err
+			}
+
+			c := bucket.Cursor()
+			for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+				if ctxErr(ctx) != nil || !yield(k, v) {
+					return nil
+				}
+			}
+			return nil
+		})
+	}
+}
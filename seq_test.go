@@ -0,0 +1,48 @@
+package bdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAllAndPrefix(t *testing.T) {
+	db := openTestDB(t, "test")
+	for _, k := range []string{"a", "user:1", "user:2", "z"} {
+		db.Set("test", k, "v"+k)
+	}
+
+	var got []string
+	for k, v := range db.All("test") {
+		got = append(got, string(k)+"="+string(v))
+	}
+	if fmt.Sprint(got) != "[a=va user:1=vuser:1 user:2=vuser:2 z=vz]" {
+		t.Errorf("db.All() == %v", got)
+	}
+
+	got = nil
+	for k := range db.Prefix("test", []byte("user:")) {
+		got = append(got, string(k))
+	}
+	if fmt.Sprint(got) != "[user:1 user:2]" {
+		t.Errorf("db.Prefix(user:) == %v", got)
+	}
+
+	// 提前break后事务已经结束，可以继续写
+	n := 0
+	for range db.All("test") {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("break after %d, want 2", n)
+	}
+	if err := db.Set("test", "b", "vb"); err != nil {
+		t.Errorf("db.Set() after break failed, err=%v", err)
+	}
+
+	for k := range db.All("missing") {
+		t.Errorf("db.All(missing) yielded %q", k)
+	}
+}
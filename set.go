@@ -0,0 +1,253 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// 集合、有序集合、列表、哈希所在的键已经是普通键值或其它类型时返回
+var ErrWrongType = errors.New("key holds a different type")
+
+// 子表的类型，记在子表的序号中。序号为0的是旧版本创建的子表，不检查类型
+type keyKind uint64
+
+const (
+	kindSet keyKind = iota + 1
+	kindZSet
+	kindList
+	kindHash
+)
+
+// 表tn中键k对应的子表。写入计入表tn的配额，并以tn/k为表名记录变更；不经过钩子和审计
+type keyTable struct {
+	*bolt.Bucket
+	b      *dbConnection
+	parent *bolt.Bucket
+	tn     string
+	k      []byte
+}
+
+func (t *keyTable) Put(m, v []byte) error {
+	if err := t.b.checkQuota(t.parent, t.tn, m, t.Get(m), v); err != nil {
+		return err
+	}
+	if err := t.Bucket.Put(m, v); err != nil {
+		return err
+	}
+	return t.b.capture(t.Tx(), OpPut, t.tn+TableSeparator+string(t.k), m, v)
+}
+
+func (t *keyTable) Delete(m []byte) error {
+	old := t.Get(m)
+	if old == nil {
+		return nil
+	}
+	if err := t.Bucket.Delete(m); err != nil {
+		return err
+	}
+	t.b.releaseQuota(t.parent, t.tn, m, old)
+	return t.b.capture(t.Tx(), OpDelete, t.tn+TableSeparator+string(t.k), m, nil)
+}
+
+// 子表空了时删除，与Redis删除空的集合一致
+func (t *keyTable) dropIfEmpty() error {
+	if first, _ := t.Cursor().First(); first != nil {
+		return nil
+	}
+	if err := t.parent.DeleteBucket(t.k); err != nil {
+		return err
+	}
+	t.b.releaseQuota(t.parent, t.tn, t.k, []byte{})
+	return nil
+}
+
+// 表tn中键k对应的子表，集合等类型都存放在键名同名的子表中。create为false且不存在时返回nil。
+// 子表已经是其它类型时返回ErrWrongType
+func (b *dbConnection) keyBucket(tx *bolt.Tx, tn string, k []byte, kind keyKind, create bool) (*keyTable, error) {
+	var parent *bolt.Bucket
+	var err error
+	if create {
+		parent, err = b.writeBucket(tx, tn)
+	} else {
+		parent, err = getBucket(tx, tn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sub := parent.Bucket(k)
+	if sub == nil {
+		if parent.Get(k) != nil {
+			return nil, fmt.Errorf("%v.%q: %w", tn, k, ErrWrongType)
+		}
+		if !create {
+			return nil, nil
+		}
+		if err := b.checkQuota(parent, tn, k, nil, []byte{}); err != nil {
+			return nil, err
+		}
+		if sub, err = parent.CreateBucket(k); err != nil {
+			return nil, err
+		}
+	}
+	switch seq := sub.Sequence(); {
+	case seq == 0 && create:
+		if err := sub.SetSequence(uint64(kind)); err != nil {
+			return nil, err
+		}
+	case seq != 0 && seq != uint64(kind):
+		return nil, fmt.Errorf("%v.%q: %w", tn, k, ErrWrongType)
+	}
+	return &keyTable{Bucket: sub, b: b, parent: parent, tn: tn, k: k}, nil
+}
+
+// 依次编码成员，空成员返回ErrEmptyKey
+func encodeMembers(members []interface{}) ([][]byte, error) {
+	encoded := make([][]byte, len(members))
+	for i, member := range members {
+		m, err := keyToBytes(member)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member:%w", err)
+		}
+		encoded[i] = m
+	}
+	return encoded, nil
+}
+
+// 成员作为子表中值为空的键，返回新加入的个数。没有成员时不创建键
+func (b *dbConnection) SAdd(tn string, key interface{}, members ...interface{}) (added int, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
+	ms, err := encodeMembers(members)
+	if err != nil {
+		return 0, err
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		added = 0
+		set, err := b.keyBucket(tx, tn, k, kindSet, len(ms) > 0)
+		if err != nil || set == nil {
+			return err
+		}
+		for _, m := range ms {
+			if set.Get(m) != nil {
+				continue
+			}
+			if err := set.Put(m, []byte{}); err != nil {
+				return fmt.Errorf("add %v.%q member %q failed: %w", tn, k, m, err)
+			}
+			added++
+		}
+		return b.mirrorWrite(tx, func(mr BoltDB) error {
+			_, err := mr.SAdd(tn, k, members...)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// 返回删除的个数，集合空了时连同键一起删除
+func (b *dbConnection) SRem(tn string, key interface{}, members ...interface{}) (removed int, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
+	ms, err := encodeMembers(members)
+	if err != nil {
+		return 0, err
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		removed = 0
+		set, err := b.keyBucket(tx, tn, k, kindSet, false)
+		if err != nil || set == nil {
+			return err
+		}
+		for _, m := range ms {
+			if set.Get(m) == nil {
+				continue
+			}
+			if err := set.Delete(m); err != nil {
+				return fmt.Errorf("remove %v.%q member %q failed: %w", tn, k, m, err)
+			}
+			removed++
+		}
+		if err := set.dropIfEmpty(); err != nil {
+			return err
+		}
+		return b.mirrorWrite(tx, func(mr BoltDB) error {
+			_, err := mr.SRem(tn, k, members...)
+			return err
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// 键不存在时返回false
+func (b *dbConnection) SIsMember(tn string, key, member interface{}) (ok bool, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return false, fmt.Errorf("invalid key:%w", err)
+	}
+	m, err := keyToBytes(member)
+	if err != nil {
+		return false, fmt.Errorf("invalid member:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		set, err := b.keyBucket(tx, tn, k, kindSet, false)
+		if err != nil || set == nil {
+			return err
+		}
+		ok = set.Get(m) != nil
+		return nil
+	})
+	return ok, err
+}
+
+// 按字节序返回所有成员，键不存在时返回空
+func (b *dbConnection) SMembers(tn string, key interface{}) (members [][]byte, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		set, err := b.keyBucket(tx, tn, k, kindSet, false)
+		if err != nil || set == nil {
+			return err
+		}
+		return set.ForEach(func(m, _ []byte) error {
+			members = append(members, append([]byte{}, m...))
+			return nil
+		})
+	})
+	return members, err
+}
+
+// 集合的成员数，键不存在时为0
+func (b *dbConnection) SCard(tn string, key interface{}) (n int, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		set, err := b.keyBucket(tx, tn, k, kindSet, false)
+		if err != nil || set == nil {
+			return err
+		}
+		n = set.Stats().KeyN
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,104 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	db := openTestDB(t, "tags")
+	if n, err := db.SAdd("tags", "item1", "red", "blue", "red"); err != nil || n != 2 {
+		t.Errorf("db.SAdd() == %d, %v, want 2, nil", n, err)
+	}
+	if n, _ := db.SAdd("tags", "item1", "blue", "green"); n != 1 {
+		t.Errorf("db.SAdd(existing) == %d, want 1", n)
+	}
+	if ok, err := db.SIsMember("tags", "item1", "blue"); err != nil || !ok {
+		t.Errorf("db.SIsMember(blue) == %v, %v, want true, nil", ok, err)
+	}
+	if ok, _ := db.SIsMember("tags", "item2", "blue"); ok {
+		t.Errorf("db.SIsMember(missing key) == true, want false")
+	}
+	members, err := db.SMembers("tags", "item1")
+	if err != nil || len(members) != 3 || string(members[0]) != "blue" || string(members[2]) != "red" {
+		t.Errorf("db.SMembers() == %q, %v, want [blue green red]", members, err)
+	}
+	if n, _ := db.SCard("tags", "item1"); n != 3 {
+		t.Errorf("db.SCard() == %d, want 3", n)
+	}
+
+	if n, err := db.SRem("tags", "item1", "red", "missing"); err != nil || n != 1 {
+		t.Errorf("db.SRem() == %d, %v, want 1, nil", n, err)
+	}
+	db.SRem("tags", "item1", "blue", "green")
+	if names, _ := db.ListCollections("tags"); len(names) != 0 {
+		t.Errorf("empty set not dropped, collections %v", names)
+	}
+
+	db.Set("tags", "plain", "v")
+	if _, err := db.SAdd("tags", "plain", "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("db.SAdd(plain key) err=%v, want ErrWrongType", err)
+	}
+	if _, err := db.SMembers("tags", "plain"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("db.SMembers(plain key) err=%v, want ErrWrongType", err)
+	}
+	if _, err := db.SAdd("missing", "k", "x"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.SAdd(missing table) err=%v, want ErrTableNotFound", err)
+	}
+	if _, err := db.SAdd("tags", "k", ""); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("db.SAdd(empty member) err=%v, want ErrEmptyKey", err)
+	}
+}
+
+func TestSetTypeQuotaAndChanges(t *testing.T) {
+	db := openTestDB(t, "tags")
+	if n, err := db.SAdd("tags", "empty"); err != nil || n != 0 {
+		t.Errorf("db.SAdd(no members) == %d, %v, want 0, nil", n, err)
+	}
+	if n, err := db.LPush("tags", "empty"); err != nil || n != 0 {
+		t.Errorf("db.LPush(no values) == %d, %v, want 0, nil", n, err)
+	}
+	if names, _ := db.ListCollections("tags"); len(names) != 0 {
+		t.Errorf("no members left collections %v", names)
+	}
+
+	db.ZAdd("tags", "ranked", "m", 1)
+	if _, err := db.SMembers("tags", "ranked"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("db.SMembers(zset key) err=%v, want ErrWrongType", err)
+	}
+	if _, err := db.SAdd("tags", "ranked", "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("db.SAdd(zset key) err=%v, want ErrWrongType", err)
+	}
+	if _, err := db.HGetAll("tags", "ranked"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("db.HGetAll(zset key) err=%v, want ErrWrongType", err)
+	}
+	db.ZRem("tags", "ranked", "m")
+
+	// 子表本身和每个成员各算一个键
+	db.SetQuota("tags", Quota{MaxKeys: 3})
+	if n, err := db.SAdd("tags", "item", "a", "b"); err != nil || n != 2 {
+		t.Fatalf("db.SAdd() == %d, %v, want 2, nil", n, err)
+	}
+	if _, err := db.SAdd("tags", "item", "c"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("db.SAdd(over quota) err=%v, want ErrQuotaExceeded", err)
+	}
+	db.SRem("tags", "item", "a")
+	if _, err := db.SAdd("tags", "item", "c"); err != nil {
+		t.Errorf("db.SAdd() after SRem err=%v, want nil", err)
+	}
+	db.SetQuota("tags", Quota{})
+
+	db.SetCDC(true)
+	db.SAdd("tags", "item", "d")
+	db.SRem("tags", "item", "b")
+	records, err := db.ReadChanges(0, 0)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("db.ReadChanges() == %+v, %v, want 2 records", records, err)
+	}
+	if r := records[0]; r.Op != OpPut || r.Table != "tags/item" || string(r.Key) != "d" {
+		t.Errorf("records[0] == %+v, want put tags/item d", r)
+	}
+	if r := records[1]; r.Op != OpDelete || r.Table != "tags/item" || string(r.Key) != "b" {
+		t.Errorf("records[1] == %+v, want delete tags/item b", r)
+	}
+}
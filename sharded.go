@@ -0,0 +1,1228 @@
+package bdb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 需要跨分片原子地完成的操作返回，如事务、重命名表。这些操作须在ShardAt返回的单个分片上进行
+var ErrUnsupported = fmt.Errorf("not supported across shards: %w", errors.ErrUnsupported)
+
+// 按键的哈希分布在多个文件上的库，各文件有各自的写锁，写入可以并行。
+// 单个键的读写在键所在的分片上进行，遍历、计数等在所有分片上进行后归并，设置对每个分片生效。
+// 跨分片的写不是原子的，CreateTable、SetBatch等中途失败时部分分片已经修改；
+// 事务、重命名表、序列号键等依赖单个文件的功能返回ErrUnsupported
+type ShardedDB struct {
+	dir    string
+	shards []BoltDB
+}
+
+var _ BoltDB = (*ShardedDB)(nil)
+
+// 在dir下打开n个分片文件，不存在时创建。dir中已有的分片数与n不同时返回错误，
+// 否则键会被路由到错误的分片，改变分片数须用Reshard
+func OpenSharded(dir string, n int, mode os.FileMode, opts *Options) (*ShardedDB, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid shard count %d", n)
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "shard-*.db"))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 && len(existing) != n {
+		return nil, fmt.Errorf("%v has %d shards, want %d", dir, len(existing), n)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &ShardedDB{dir: dir, shards: make([]BoltDB, 0, n)}
+	for i := 0; i < n; i++ {
+		db, err := OpenWithOptions(shardPath(dir, i), mode, opts)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("open shard %d failed: %w", i, err)
+		}
+		s.shards = append(s.shards, db)
+	}
+	return s, nil
+}
+
+func shardPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard-%03d.db", i))
+}
+
+// 分片数
+func (s *ShardedDB) Shards() int {
+	return len(s.shards)
+}
+
+// 第i个分片的连接，不要在上面写入不属于该分片的键
+func (s *ShardedDB) ShardAt(i int) BoltDB {
+	return s.shards[i]
+}
+
+// 键所在的分片
+func (s *ShardedDB) shardOf(k []byte) int {
+	h := fnv.New32a()
+	h.Write(k)
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// 键所在分片的连接
+func (s *ShardedDB) route(key interface{}) (BoltDB, error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+	return s.shards[s.shardOf(k)], nil
+}
+
+// 在每个分片上执行fn，返回所有分片的错误
+func (s *ShardedDB) each(fn func(db BoltDB) error) error {
+	return s.eachIndex(func(_ int, db BoltDB) error { return fn(db) })
+}
+
+// 同each，fn同时得到分片的下标
+func (s *ShardedDB) eachIndex(fn func(i int, db BoltDB) error) error {
+	var errs []error
+	for i, db := range s.shards {
+		if err := fn(i, db); err != nil {
+			errs = append(errs, fmt.Errorf("shard %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 关闭所有分片
+func (s *ShardedDB) Close() {
+	for _, db := range s.shards {
+		db.Close()
+	}
+}
+
+// 在所有分片上创建表
+func (s *ShardedDB) CreateTable(tn string) error {
+	return s.each(func(db BoltDB) error { return db.CreateTable(tn) })
+}
+
+// 在所有分片上删除表
+func (s *ShardedDB) DeleteTable(tn string) error {
+	return s.each(func(db BoltDB) error { return db.DeleteTable(tn) })
+}
+
+func (s *ShardedDB) Set(tn string, key, value interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.Set(tn, key, value)
+}
+
+func (s *ShardedDB) Get(tn string, key interface{}) []byte {
+	db, err := s.route(key)
+	if err != nil {
+		return nil
+	}
+	return db.Get(tn, key)
+}
+
+func (s *ShardedDB) GetValue(tn string, key interface{}) ([]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetValue(tn, key)
+}
+
+func (s *ShardedDB) Has(tn string, key interface{}) (bool, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return false, err
+	}
+	return db.Has(tn, key)
+}
+
+func (s *ShardedDB) Delete(tn string, key interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.Delete(tn, key)
+}
+
+// 所有分片中的键数之和
+func (s *ShardedDB) Count(tn string) (int, error) {
+	total := 0
+	err := s.each(func(db BoltDB) error {
+		n, err := db.Count(tn)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+// 把所有表复制到dstDir下的n个新分片中并返回打开的新库，原库不变。dstDir中已有分片文件时返回错误。
+// 复制期间须停止写入原库，完成后由调用方切换到新库并删除原目录。只复制顶层表中的键值，子表不复制
+func (s *ShardedDB) Reshard(dstDir string, n int, mode os.FileMode, opts *Options) (*ShardedDB, error) {
+	if abs, err := filepath.Abs(dstDir); err == nil {
+		if cur, err := filepath.Abs(s.dir); err == nil && abs == cur {
+			return nil, fmt.Errorf("reshard into the open directory %v", dstDir)
+		}
+	}
+	existing, err := filepath.Glob(filepath.Join(dstDir, "shard-*.db"))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("reshard into %v: directory already has %d shards", dstDir, len(existing))
+	}
+	dst, err := OpenSharded(dstDir, n, mode, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.copyTo(dst); err != nil {
+		dst.Close()
+		return nil, err
+	}
+	return dst, nil
+}
+
+func (s *ShardedDB) copyTo(dst *ShardedDB) error {
+	seen := map[string]bool{}
+	for i, src := range s.shards {
+		tables, err := src.ListTables()
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+		for _, tn := range tables {
+			if !seen[tn] {
+				seen[tn] = true
+				if err := dst.CreateTable(tn); err != nil {
+					return err
+				}
+			}
+			if err := dst.copyTable(src, tn); err != nil {
+				return fmt.Errorf("copy shard %d table %v failed: %w", i, tn, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 把src中表tn的键值按新的分片分组，每组攒够batchSize条写入一次
+func (s *ShardedDB) copyTable(src BoltDB, tn string) error {
+	pending := make([]map[interface{}]interface{}, len(s.shards))
+	flush := func(i int) error {
+		if len(pending[i]) == 0 {
+			return nil
+		}
+		err := s.shards[i].SetBatch(tn, pending[i])
+		pending[i] = nil
+		return err
+	}
+
+	err := src.Scan(tn, nil, func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		i := s.shardOf(k)
+		if pending[i] == nil {
+			pending[i] = make(map[interface{}]interface{}, batchSize)
+		}
+		pending[i][string(k)] = append([]byte{}, v...)
+		if len(pending[i]) >= batchSize {
+			return flush(i)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		if err := flush(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 分片由OpenSharded按目录打开
+func (s *ShardedDB) Open(dbname string, mode os.FileMode) error {
+	return ErrUnsupported
+}
+
+// 分片所在的目录
+func (s *ShardedDB) GetDBName() string {
+	return s.dir
+}
+
+// other是否就是s本身，Manager返回的连接先取出底层连接再比较
+func (s *ShardedDB) isSelf(other BoltDB) bool {
+	return unwrapManaged(other) == BoltDB(s)
+}
+
+// 按键所在的分片把kvs分组
+func (s *ShardedDB) group(kvs []KV) []map[interface{}]interface{} {
+	groups := make([]map[interface{}]interface{}, len(s.shards))
+	for _, kv := range kvs {
+		i := s.shardOf(kv.Key)
+		if groups[i] == nil {
+			groups[i] = make(map[interface{}]interface{})
+		}
+		groups[i][string(kv.Key)] = kv.Value
+	}
+	return groups
+}
+
+// 按键所在的分片把keys分组
+func (s *ShardedDB) groupKeys(keys []interface{}) ([][]interface{}, error) {
+	groups := make([][]interface{}, len(s.shards))
+	for _, key := range keys {
+		k, err := keyToBytes(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key:%w", err)
+		}
+		i := s.shardOf(k)
+		groups[i] = append(groups[i], key)
+	}
+	return groups, nil
+}
+
+// 在每个分片上执行fn，累加返回的条数
+func (s *ShardedDB) sum(fn func(db BoltDB) (int, error)) (int, error) {
+	total := 0
+	err := s.each(func(db BoltDB) error {
+		n, err := fn(db)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+// 页大小取第一个分片的，页数为所有分片之和
+func (s *ShardedDB) PageInfo() (pageSize, usedPages, freePages int, err error) {
+	err = s.each(func(db BoltDB) error {
+		size, used, free, err := db.PageInfo()
+		if pageSize == 0 {
+			pageSize = size
+		}
+		usedPages += used
+		freePages += free
+		return err
+	})
+	return pageSize, usedPages, freePages, err
+}
+
+// 汇总所有分片：文件大小、键数相加，同名表合并，序列号取各分片中最大的
+func (s *ShardedDB) Describe() (DBInfo, error) {
+	info := DBInfo{Path: s.dir, Tables: []TableInfo{}, EncodingVersion: EncodingVersion}
+	tables := map[string]int{}
+	err := s.each(func(db BoltDB) error {
+		di, err := db.Describe()
+		if err != nil {
+			return err
+		}
+		info.FileSize += di.FileSize
+		info.Mode = di.Mode
+		info.ReadOnly = info.ReadOnly || di.ReadOnly
+		info.TotalKeys += di.TotalKeys
+		for _, ti := range di.Tables {
+			i, ok := tables[ti.Name]
+			if !ok {
+				tables[ti.Name] = len(info.Tables)
+				info.Tables = append(info.Tables, ti)
+				continue
+			}
+			info.Tables[i].Keys += ti.Keys
+			info.Tables[i].Sequence = max(info.Tables[i].Sequence, ti.Sequence)
+		}
+		return nil
+	})
+	sort.Slice(info.Tables, func(i, j int) bool { return info.Tables[i].Name < info.Tables[j].Name })
+	return info, err
+}
+
+// 所有分片的统计相加
+func (s *ShardedDB) Stats() (bolt.Stats, error) {
+	var st bolt.Stats
+	err := s.each(func(db BoltDB) error {
+		o, err := db.Stats()
+		if err != nil {
+			return err
+		}
+		st.FreePageN += o.FreePageN
+		st.PendingPageN += o.PendingPageN
+		st.FreeAlloc += o.FreeAlloc
+		st.FreelistInuse += o.FreelistInuse
+		st.TxN += o.TxN
+		st.OpenTxN += o.OpenTxN
+		t, ot := &st.TxStats, o.TxStats
+		t.PageCount += ot.PageCount
+		t.PageAlloc += ot.PageAlloc
+		t.CursorCount += ot.CursorCount
+		t.NodeCount += ot.NodeCount
+		t.NodeDeref += ot.NodeDeref
+		t.Rebalance += ot.Rebalance
+		t.RebalanceTime += ot.RebalanceTime
+		t.Split += ot.Split
+		t.Spill += ot.Spill
+		t.SpillTime += ot.SpillTime
+		t.Write += ot.Write
+		t.WriteTime += ot.WriteTime
+		return nil
+	})
+	return st, err
+}
+
+// 所有分片中表tn的页统计相加
+func (s *ShardedDB) TableStats(tn string) (bolt.BucketStats, error) {
+	var st bolt.BucketStats
+	err := s.each(func(db BoltDB) error {
+		o, err := db.TableStats(tn)
+		st.Add(o)
+		return err
+	})
+	return st, err
+}
+
+// 所有分片都健康时返回nil
+func (s *ShardedDB) Ping() error {
+	return s.each(func(db BoltDB) error { return db.Ping() })
+}
+
+func (s *ShardedDB) SizeOf(tn string) (size int64, keys int, err error) {
+	err = s.each(func(db BoltDB) error {
+		n, k, err := db.SizeOf(tn)
+		size += n
+		keys += k
+		return err
+	})
+	return size, keys, err
+}
+
+func (s *ShardedDB) SetObserver(o Observer) {
+	for _, db := range s.shards {
+		db.SetObserver(o)
+	}
+}
+
+func (s *ShardedDB) SetTracer(t Tracer) {
+	for _, db := range s.shards {
+		db.SetTracer(t)
+	}
+}
+
+func (s *ShardedDB) SetLogger(l *slog.Logger) {
+	for _, db := range s.shards {
+		db.SetLogger(l)
+	}
+}
+
+// 配额在每个分片上分别计算
+func (s *ShardedDB) SetQuota(tn string, q Quota) {
+	for _, db := range s.shards {
+		db.SetQuota(tn, q)
+	}
+}
+
+// 表是否为空要在所有分片上一起判断，无法与写入原子地完成
+func (s *ShardedDB) InitTable(tn string, seed map[interface{}]interface{}) (bool, error) {
+	return false, ErrUnsupported
+}
+
+// 按名字排序返回所有分片中的顶层表
+func (s *ShardedDB) ListTables() ([]string, error) {
+	seen := map[string]bool{}
+	names := []string{}
+	err := s.each(func(db BoltDB) error {
+		tables, err := db.ListTables()
+		for _, tn := range tables {
+			if !seen[tn] {
+				seen[tn] = true
+				names = append(names, tn)
+			}
+		}
+		return err
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// 所有分片上都有该表时返回true
+func (s *ShardedDB) HasTable(tn string) bool {
+	for _, db := range s.shards {
+		if !db.HasTable(tn) {
+			return false
+		}
+	}
+	return true
+}
+
+// 各分片分别重命名，中途失败时无法回滚已重命名的分片
+func (s *ShardedDB) RenameTable(oldName, newName string) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) TruncateTable(tn string) error {
+	return s.each(func(db BoltDB) error { return db.TruncateTable(tn) })
+}
+
+// 各分片分别复制，键仍在原来的分片上
+func (s *ShardedDB) CopyTable(srcTn, dstTn string) (int, error) {
+	return s.sum(func(db BoltDB) (int, error) { return db.CopyTable(srcTn, dstTn) })
+}
+
+// 各分片分别复制到dst
+func (s *ShardedDB) CopyTableTo(dst BoltDB, tn string) (int, error) {
+	if s.isSelf(dst) {
+		return s.CopyTable(tn, tn)
+	}
+	return s.sum(func(db BoltDB) (int, error) { return db.CopyTableTo(dst, tn) })
+}
+
+// 按名字排序返回所有分片中表tn下的子表
+func (s *ShardedDB) ListCollections(tn string) ([]string, error) {
+	var names []string
+	err := s.each(func(db BoltDB) error {
+		got, err := db.ListCollections(tn)
+		names = append(names, got...)
+		return err
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// 各分片分别拆分，返回各表在所有分片中的条数之和
+func (s *ShardedDB) Shard(src string, shardFunc func(k, v []byte) string) (map[string]int, error) {
+	counts := map[string]int{}
+	err := s.each(func(db BoltDB) error {
+		got, err := db.Shard(src, shardFunc)
+		for tn, n := range got {
+			counts[tn] += n
+		}
+		return err
+	})
+	return counts, err
+}
+
+func (s *ShardedDB) TransformValues(tn string, transform func(k, v []byte) ([]byte, error)) (int, error) {
+	return s.sum(func(db BoltDB) (int, error) { return db.TransformValues(tn, transform) })
+}
+
+func (s *ShardedDB) ExtractRange(src, dst string, start, end interface{}) (int, error) {
+	return s.sum(func(db BoltDB) (int, error) { return db.ExtractRange(src, dst, start, end) })
+}
+
+func (s *ShardedDB) UpdateAll(tn string, fn func(k, v []byte) (newValue interface{}, delete bool, err error)) (int, error) {
+	return s.sum(func(db BoltDB) (int, error) { return db.UpdateAll(tn, fn) })
+}
+
+// 按分片分组，每个分片在一个事务中写入，分片之间不是原子的
+func (s *ShardedDB) SetBatch(tn string, kvs map[interface{}]interface{}) error {
+	encoded, err := encodeMap(kvs)
+	if err != nil {
+		return err
+	}
+	groups := s.group(encoded)
+	return s.eachIndex(func(i int, db BoltDB) error {
+		if len(groups[i]) == 0 {
+			return nil
+		}
+		return db.SetBatch(tn, groups[i])
+	})
+}
+
+func (s *ShardedDB) GetMulti(tn string, keys []interface{}) (map[string][]byte, error) {
+	groups, err := s.groupKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string][]byte, len(keys))
+	err = s.eachIndex(func(i int, db BoltDB) error {
+		if len(groups[i]) == 0 {
+			return nil
+		}
+		got, err := db.GetMulti(tn, groups[i])
+		for k, v := range got {
+			ret[k] = v
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// 按分片分组删除，分片之间不是原子的
+func (s *ShardedDB) DeleteMulti(tn string, keys ...interface{}) (int, error) {
+	groups, err := s.groupKeys(keys)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	err = s.eachIndex(func(i int, db BoltDB) error {
+		if len(groups[i]) == 0 {
+			return nil
+		}
+		n, err := db.DeleteMulti(tn, groups[i]...)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+func (s *ShardedDB) DeleteByPrefix(tn string, prefix []byte) (int, error) {
+	return s.sum(func(db BoltDB) (int, error) { return db.DeleteByPrefix(tn, prefix) })
+}
+
+// 事务只能在单个分片上进行
+func (s *ShardedDB) Begin(writable bool) (Txn, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *ShardedDB) Update(tn string, fn func(t Table) error) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) View(tn string, fn func(t Table) error) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) SetCodec(c Codec) {
+	for _, db := range s.shards {
+		db.SetCodec(c)
+	}
+}
+
+func (s *ShardedDB) SetTableCodec(tn string, c Codec) {
+	for _, db := range s.shards {
+		db.SetTableCodec(tn, c)
+	}
+}
+
+func (s *ShardedDB) SetObject(tn string, key, v interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.SetObject(tn, key, v)
+}
+
+func (s *ShardedDB) GetObject(tn string, key, out interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.GetObject(tn, key, out)
+}
+
+func (s *ShardedDB) Save(tn string, v interface{}) error {
+	key, err := objectKey(v)
+	if err != nil {
+		return err
+	}
+	return s.SetObject(tn, key, v)
+}
+
+func (s *ShardedDB) Load(tn string, key, out interface{}) error {
+	return s.GetObject(tn, key, out)
+}
+
+func (s *ShardedDB) CompareAndSwap(tn string, key, old, new interface{}) (bool, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return false, err
+	}
+	return db.CompareAndSwap(tn, key, old, new)
+}
+
+func (s *ShardedDB) Incr(tn, key string, delta int64) (int64, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.Incr(tn, key, delta)
+}
+
+func (s *ShardedDB) Decr(tn, key string, delta int64) (int64, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.Decr(tn, key, delta)
+}
+
+func (s *ShardedDB) Append(tn string, key, data interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.Append(tn, key, data)
+}
+
+func (s *ShardedDB) GetSet(tn string, key, value interface{}) ([]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetSet(tn, key, value)
+}
+
+func (s *ShardedDB) SetNX(tn string, key, value interface{}) (bool, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return false, err
+	}
+	return db.SetNX(tn, key, value)
+}
+
+func (s *ShardedDB) SetWithTTL(tn string, key, value interface{}, ttl time.Duration) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.SetWithTTL(tn, key, value, ttl)
+}
+
+func (s *ShardedDB) PurgeExpired() (int, error) {
+	return s.sum(func(db BoltDB) (int, error) { return db.PurgeExpired() })
+}
+
+func (s *ShardedDB) OnExpire(tn string, fn EventFunc) {
+	for _, db := range s.shards {
+		db.OnExpire(tn, fn)
+	}
+}
+
+func (s *ShardedDB) OnDelete(tn string, fn EventFunc) {
+	for _, db := range s.shards {
+		db.OnDelete(tn, fn)
+	}
+}
+
+// 合并所有分片的订阅，同一分片的变化按顺序送达，不同分片之间没有先后
+func (s *ShardedDB) Watch(tn string, prefix []byte) (<-chan Change, func()) {
+	out := make(chan Change)
+	done := make(chan struct{})
+	cancels := make([]func(), 0, len(s.shards))
+	var wg sync.WaitGroup
+	for _, db := range s.shards {
+		ch, cancel := db.Watch(tn, prefix)
+		cancels = append(cancels, cancel)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range ch {
+				select {
+				case out <- c:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			for _, cancel := range cancels {
+				cancel()
+			}
+		})
+	}
+}
+
+func (s *ShardedDB) RegisterHook(point HookPoint, fn HookFunc) {
+	for _, db := range s.shards {
+		db.RegisterHook(point, fn)
+	}
+}
+
+// 每个分片各自记录审计，序号各自独立，用ShardAt(i).AuditLog读取
+func (s *ShardedDB) SetAudit(mode AuditMode) {
+	for _, db := range s.shards {
+		db.SetAudit(mode)
+	}
+}
+
+func (s *ShardedDB) AuditLog(after uint64, limit int) ([]AuditRecord, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *ShardedDB) TruncateAudit(upTo uint64) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// 每个分片各自记录变更，序号各自独立，用ShardAt(i)读取和发送
+func (s *ShardedDB) SetCDC(enabled bool) {
+	for _, db := range s.shards {
+		db.SetCDC(enabled)
+	}
+}
+
+func (s *ShardedDB) StreamChanges(name string, sink ChangeSink) (func(), error) {
+	return nil, ErrUnsupported
+}
+
+func (s *ShardedDB) ReadChanges(after uint64, limit int) ([]ChangeRecord, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *ShardedDB) ChangeCursor(name string) (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+func (s *ShardedDB) TruncateChanges(upTo uint64) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// 备份须是所有分片同一时刻的快照，各分片的只读事务无法做到
+func (s *ShardedDB) Backup(w io.Writer) (int64, error) {
+	return 0, ErrUnsupported
+}
+
+func (s *ShardedDB) BackupToFile(path string) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) BackupTo(sink BackupSink, name string) (int64, error) {
+	return 0, ErrUnsupported
+}
+
+func (s *ShardedDB) Restore(r io.Reader) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) RestoreFile(path string) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) ScheduleBackups(sch BackupSchedule) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) StopBackups() {
+	for _, db := range s.shards {
+		db.StopBackups()
+	}
+}
+
+// dstPath为空时各分片原地压缩，否则dstPath是目录，各分片压缩到其中同名的分片文件
+func (s *ShardedDB) Compact(dstPath string) error {
+	if dstPath == "" {
+		return s.each(func(db BoltDB) error { return db.Compact("") })
+	}
+	if abs, err := filepath.Abs(dstPath); err == nil {
+		if cur, err := filepath.Abs(s.dir); err == nil && abs == cur {
+			return fmt.Errorf("compact into the open directory %v", dstPath)
+		}
+	}
+	if err := os.MkdirAll(dstPath, 0755); err != nil {
+		return err
+	}
+	return s.eachIndex(func(i int, db BoltDB) error { return db.Compact(shardPath(dstPath, i)) })
+}
+
+// 各分片分别建立索引，记录与索引在同一分片上
+func (s *ShardedDB) CreateIndex(tn, name string, fn IndexFunc) error {
+	return s.each(func(db BoltDB) error { return db.CreateIndex(tn, name, fn) })
+}
+
+// 每个分片只能检查自己的记录，无法保证跨分片唯一
+func (s *ShardedDB) CreateUniqueIndex(tn, name string, fn IndexFunc) error {
+	return ErrUnsupported
+}
+
+// 同CreateIndex，sample中有bdb:"unique"字段时返回ErrUnsupported
+func (s *ShardedDB) CreateIndexes(tn string, sample interface{}) error {
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return fmt.Errorf("invalid sample %v", sample)
+	}
+	m, err := modelOf(t)
+	if err != nil {
+		return err
+	}
+	for _, i := range m.indexes {
+		if i.unique {
+			return ErrUnsupported
+		}
+	}
+	return s.each(func(db BoltDB) error { return db.CreateIndexes(tn, sample) })
+}
+
+// 查询依赖单个文件中的索引和编解码方式，Run返回ErrUnsupported
+func (s *ShardedDB) Query(tn string) *Query {
+	return &Query{tn: tn, err: ErrUnsupported}
+}
+
+func (s *ShardedDB) ValueSize(tn string, key interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.ValueSize(tn, key)
+}
+
+func (s *ShardedDB) GetBigInt(tn string, key interface{}) (*big.Int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetBigInt(tn, key)
+}
+
+func (s *ShardedDB) GetBigRat(tn string, key interface{}) (*big.Rat, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetBigRat(tn, key)
+}
+
+// Add的键是所在文件的序列号，各分片的序列号会重复
+func (s *ShardedDB) Add(tn string, value interface{}) error {
+	return ErrUnsupported
+}
+
+// 先生成UUID再写入它所在的分片，已存在时重新生成
+func (s *ShardedDB) AddUUID(tn string, value interface{}) (string, error) {
+	for {
+		id, err := newUUID()
+		if err != nil {
+			return "", fmt.Errorf("generate uuid error:%v", err)
+		}
+		db, err := s.route(id)
+		if err != nil {
+			return "", err
+		}
+		ok, err := db.SetNX(tn, id, value)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return id, nil
+		}
+	}
+}
+
+func (s *ShardedDB) AddWithKey(tn string, id uint64, value interface{}) error {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return s.shards[s.shardOf(k)].AddWithKey(tn, id, value)
+}
+
+// 队列的项分布在各分片上，移动无法原子地完成
+func (s *ShardedDB) DrainBatch(srcQueue, dstTable string, n int) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// 导出的序列号和内部表属于单个文件
+func (s *ShardedDB) ExportBinary(w io.Writer) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) ImportBinary(r io.Reader, policy ConflictPolicy) error {
+	return ErrUnsupported
+}
+
+// 熔断器在每个分片上分别计数
+func (s *ShardedDB) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	for _, db := range s.shards {
+		db.SetCircuitBreaker(threshold, cooldown)
+	}
+}
+
+// 各分片中最严重的状态、最多的连续失败次数和最近的熔断时间
+func (s *ShardedDB) CircuitStats() CircuitStats {
+	var ret CircuitStats
+	for _, db := range s.shards {
+		st := db.CircuitStats()
+		if st.State == CircuitOpen || st.State == CircuitHalfOpen && ret.State == CircuitClosed {
+			ret.State = st.State
+		}
+		ret.Failures = max(ret.Failures, st.Failures)
+		if st.OpenedAt.After(ret.OpenedAt) {
+			ret.OpenedAt = st.OpenedAt
+		}
+	}
+	return ret
+}
+
+// 每个分片各自最多排队n个写操作
+func (s *ShardedDB) SetMaxPendingWrites(n int) {
+	for _, db := range s.shards {
+		db.SetMaxPendingWrites(n)
+	}
+}
+
+func (s *ShardedDB) PendingWrites() int {
+	n := 0
+	for _, db := range s.shards {
+		n += db.PendingWrites()
+	}
+	return n
+}
+
+// 所有分片的写都同步到other，other为s本身时取消
+func (s *ShardedDB) SetMirror(other BoltDB) {
+	if other != nil && s.isSelf(other) {
+		// 分片的写事务中写回自身的分片会死锁
+		other = nil
+	}
+	for _, db := range s.shards {
+		db.SetMirror(other)
+	}
+}
+
+func (s *ShardedDB) SetMirrorStrict(strict bool) {
+	for _, db := range s.shards {
+		db.SetMirrorStrict(strict)
+	}
+}
+
+func (s *ShardedDB) SetOpTimeout(d time.Duration) {
+	for _, db := range s.shards {
+		db.SetOpTimeout(d)
+	}
+}
+
+func (s *ShardedDB) GetCtx(ctx context.Context, tn string, key interface{}) ([]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetCtx(ctx, tn, key)
+}
+
+func (s *ShardedDB) SetCtx(ctx context.Context, tn string, key, value interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.SetCtx(ctx, tn, key, value)
+}
+
+func (s *ShardedDB) DeleteCtx(ctx context.Context, tn string, key interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.DeleteCtx(ctx, tn, key)
+}
+
+// 同一纳秒已有点时顺延，顺延后的键可能属于另一个分片
+func (s *ShardedDB) AppendPoint(tn string, t time.Time, value []byte) error {
+	return ErrUnsupported
+}
+
+func (s *ShardedDB) SAdd(tn string, key interface{}, members ...interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.SAdd(tn, key, members...)
+}
+
+func (s *ShardedDB) SRem(tn string, key interface{}, members ...interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.SRem(tn, key, members...)
+}
+
+func (s *ShardedDB) SIsMember(tn string, key, member interface{}) (bool, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return false, err
+	}
+	return db.SIsMember(tn, key, member)
+}
+
+func (s *ShardedDB) SMembers(tn string, key interface{}) ([][]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.SMembers(tn, key)
+}
+
+func (s *ShardedDB) SCard(tn string, key interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.SCard(tn, key)
+}
+
+func (s *ShardedDB) ZAdd(tn string, key, member interface{}, score float64) (bool, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return false, err
+	}
+	return db.ZAdd(tn, key, member, score)
+}
+
+func (s *ShardedDB) ZRem(tn string, key, member interface{}) (bool, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return false, err
+	}
+	return db.ZRem(tn, key, member)
+}
+
+func (s *ShardedDB) ZScore(tn string, key, member interface{}) (float64, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.ZScore(tn, key, member)
+}
+
+func (s *ShardedDB) ZRank(tn string, key, member interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.ZRank(tn, key, member)
+}
+
+func (s *ShardedDB) ZRangeByScore(tn string, key interface{}, min, max float64) ([]ZMember, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.ZRangeByScore(tn, key, min, max)
+}
+
+func (s *ShardedDB) ZCard(tn string, key interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.ZCard(tn, key)
+}
+
+func (s *ShardedDB) LPush(tn string, key interface{}, values ...interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.LPush(tn, key, values...)
+}
+
+func (s *ShardedDB) RPush(tn string, key interface{}, values ...interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.RPush(tn, key, values...)
+}
+
+func (s *ShardedDB) LPop(tn string, key interface{}) ([]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.LPop(tn, key)
+}
+
+func (s *ShardedDB) RPop(tn string, key interface{}) ([]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.RPop(tn, key)
+}
+
+func (s *ShardedDB) LIndex(tn string, key interface{}, i int) ([]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.LIndex(tn, key, i)
+}
+
+func (s *ShardedDB) LRange(tn string, key interface{}, start, stop int) ([][]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.LRange(tn, key, start, stop)
+}
+
+func (s *ShardedDB) LLen(tn string, key interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.LLen(tn, key)
+}
+
+func (s *ShardedDB) HSet(tn string, key, field, value interface{}) error {
+	db, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return db.HSet(tn, key, field, value)
+}
+
+func (s *ShardedDB) HGet(tn string, key, field interface{}) ([]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.HGet(tn, key, field)
+}
+
+func (s *ShardedDB) HDel(tn string, key interface{}, fields ...interface{}) (int, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return 0, err
+	}
+	return db.HDel(tn, key, fields...)
+}
+
+func (s *ShardedDB) HGetAll(tn string, key interface{}) (map[string][]byte, error) {
+	db, err := s.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return db.HGetAll(tn, key)
+}
+
+// 每个分片各自缓冲
+func (s *ShardedDB) SetWriteBuffer(interval time.Duration, size int) {
+	for _, db := range s.shards {
+		db.SetWriteBuffer(interval, size)
+	}
+}
+
+func (s *ShardedDB) Flush() error {
+	return s.each(func(db BoltDB) error { return db.Flush() })
+}
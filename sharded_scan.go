@@ -0,0 +1,477 @@
+package bdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+	"time"
+)
+
+// 把各分片的迭代器归并成一个：各分片内有序且键不重复，前进时取最小的键，后退时取最大的键
+type mergedIterator struct {
+	its     []Iterator
+	cur     Iterator // 当前键所在分片的迭代器，nil表示无效
+	forward bool     // 上一次移动的方向，其余分片停在当前键的这一侧
+}
+
+// 在每个分片上打开表tn的迭代器并归并，每个分片各持有一个只读事务，初始指向第一个键
+func (s *ShardedDB) NewIterator(tn string) (Iterator, error) {
+	m := &mergedIterator{its: make([]Iterator, 0, len(s.shards))}
+	for i, db := range s.shards {
+		it, err := db.NewIterator(tn)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		m.its = append(m.its, it)
+	}
+	m.First()
+	return m, nil
+}
+
+// 在各分片迭代器的当前位置中前进时取最小的键，后退时取最大的键
+func (m *mergedIterator) pick(forward bool) {
+	m.forward = forward
+	m.cur = nil
+	for _, it := range m.its {
+		if !it.Valid() {
+			continue
+		}
+		if m.cur == nil {
+			m.cur = it
+			continue
+		}
+		c := bytes.Compare(it.Key(), m.cur.Key())
+		if forward && c < 0 || !forward && c > 0 {
+			m.cur = it
+		}
+	}
+}
+
+func (m *mergedIterator) First() {
+	for _, it := range m.its {
+		it.First()
+	}
+	m.pick(true)
+}
+
+func (m *mergedIterator) Last() {
+	for _, it := range m.its {
+		it.Last()
+	}
+	m.pick(false)
+}
+
+func (m *mergedIterator) Seek(key []byte) {
+	for _, it := range m.its {
+		it.Seek(key)
+	}
+	m.pick(true)
+}
+
+func (m *mergedIterator) Next() {
+	if !m.Valid() {
+		return
+	}
+	if !m.forward {
+		// 其余分片停在小于当前键处，先移到大于当前键的位置
+		k := append([]byte(nil), m.cur.Key()...)
+		for _, it := range m.its {
+			if it == m.cur {
+				continue
+			}
+			it.Seek(k)
+			if it.Valid() && bytes.Equal(it.Key(), k) {
+				it.Next()
+			}
+		}
+	}
+	m.cur.Next()
+	m.pick(true)
+}
+
+func (m *mergedIterator) Prev() {
+	if !m.Valid() {
+		return
+	}
+	if m.forward {
+		// 其余分片停在大于当前键处，先移到小于当前键的位置
+		k := append([]byte(nil), m.cur.Key()...)
+		for _, it := range m.its {
+			if it == m.cur {
+				continue
+			}
+			it.Seek(k)
+			if it.Valid() {
+				it.Prev()
+			} else {
+				it.Last()
+			}
+		}
+	}
+	m.cur.Prev()
+	m.pick(false)
+}
+
+func (m *mergedIterator) Valid() bool {
+	return m.cur != nil && m.cur.Valid()
+}
+
+func (m *mergedIterator) Key() []byte {
+	if !m.Valid() {
+		return nil
+	}
+	return m.cur.Key()
+}
+
+func (m *mergedIterator) Value() []byte {
+	if !m.Valid() {
+		return nil
+	}
+	return m.cur.Value()
+}
+
+// 关闭所有分片的迭代器，返回第一个错误
+func (m *mergedIterator) Close() error {
+	m.cur = nil
+	var first error
+	for _, it := range m.its {
+		if err := it.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// 在表tn的归并迭代器上执行fn，结束后关闭
+func (s *ShardedDB) iterate(tn string, fn func(it Iterator) error) error {
+	it, err := s.NewIterator(tn)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	return fn(it)
+}
+
+// 定位到严格大于after的第一个键，after为nil时定位到第一个键
+func seekAfterIter(it Iterator, after []byte) {
+	if after == nil {
+		it.First()
+		return
+	}
+	it.Seek(after)
+	if it.Valid() && bytes.Equal(it.Key(), after) {
+		it.Next()
+	}
+}
+
+// 按键的顺序遍历所有分片中以prefix开头的键，prefix为空时遍历全表。fn返回错误时停止并返回该错误
+func (s *ShardedDB) Scan(tn string, prefix []byte, fn func(k, v []byte) error) error {
+	return s.ScanCtx(context.Background(), tn, prefix, fn)
+}
+
+// ctx结束时停止并返回结束的原因
+func (s *ShardedDB) ScanCtx(ctx context.Context, tn string, prefix []byte, fn func(k, v []byte) error) error {
+	return s.iterate(tn, func(it Iterator) error {
+		for it.Seek(prefix); it.Valid() && bytes.HasPrefix(it.Key(), prefix); it.Next() {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			if err := fn(it.Key(), it.Value()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *ShardedDB) Tarverse(tn string, tar func(k, v []byte) []byte) []byte {
+	ret, _ := s.TarverseCtx(context.Background(), tn, tar)
+	return ret
+}
+
+// 按键的顺序遍历所有分片，ctx结束时停止，返回已遍历部分的结果和原因
+func (s *ShardedDB) TarverseCtx(ctx context.Context, tn string, tar func(k, v []byte) []byte) ([]byte, error) {
+	var ret bytes.Buffer
+	err := s.ScanCtx(ctx, tn, nil, func(k, v []byte) error {
+		ret.Write(tar(k, v))
+		ret.WriteByte(' ')
+		return nil
+	})
+	return ret.Bytes(), err
+}
+
+// 键按字节序比较，start、end为nil表示不限。fn返回错误时停止并返回该错误
+func (s *ShardedDB) GetRange(tn string, start, end interface{}, fn func(k, v []byte) error) error {
+	st, e, err := rangeBounds(start, end)
+	if err != nil {
+		return err
+	}
+	return s.iterate(tn, func(it Iterator) error {
+		if st != nil {
+			it.Seek(st)
+		}
+		for ; it.Valid() && (e == nil || bytes.Compare(it.Key(), e) < 0); it.Next() {
+			if err := fn(it.Key(), it.Value()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 从最后一个键开始倒序遍历，limit<=0表示不限，fn返回错误时停止并返回该错误
+func (s *ShardedDB) ForEachReverse(tn string, limit int, fn func(k, v []byte) error) error {
+	return s.iterate(tn, func(it Iterator) error {
+		n := 0
+		for it.Last(); it.Valid() && (limit <= 0 || n < limit); it.Prev() {
+			if err := fn(it.Key(), it.Value()); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+}
+
+// 同dbConnection.ForEachResumable，断点是所有分片合并后的键序中的位置
+func (s *ShardedDB) ForEachResumable(tn string, afterKey []byte, fn func(k, v []byte) error) (lastKey []byte, err error) {
+	lastKey = append([]byte(nil), afterKey...)
+	err = s.iterate(tn, func(it Iterator) error {
+		for seekAfterIter(it, afterKey); it.Valid(); it.Next() {
+			if err := fn(it.Key(), it.Value()); err != nil {
+				return err
+			}
+			lastKey = append(lastKey[:0], it.Key()...)
+		}
+		return nil
+	})
+	return lastKey, err
+}
+
+// afterKey为nil时取第一页，之后把返回的nextKey原样传回即取下一页；没有更多数据时nextKey为nil
+func (s *ShardedDB) Page(tn string, afterKey []byte, limit int) (items []KV, nextKey []byte, err error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page limit:%d", limit)
+	}
+
+	err = s.iterate(tn, func(it Iterator) error {
+		items = make([]KV, 0, limit)
+		for seekAfterIter(it, afterKey); it.Valid() && len(items) < limit; it.Next() {
+			items = append(items, copyKV(it.Key(), it.Value()))
+		}
+		if it.Valid() {
+			nextKey = items[len(items)-1].Key
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, nextKey, nil
+}
+
+// 只拷贝键不拷贝值，limit<=0表示不限。子表不在其中
+func (s *ShardedDB) Keys(tn string, limit int) ([][]byte, error) {
+	return s.KeysWithPrefix(tn, nil, limit)
+}
+
+// 同Keys，只列出以prefix开头的键
+func (s *ShardedDB) KeysWithPrefix(tn string, prefix []byte, limit int) (keys [][]byte, err error) {
+	err = s.iterate(tn, func(it Iterator) error {
+		keys = [][]byte{}
+		for it.Seek(prefix); it.Valid() && bytes.HasPrefix(it.Key(), prefix); it.Next() {
+			if limit > 0 && len(keys) >= limit {
+				break
+			}
+			if it.Value() != nil {
+				keys = append(keys, append([]byte{}, it.Key()...))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// prev为小于pivot的最多before条，next为从pivot(含)开始的最多after条，都按键升序排列
+func (s *ShardedDB) Around(tn string, pivot interface{}, before, after int) (prev, next []KV, err error) {
+	p, err := keyToBytes(pivot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = s.iterate(tn, func(it Iterator) error {
+		for it.Seek(p); it.Valid() && len(next) < after; it.Next() {
+			next = append(next, copyKV(it.Key(), it.Value()))
+		}
+
+		it.Seek(p)
+		if !it.Valid() {
+			it.Last()
+		} else {
+			it.Prev()
+		}
+		for ; it.Valid() && len(prev) < before; it.Prev() {
+			prev = append(prev, copyKV(it.Key(), it.Value()))
+		}
+		for i, j := 0, len(prev)-1; i < j; i, j = i+1, j-1 {
+			prev[i], prev[j] = prev[j], prev[i]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prev, next, nil
+}
+
+// 把所有分片中的JSON值按键的顺序拼成数组写到w，validate的含义同dbConnection.ScanJSON
+func (s *ShardedDB) ScanJSON(tn string, w io.Writer, validate bool) error {
+	return s.iterate(tn, func(it Iterator) error {
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		first := true
+		for ; it.Valid(); it.Next() {
+			v := it.Value()
+			if v == nil {
+				// 子表
+				continue
+			}
+			if validate && !json.Valid(v) {
+				return fmt.Errorf("value of %v.%q is not valid JSON", tn, it.Key())
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := w.Write(v); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "]")
+		return err
+	})
+}
+
+// 先定位到通配符的字面前缀，只检查带该前缀的键
+func (s *ShardedDB) Match(tn string, pattern string, fn func(k, v []byte) bool) error {
+	prefix, err := globPrefix(pattern)
+	if err != nil {
+		return err
+	}
+	return s.iterate(tn, func(it Iterator) error {
+		for it.Seek(prefix); it.Valid() && bytes.HasPrefix(it.Key(), prefix); it.Next() {
+			if globMatch(pattern, it.Key()) && !fn(it.Key(), it.Value()) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// range遍历所有分片合并后的整张表
+func (s *ShardedDB) All(tn string) iter.Seq2[[]byte, []byte] {
+	return s.Prefix(tn, nil)
+}
+
+// 以p开头的键值的迭代器，其余同All
+func (s *ShardedDB) Prefix(tn string, p []byte) iter.Seq2[[]byte, []byte] {
+	return func(yield func(k, v []byte) bool) {
+		s.iterate(tn, func(it Iterator) error {
+			for it.Seek(p); it.Valid() && bytes.HasPrefix(it.Key(), p); it.Next() {
+				if !yield(it.Key(), it.Value()) {
+					return nil
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// 按指定类型导出所有分片中的整张表
+func (s *ShardedDB) AsMap(tn string, keyKind, valKind Kind) (map[interface{}]interface{}, error) {
+	ret := make(map[interface{}]interface{})
+	err := s.iterate(tn, func(it Iterator) error {
+		for ; it.Valid(); it.Next() {
+			if err := putKind(ret, it.Key(), it.Value(), keyKind, valKind); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// 按合并后的键序计算，与同样内容的单个库的TableHash相同，与分片数无关
+func (s *ShardedDB) TableHash(tn string) (sum []byte, err error) {
+	err = s.iterate(tn, func(it Iterator) error {
+		h := sha256.New()
+		for ; it.Valid(); it.Next() {
+			hashKV(h, it.Key(), it.Value())
+		}
+		sum = h.Sum(nil)
+		return nil
+	})
+	return sum, err
+}
+
+// 返回索引name中值为value的所有记录，按主键排序
+func (s *ShardedDB) GetByIndex(tn, name string, value interface{}) ([]KV, error) {
+	kvs := []KV{}
+	err := s.each(func(db BoltDB) error {
+		got, err := db.GetByIndex(tn, name, value)
+		kvs = append(kvs, got...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(kvs, func(i, j int) bool { return bytes.Compare(kvs[i].Key, kvs[j].Key) < 0 })
+	return kvs, nil
+}
+
+// 按时间先后返回[from, to)内的点，不是8字节的键跳过
+func (s *ShardedDB) QueryRange(tn string, from, to time.Time) ([]Point, error) {
+	var points []Point
+	err := s.walkPoints(tn, from, to, func(t time.Time, v []byte) {
+		points = append(points, Point{Time: t, Value: append([]byte{}, v...)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// 同dbConnection.Downsample，各分片的点按时间归并后分组
+func (s *ShardedDB) Downsample(tn string, from, to time.Time, step time.Duration, fn DownsampleFunc) ([]Point, error) {
+	return downsample(from, step, fn, func(each func(t time.Time, v []byte)) error {
+		return s.walkPoints(tn, from, to, each)
+	})
+}
+
+func (s *ShardedDB) walkPoints(tn string, from, to time.Time, fn func(t time.Time, v []byte)) error {
+	st, e, err := pointRange(from, to)
+	if err != nil || st == nil {
+		return err
+	}
+	return s.GetRange(tn, st, e, func(k, v []byte) error {
+		if len(k) == 8 && v != nil {
+			fn(pointTime(k), v)
+		}
+		return nil
+	})
+}
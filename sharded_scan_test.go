@@ -0,0 +1,207 @@
+package bdb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMergedIterator(t *testing.T) {
+	s := openTestSharded(t, 3, "test")
+	var want []string
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("k%02d", i)
+		want = append(want, k)
+		if err := s.Set("test", k, i); err != nil {
+			t.Fatalf("s.Set(%v) failed, err=%v", k, err)
+		}
+	}
+
+	it, err := s.NewIterator("test")
+	if err != nil {
+		t.Fatalf("s.NewIterator() failed, err=%v", err)
+	}
+	defer it.Close()
+
+	var got []string
+	for ; it.Valid(); it.Next() {
+		got = append(got, string(it.Key()))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("forward == %v, want %v", got, want)
+	}
+
+	got = nil
+	for it.Last(); it.Valid(); it.Prev() {
+		got = append(got, string(it.Key()))
+	}
+	if len(got) != 30 || got[0] != "k29" || got[29] != "k00" {
+		t.Errorf("backward == %v, want k29..k00", got)
+	}
+
+	// 改变方向时其余分片重新定位，不跳过也不重复
+	it.Seek([]byte("k10"))
+	steps := []struct {
+		move func()
+		want string
+	}{
+		{it.Next, "k11"},
+		{it.Next, "k12"},
+		{it.Prev, "k11"},
+		{it.Prev, "k10"},
+		{it.Prev, "k09"},
+		{it.Next, "k10"},
+	}
+	for i, step := range steps {
+		step.move()
+		if got := string(it.Key()); got != step.want {
+			t.Errorf("step %d at %q, want %q", i, got, step.want)
+		}
+	}
+	it.Seek([]byte("z"))
+	if it.Valid() {
+		t.Errorf("it.Seek(z).Valid() == true, want false")
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("it.Close() failed, err=%v", err)
+	}
+	if it.Valid() {
+		t.Errorf("it.Valid() after Close == true")
+	}
+}
+
+func TestShardedScans(t *testing.T) {
+	s := openTestSharded(t, 4, "test")
+	single := openTestDB(t, "test")
+	for i := 0; i < 25; i++ {
+		k, v := fmt.Sprintf("k%02d", i), fmt.Sprintf(`{"n":%d}`, i)
+		s.Set("test", k, v)
+		single.Set("test", k, v)
+	}
+
+	if got := string(s.Tarverse("test", func(k, v []byte) []byte { return k })); got != string(single.Tarverse("test", func(k, v []byte) []byte { return k })) {
+		t.Errorf("s.Tarverse() == %q, want same as a single db", got)
+	}
+
+	// 分页跨越分片，拼起来是完整的表
+	var keys []string
+	var after []byte
+	for {
+		items, next, err := s.Page("test", after, 7)
+		if err != nil {
+			t.Fatalf("s.Page() failed, err=%v", err)
+		}
+		for _, kv := range items {
+			keys = append(keys, string(kv.Key))
+		}
+		if next == nil {
+			break
+		}
+		after = next
+	}
+	if len(keys) != 25 || keys[0] != "k00" || keys[24] != "k24" {
+		t.Errorf("pages == %v, want k00..k24", keys)
+	}
+
+	prev, next, err := s.Around("test", "k10", 2, 2)
+	if err != nil || len(prev) != 2 || string(prev[0].Key) != "k08" || len(next) != 2 || string(next[1].Key) != "k11" {
+		t.Errorf("s.Around(k10) == %v, %v, %v, want k08 k09 | k10 k11", prev, next, err)
+	}
+
+	var last []string
+	s.ForEachReverse("test", 3, func(k, v []byte) error {
+		last = append(last, string(k))
+		return nil
+	})
+	if fmt.Sprint(last) != "[k24 k23 k22]" {
+		t.Errorf("s.ForEachReverse(3) == %v, want [k24 k23 k22]", last)
+	}
+
+	if ks, err := s.KeysWithPrefix("test", []byte("k1"), 0); err != nil || len(ks) != 10 {
+		t.Errorf("s.KeysWithPrefix(k1) == %q, %v, want 10 keys", ks, err)
+	}
+
+	var buf, want bytes.Buffer
+	s.ScanJSON("test", &buf, true)
+	single.ScanJSON("test", &want, true)
+	if buf.String() != want.String() {
+		t.Errorf("s.ScanJSON() == %s, want %s", buf.String(), want.String())
+	}
+
+	// 哈希与分片数无关
+	sum, err := s.TableHash("test")
+	if err != nil {
+		t.Fatalf("s.TableHash() failed, err=%v", err)
+	}
+	if ws, _ := single.TableHash("test"); !bytes.Equal(sum, ws) {
+		t.Errorf("s.TableHash() == %x, want %x", sum, ws)
+	}
+}
+
+func TestShardedPoints(t *testing.T) {
+	s := openTestSharded(t, 3, "series")
+	base := time.Unix(1000, 0)
+	for i := 0; i < 6; i++ {
+		k, _ := pointKey(base.Add(time.Duration(i) * time.Second))
+		if err := s.Set("series", k, []byte{byte(i)}); err != nil {
+			t.Fatalf("s.Set(point %d) failed, err=%v", i, err)
+		}
+	}
+
+	points, err := s.QueryRange("series", base.Add(time.Second), base.Add(5*time.Second))
+	if err != nil || len(points) != 4 || !points[0].Time.Equal(base.Add(time.Second)) {
+		t.Errorf("s.QueryRange() == %v, %v, want 4 points from base+1s", points, err)
+	}
+	for i := 1; i < len(points); i++ {
+		if !points[i-1].Time.Before(points[i].Time) {
+			t.Errorf("s.QueryRange() out of order at %d", i)
+		}
+	}
+
+	sum := func(start time.Time, values [][]byte) []byte {
+		n := byte(0)
+		for _, v := range values {
+			n += v[0]
+		}
+		return []byte{n}
+	}
+	down, err := s.Downsample("series", base, base.Add(6*time.Second), 3*time.Second, sum)
+	if err != nil || len(down) != 2 || down[0].Value[0] != 0+1+2 || down[1].Value[0] != 3+4+5 {
+		t.Errorf("s.Downsample() == %v, %v, want sums 3 and 12", down, err)
+	}
+}
+
+func TestShardedWatch(t *testing.T) {
+	s := openTestSharded(t, 4, "test")
+	ch, cancel := s.Watch("test", nil)
+
+	// 键分布在不同的分片上，都能收到
+	want := map[string]bool{}
+	for i := 0; i < 8; i++ {
+		k := fmt.Sprintf("k%d", i)
+		want[k] = true
+		if err := s.Set("test", k, i); err != nil {
+			t.Fatalf("s.Set(%v) failed, err=%v", k, err)
+		}
+	}
+	for len(want) > 0 {
+		select {
+		case c := <-ch:
+			delete(want, string(c.Key))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("missing changes for %v", want)
+		}
+	}
+
+	cancel()
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("received a change after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("channel not closed after cancel")
+	}
+}
@@ -0,0 +1,241 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShardedDB(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shards")
+	s, err := OpenSharded(dir, 4, 0600, nil)
+	if err != nil {
+		t.Fatalf("OpenSharded() failed, err=%v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateTable("test"); err != nil {
+		t.Fatalf("s.CreateTable() failed, err=%v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := s.Set("test", fmt.Sprintf("k%03d", i), i); err != nil {
+			t.Fatalf("s.Set(%d) failed, err=%v", i, err)
+		}
+	}
+	if got := string(s.Get("test", "k042")); got != "42" {
+		t.Errorf("s.Get(k042) == %q, want %q", got, "42")
+	}
+	if n, err := s.Count("test"); err != nil || n != 100 {
+		t.Errorf("s.Count() == %d, %v, want 100, nil", n, err)
+	}
+
+	// 键分散在各个分片上
+	for i := 0; i < s.Shards(); i++ {
+		if n, _ := s.ShardAt(i).Count("test"); n == 0 || n == 100 {
+			t.Errorf("shard %d has %d keys, want some", i, n)
+		}
+	}
+
+	// 跨分片遍历仍按键排序
+	var keys [][]byte
+	s.Scan("test", []byte("k01"), func(k, v []byte) error {
+		keys = append(keys, append([]byte{}, k...))
+		return nil
+	})
+	if len(keys) != 10 || string(keys[0]) != "k010" || string(keys[9]) != "k019" {
+		t.Errorf("s.Scan(k01) == %q, want k010..k019", keys)
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+			t.Errorf("s.Scan() out of order: %q before %q", keys[i-1], keys[i])
+		}
+	}
+
+	if err := s.Delete("test", "k042"); err != nil {
+		t.Errorf("s.Delete() failed, err=%v", err)
+	}
+	if _, err := s.GetValue("test", "k042"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("s.GetValue(deleted) err=%v, want ErrKeyNotFound", err)
+	}
+
+	// 分片数不同时拒绝打开
+	if _, err := OpenSharded(dir, 3, 0600, nil); err == nil {
+		t.Errorf("OpenSharded(3) on a 4 shard dir err=nil, want error")
+	}
+
+	dst, err := s.Reshard(filepath.Join(t.TempDir(), "resharded"), 3, 0600, nil)
+	if err != nil {
+		t.Fatalf("s.Reshard() failed, err=%v", err)
+	}
+	defer dst.Close()
+	if n, err := dst.Count("test"); err != nil || n != 99 {
+		t.Errorf("dst.Count() == %d, %v, want 99, nil", n, err)
+	}
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprintf("k%03d", i)
+		want := fmt.Sprint(i)
+		if i == 42 {
+			want = ""
+		}
+		if got := string(dst.Get("test", k)); got != want {
+			t.Errorf("dst.Get(%v) == %q, want %q", k, got, want)
+		}
+	}
+	if _, err := s.Reshard(dir, 2, 0600, nil); err == nil {
+		t.Errorf("s.Reshard(own dir) err=nil, want error")
+	}
+
+	// 目标目录已有分片时不能覆盖其中的数据
+	other := filepath.Join(t.TempDir(), "other")
+	o, err := OpenSharded(other, 3, 0600, nil)
+	if err != nil {
+		t.Fatalf("OpenSharded(other) failed, err=%v", err)
+	}
+	o.CreateTable("test")
+	o.Set("test", "k001", "keep")
+	o.Close()
+	if _, err := s.Reshard(other, 3, 0600, nil); err == nil {
+		t.Errorf("s.Reshard(non-empty dir) err=nil, want error")
+	}
+	o, err = OpenSharded(other, 3, 0600, nil)
+	if err != nil {
+		t.Fatalf("OpenSharded(other) again failed, err=%v", err)
+	}
+	defer o.Close()
+	if got := string(o.Get("test", "k001")); got != "keep" {
+		t.Errorf("o.Get(k001) after refused Reshard == %q, want keep", got)
+	}
+}
+
+// 打开dir下有n个分片的库并创建tables
+func openTestSharded(t *testing.T, n int, tables ...string) *ShardedDB {
+	t.Helper()
+	s, err := OpenSharded(filepath.Join(t.TempDir(), "shards"), n, 0600, nil)
+	if err != nil {
+		t.Fatalf("OpenSharded() failed, err=%v", err)
+	}
+	t.Cleanup(s.Close)
+	for _, tn := range tables {
+		if err := s.CreateTable(tn); err != nil {
+			t.Fatalf("s.CreateTable(%q) failed, err=%v", tn, err)
+		}
+	}
+	return s
+}
+
+func TestShardedBoltDB(t *testing.T) {
+	s := openTestSharded(t, 4, "test")
+	var db BoltDB = s
+
+	kvs := map[interface{}]interface{}{}
+	keys := []interface{}{}
+	for i := 0; i < 20; i++ {
+		k := fmt.Sprintf("k%02d", i)
+		kvs[k] = i
+		keys = append(keys, k)
+	}
+	if err := db.SetBatch("test", kvs); err != nil {
+		t.Fatalf("db.SetBatch() failed, err=%v", err)
+	}
+	got, err := db.GetMulti("test", keys)
+	if err != nil || len(got) != 20 || string(got["k07"]) != "7" {
+		t.Errorf("db.GetMulti() == %v, %v, want 20 keys", got, err)
+	}
+	if n, err := db.DeleteMulti("test", "k00", "k01", "k02", "missing"); err != nil || n != 3 {
+		t.Errorf("db.DeleteMulti() == %d, %v, want 3, nil", n, err)
+	}
+	if n, _ := db.Count("test"); n != 17 {
+		t.Errorf("db.Count() == %d, want 17", n)
+	}
+
+	// 单个键的操作在键所在的分片上进行
+	if n, err := db.Incr("test", "counter", 5); err != nil || n != 5 {
+		t.Errorf("db.Incr() == %d, %v, want 5, nil", n, err)
+	}
+	if n, _ := s.shards[s.shardOf([]byte("counter"))].Incr("test", "counter", 1); n != 6 {
+		t.Errorf("Incr on the owning shard == %d, want 6", n)
+	}
+	if _, err := db.SAdd("test", "set", "a", "b"); err != nil {
+		t.Fatalf("db.SAdd() failed, err=%v", err)
+	}
+	if members, err := db.SMembers("test", "set"); err != nil || len(members) != 2 {
+		t.Errorf("db.SMembers() == %q, %v, want 2 members", members, err)
+	}
+	if names, err := db.ListCollections("test"); err != nil || len(names) != 1 || names[0] != "set" {
+		t.Errorf("db.ListCollections() == %q, %v, want [set]", names, err)
+	}
+	id, err := db.AddUUID("test", "u")
+	if err != nil {
+		t.Fatalf("db.AddUUID() failed, err=%v", err)
+	}
+	if got := string(db.Get("test", id)); got != "u" {
+		t.Errorf("db.Get(uuid) == %q, want u", got)
+	}
+
+	if err := db.CreateTable("other"); err != nil {
+		t.Fatalf("db.CreateTable(other) failed, err=%v", err)
+	}
+	if tables, err := db.ListTables(); err != nil || len(tables) != 2 || tables[0] != "other" || tables[1] != "test" {
+		t.Errorf("db.ListTables() == %q, %v, want [other test]", tables, err)
+	}
+	if !db.HasTable("other") || db.HasTable("missing") {
+		t.Errorf("db.HasTable() wrong")
+	}
+	n, err := db.CopyTable("test", "copy")
+	if err != nil {
+		t.Fatalf("db.CopyTable() failed, err=%v", err)
+	}
+	if c, _ := db.Count("copy"); c != n {
+		t.Errorf("db.Count(copy) == %d, want %d", c, n)
+	}
+	if info, err := db.Describe(); err != nil || info.Path != s.dir || len(info.Tables) != 3 {
+		t.Errorf("db.Describe() == %+v, %v, want 3 tables", info, err)
+	}
+
+	// 需要跨分片原子完成的操作明确拒绝
+	unsupported := map[string]error{}
+	_, unsupported["Begin"] = db.Begin(true)
+	unsupported["RenameTable"] = db.RenameTable("test", "renamed")
+	_, unsupported["InitTable"] = db.InitTable("seeded", map[interface{}]interface{}{"a": 1})
+	unsupported["Add"] = db.Add("test", "x")
+	_, unsupported["DrainBatch"] = db.DrainBatch("test", "other", 1)
+	unsupported["Update"] = db.Update("test", func(Table) error { return nil })
+	unsupported["CreateUniqueIndex"] = db.CreateUniqueIndex("test", "u", func(k, v []byte) ([]interface{}, error) { return nil, nil })
+	unsupported["Query"] = db.Query("test").Run(&[]struct{}{})
+	for name, err := range unsupported {
+		if !errors.Is(err, ErrUnsupported) || !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("db.%v() err=%v, want ErrUnsupported", name, err)
+		}
+	}
+	if !db.HasTable("test") || db.HasTable("renamed") {
+		t.Errorf("refused RenameTable changed the tables")
+	}
+}
+
+func TestShardedMirrorSelf(t *testing.T) {
+	s := openTestSharded(t, 2, "test")
+	s.SetMirror(s)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Set("test", "k", "v") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("s.Set() with self mirror failed, err=%v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("s.Set() with self mirror deadlocked")
+	}
+
+	standby := openTestDB(t, "test")
+	s.SetMirror(standby)
+	if err := s.SetBatch("test", map[interface{}]interface{}{"a": 1, "b": 2, "c": 3}); err != nil {
+		t.Fatalf("s.SetBatch() failed, err=%v", err)
+	}
+	if n, _ := standby.Count("test"); n != 3 {
+		t.Errorf("standby.Count() == %d, want 3 from all shards", n)
+	}
+}
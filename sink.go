@@ -0,0 +1,68 @@
+package bdb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 备份的存放位置
+type BackupSink interface {
+	// 把r中的备份以name保存，name可以包含/分隔的子目录
+	Put(name string, r io.Reader) error
+}
+
+// 存放到本地目录的BackupSink
+type DirSink string
+
+func (d DirSink) Put(name string, r io.Reader) error {
+	path := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// 在线备份并交给sink以name保存，返回备份的字节数
+func (b *dbConnection) BackupTo(sink BackupSink, name string) (int64, error) {
+	pr, pw := io.Pipe()
+	var n int64
+	var berr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n, berr = b.Backup(pw)
+		pw.CloseWithError(berr)
+	}()
+
+	err := sink.Put(name, pr)
+	// sink没有读完就返回时让备份的写入结束
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	if err == nil {
+		err = berr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("backup to %v failed:%w", name, err)
+	}
+	return n, nil
+}
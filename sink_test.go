@@ -0,0 +1,65 @@
+package bdb
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupTo(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "k", "v")
+	dir := t.TempDir()
+
+	n, err := db.BackupTo(DirSink(dir), "daily/a.db")
+	if err != nil || n == 0 {
+		t.Fatalf("db.BackupTo() == %d, %v, want size, nil", n, err)
+	}
+	restored, err := Open(filepath.Join(dir, "daily", "a.db"), 0600)
+	if err != nil {
+		t.Fatalf("Open(backup) failed, err=%v", err)
+	}
+	defer restored.Close()
+	if got := string(restored.Get("test", "k")); got != "v" {
+		t.Errorf("backup Get(k) == %q, want %q", got, "v")
+	}
+
+	errUpload := errors.New("upload failed")
+	failing := sinkFunc(func(name string, r io.Reader) error {
+		io.CopyN(io.Discard, r, 10)
+		return errUpload
+	})
+	if _, err := db.BackupTo(failing, "x"); !errors.Is(err, errUpload) {
+		t.Errorf("db.BackupTo(failing) err=%v, want errUpload", err)
+	}
+}
+
+type sinkFunc func(name string, r io.Reader) error
+
+func (f sinkFunc) Put(name string, r io.Reader) error {
+	return f(name, r)
+}
+
+func TestScheduleBackupsToSink(t *testing.T) {
+	db := openTestDB(t, "test")
+	names := make(chan string, 10)
+	sink := sinkFunc(func(name string, r io.Reader) error {
+		io.Copy(io.Discard, r)
+		names <- name
+		return nil
+	})
+	if err := db.ScheduleBackups(BackupSchedule{Interval: 10 * time.Millisecond, Pattern: "b-150405.000", Sink: sink}); err != nil {
+		t.Fatalf("db.ScheduleBackups() failed, err=%v", err)
+	}
+	defer db.StopBackups()
+	select {
+	case name := <-names:
+		if len(name) != len("b-150405.000") {
+			t.Errorf("backup name == %q, want pattern b-150405.000", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no scheduled backup sent to the sink")
+	}
+}
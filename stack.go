@@ -0,0 +1,95 @@
+package bdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 后进先出的持久栈，每项的键是8字节大端的递减序号，最新的项键最小，
+// Pop、Peek只需定位到第一个8字节键。每个操作都在单个事务中完成，
+// 表中其他长度的键不属于栈，Push、Pop、Peek都会跳过它们，但Len会计入
+type Stack struct {
+	db   BoltDB
+	name string
+}
+
+// 打开名为name的栈，表不存在时创建
+func OpenStack(db BoltDB, name string) (*Stack, error) {
+	if err := db.CreateTable(name); err != nil {
+		return nil, err
+	}
+	return &Stack{db: db, name: name}, nil
+}
+
+// 表名
+func (s *Stack) Name() string {
+	return s.name
+}
+
+// 压入栈顶
+func (s *Stack) Push(value interface{}) error {
+	return s.db.Update(s.name, func(t Table) error {
+		k, _ := seqItem(t.Cursor(), true)
+		k, err := prevSeqKey(k)
+		if err != nil {
+			return err
+		}
+		if _, err := t.Get(k); err == nil {
+			return fmt.Errorf("%v.%q already exists", s.name, k)
+		}
+		return t.Set(k, value)
+	})
+}
+
+// 在一个写事务中取出并删除栈顶，栈为空时返回ErrEmpty
+func (s *Stack) Pop() (value []byte, err error) {
+	err = s.db.Update(s.name, func(t Table) error {
+		k, v := seqItem(t.Cursor(), true)
+		if k == nil {
+			return ErrEmpty
+		}
+		if v == nil {
+			return fmt.Errorf("%v.%q is a sub table", s.name, k)
+		}
+		value = append([]byte{}, v...)
+		return t.Delete(k)
+	})
+	return value, err
+}
+
+// 返回栈顶但不取出，栈为空时返回ErrEmpty
+func (s *Stack) Peek() (value []byte, err error) {
+	err = s.db.View(s.name, func(t Table) error {
+		k, v := seqItem(t.Cursor(), true)
+		if k == nil {
+			return ErrEmpty
+		}
+		if v == nil {
+			return fmt.Errorf("%v.%q is a sub table", s.name, k)
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, err
+}
+
+// 栈中的项数
+func (s *Stack) Len() (int, error) {
+	return s.db.Count(s.name)
+}
+
+// first之前的序号键，first不是8字节序号(如nil)时从最大值开始
+func prevSeqKey(first []byte) ([]byte, error) {
+	n := ^uint64(0)
+	if len(first) == 8 {
+		n = binary.BigEndian.Uint64(first)
+		if n == 0 {
+			return nil, errors.New("sequence exhausted")
+		}
+		n--
+	}
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, n)
+	return k, nil
+}
@@ -0,0 +1,68 @@
+package bdb
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	db := openTestDB(t)
+	s, err := OpenStack(db, "undo")
+	if err != nil {
+		t.Fatalf("OpenStack() failed, err=%v", err)
+	}
+	if _, err := s.Pop(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("s.Pop() on empty err=%v, want ErrEmpty", err)
+	}
+
+	for i := 0; i < 12; i++ {
+		if err := s.Push(i); err != nil {
+			t.Fatalf("s.Push(%d) failed, err=%v", i, err)
+		}
+	}
+	if v, err := s.Peek(); err != nil || string(v) != "11" {
+		t.Errorf("s.Peek() == %q, %v, want \"11\", nil", v, err)
+	}
+	if n, err := s.Len(); err != nil || n != 12 {
+		t.Errorf("s.Len() == %d, %v, want 12, nil", n, err)
+	}
+	for i := 11; i >= 6; i-- {
+		v, err := s.Pop()
+		if err != nil || string(v) != strconv.Itoa(i) {
+			t.Fatalf("s.Pop() == %q, %v, want %d", v, err, i)
+		}
+	}
+
+	// 弹出后再压入仍在栈顶
+	s.Push("new")
+	if v, _ := s.Pop(); string(v) != "new" {
+		t.Errorf("s.Pop() after Push == %q, want new", v)
+	}
+	if v, _ := s.Pop(); string(v) != "5" {
+		t.Errorf("s.Pop() == %q, want 5", v)
+	}
+}
+
+func TestStackForeignKeys(t *testing.T) {
+	db := openTestDB(t)
+	s, _ := OpenStack(db, "undo")
+	s.Push("first")
+	s.Push("second")
+	// 不是栈序号的键不影响序号的分配和弹出
+	db.Set("undo", "\x00", "low")
+	db.Set("undo", "x", "plain")
+	s.Push("third")
+
+	for _, want := range []string{"third", "second", "first"} {
+		if v, err := s.Pop(); err != nil || string(v) != want {
+			t.Errorf("s.Pop() == %q, %v, want %q", v, err, want)
+		}
+	}
+	if _, err := s.Pop(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("s.Pop() after drain err=%v, want ErrEmpty", err)
+	}
+	if got := string(db.Get("undo", "\x00")); got != "low" {
+		t.Errorf("db.Get(\\x00) == %q, want low", got)
+	}
+}
@@ -0,0 +1,146 @@
+package bdb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boltdb/bolt"
+)
+
+// dataToBytes编码方式的版本，编码不兼容地变化时递增
+const EncodingVersion = 1
+
+// 数据库概况，可直接序列化为JSON
+type DBInfo struct {
+	Path            string      `json:"path"`
+	FileSize        int64       `json:"file_size"`
+	Mode            os.FileMode `json:"mode"`
+	ReadOnly        bool        `json:"read_only"`
+	Tables          []TableInfo `json:"tables"`
+	TotalKeys       int         `json:"total_keys"`
+	EncodingVersion int         `json:"encoding_version"`
+
+	LastBackup *BackupStatus `json:"last_backup,omitempty"` // 最近一次定时备份，没有时为nil
+}
+
+// 表概况
+type TableInfo struct {
+	Name     string `json:"name"`
+	Keys     int    `json:"keys"` // 包括子表中的键
+	Sequence uint64 `json:"sequence"`
+}
+
+// 页数按文件中已分配的部分计算，空闲页包括待释放的页(PendingPageN)，
+// 空闲页占比高说明文件膨胀，可以考虑压缩
+func (b *dbConnection) PageInfo() (pageSize, usedPages, freePages int, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		pageSize = b.bdb.Info().PageSize
+		stats := b.bdb.Stats()
+		freePages = stats.FreePageN + stats.PendingPageN
+		usedPages = int(tx.Size())/pageSize - freePages
+		return nil
+	})
+	return pageSize, usedPages, freePages, err
+}
+
+// 在一个只读事务中汇总库的信息
+func (b *dbConnection) Describe() (info DBInfo, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		info = DBInfo{
+			Path:            b.bdb.Path(),
+			FileSize:        tx.Size(),
+			Mode:            b.mode,
+			ReadOnly:        b.bdb.IsReadOnly(),
+			Tables:          []TableInfo{},
+			EncodingVersion: EncodingVersion,
+			LastBackup:      b.lastBackup(),
+		}
+		if fi, err := os.Stat(info.Path); err == nil {
+			info.FileSize = fi.Size()
+		}
+
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			if internalTable(string(name)) {
+				return nil
+			}
+			ti := TableInfo{
+				Name:     string(name),
+				Keys:     bucket.Stats().KeyN,
+				Sequence: bucket.Sequence(),
+			}
+			info.Tables = append(info.Tables, ti)
+			info.TotalKeys += ti.Keys
+			return nil
+		})
+	})
+	return info, err
+}
+
+// 用bucket.Stats()统计，只遍历页头，不拷贝也不解码键值，比逐条遍历快得多；
+// bolt不保存计数，所以仍与页数成正比。子表本身及其中的键也计算在内
+func (b *dbConnection) Count(tn string) (n int, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		n = bucket.Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// bolt的运行统计，计数类的字段从打开起累计，可以两次相减得到一段时间内的值
+func (b *dbConnection) Stats() (bolt.Stats, error) {
+	if err := b.acquire(); err != nil {
+		return bolt.Stats{}, err
+	}
+	defer b.release()
+	return b.bdb.Stats(), nil
+}
+
+// 表的页统计：键数、深度、分支页和叶子页的数量及已用字节数，子表计算在内
+func (b *dbConnection) TableStats(tn string) (stats bolt.BucketStats, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		stats = bucket.Stats()
+		return nil
+	})
+	return stats, err
+}
+
+// 健康检查：在只读事务中读取根表以访问mmap，并确认数据库文件仍然存在且不小于已映射的大小
+func (b *dbConnection) Ping() error {
+	return b.view(func(tx *bolt.Tx) error {
+		fi, err := os.Stat(b.bdb.Path())
+		if err != nil {
+			return fmt.Errorf("stat database file failed:%w", err)
+		}
+		if fi.Size() < tx.Size() {
+			return fmt.Errorf("database file truncated: %d bytes, %d in use", fi.Size(), tx.Size())
+		}
+		c := tx.Cursor()
+		c.First()
+		return nil
+	})
+}
+
+// 按页统计估算表占用的文件空间：表及其子表所占的页数乘以页大小，内联在上级页中的小表按已用字节计算。
+// keys与Count相同
+func (b *dbConnection) SizeOf(tn string) (size int64, keys int, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		st := bucket.Stats()
+		pages := st.BranchPageN + st.BranchOverflowN + st.LeafPageN + st.LeafOverflowN
+		size = int64(pages)*int64(b.bdb.Info().PageSize) + int64(st.InlineBucketInuse)
+		keys = st.KeyN
+		return nil
+	})
+	return size, keys, err
+}
@@ -0,0 +1,155 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestPageInfo(t *testing.T) {
+	db := openTestDB(t, "test")
+	pageSize, used, free, err := db.PageInfo()
+	if err != nil {
+		t.Fatalf("db.PageInfo() failed, err=%v", err)
+	}
+	if pageSize <= 0 || used <= 0 {
+		t.Errorf("db.PageInfo() == %d, %d, %d", pageSize, used, free)
+	}
+
+	value := bytes.Repeat([]byte("x"), 1024)
+	for i := 0; i < 200; i++ {
+		db.Set("test", fmt.Sprintf("k%d", i), value)
+	}
+	_, used2, _, _ := db.PageInfo()
+	if used2 <= used {
+		t.Errorf("used pages %d not grown after writes, was %d", used2, used)
+	}
+
+	db.DeleteTable("test")
+	db.CreateTable("test")
+	_, _, free3, _ := db.PageInfo()
+	if free3 <= free {
+		t.Errorf("free pages %d not grown after delete, was %d", free3, free)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	db := openTestDB(t, "a", "b")
+	db.Set("a", "k1", "v")
+	db.Set("a", "k2", "v")
+	db.Add("b", "v")
+
+	info, err := db.Describe()
+	if err != nil {
+		t.Fatalf("db.Describe() failed, err=%v", err)
+	}
+	if info.Path != db.GetDBName() || info.FileSize <= 0 || info.Mode != 0600 || info.ReadOnly {
+		t.Errorf("db.Describe() == %+v", info)
+	}
+	if info.TotalKeys != 3 || len(info.Tables) != 2 {
+		t.Fatalf("db.Describe() tables=%+v total=%d", info.Tables, info.TotalKeys)
+	}
+	if a := info.Tables[0]; a.Name != "a" || a.Keys != 2 || a.Sequence != 0 {
+		t.Errorf("table a == %+v", a)
+	}
+	if b := info.Tables[1]; b.Name != "b" || b.Keys != 1 || b.Sequence != 1 {
+		t.Errorf("table b == %+v", b)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil || !bytes.Contains(data, []byte(`"total_keys":3`)) {
+		t.Errorf("json.Marshal(DBInfo) == %s, err=%v", data, err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	db := openTestDB(t, "test", "empty")
+	for i := 0; i < 2000; i++ {
+		db.Add("test", i)
+	}
+
+	if n, err := db.Count("test"); err != nil || n != 2000 {
+		t.Errorf("db.Count(test) == %d, %v, want 2000, nil", n, err)
+	}
+	if n, err := db.Count("empty"); err != nil || n != 0 {
+		t.Errorf("db.Count(empty) == %d, %v, want 0, nil", n, err)
+	}
+	if _, err := db.Count("missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.Count(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db := openTestDB(t, "test", "test/sub")
+	before, err := db.Stats()
+	if err != nil {
+		t.Fatalf("db.Stats() failed, err=%v", err)
+	}
+	for i := 0; i < 500; i++ {
+		db.Set("test", i, "value")
+	}
+	db.Set("test/sub", "k", "v")
+	after, _ := db.Stats()
+	if diff := after.Sub(&before); diff.TxStats.Write == 0 {
+		t.Errorf("Stats() write count did not grow: %+v", diff.TxStats)
+	}
+
+	st, err := db.TableStats("test")
+	if err != nil {
+		t.Fatalf("db.TableStats() failed, err=%v", err)
+	}
+	// 500个键、子表本身及其中的1个键
+	if st.KeyN != 502 || st.Depth < 2 || st.LeafPageN == 0 || st.LeafInuse == 0 || st.BucketN != 2 {
+		t.Errorf("db.TableStats() == %+v", st)
+	}
+	if _, err := db.TableStats("missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.TableStats(missing) err=%v, want ErrTableNotFound", err)
+	}
+
+	db.Close()
+	if _, err := db.Stats(); err != ErrClosed {
+		t.Errorf("db.Stats() after Close err=%v, want ErrClosed", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	db := openTestDB(t, "test")
+	if err := db.Ping(); err != nil {
+		t.Errorf("db.Ping() err=%v, want nil", err)
+	}
+	os.Remove(db.GetDBName())
+	if err := db.Ping(); err == nil {
+		t.Errorf("db.Ping() after removing the file err=nil, want error")
+	}
+	db.Close()
+	if err := db.Ping(); err != ErrClosed {
+		t.Errorf("db.Ping() after Close err=%v, want ErrClosed", err)
+	}
+	var unopened BoltDB = &dbConnection{}
+	if err := unopened.Ping(); !errors.Is(err, ErrNotOpen) {
+		t.Errorf("Ping() on unopened err=%v, want ErrNotOpen", err)
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	db := openTestDB(t, "small", "big")
+	db.Set("small", "k", "v")
+	for i := 0; i < 1000; i++ {
+		db.Set("big", i, bytes.Repeat([]byte("x"), 500))
+	}
+
+	small, n, err := db.SizeOf("small")
+	if err != nil || n != 1 || small <= 0 {
+		t.Errorf("db.SizeOf(small) == %d, %d, %v, want > 0, 1, nil", small, n, err)
+	}
+	big, n, err := db.SizeOf("big")
+	if err != nil || n != 1000 || big < 500*1000 {
+		t.Errorf("db.SizeOf(big) == %d, %d, %v, want >= %d, 1000, nil", big, n, err, 500*1000)
+	}
+	if _, _, err := db.SizeOf("missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.SizeOf(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
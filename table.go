@@ -0,0 +1,502 @@
+package bdb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// 按名字排序返回所有顶层表，子表用ListCollections列出。bdb内部使用的表不在其中
+func (b *dbConnection) ListTables() (names []string, err error) {
+	err = b.view(func(tx *bolt.Tx) error {
+		names = []string{}
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if !internalTable(string(name)) {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	})
+	return names, err
+}
+
+// bdb自己使用的顶层表
+func internalTable(name string) bool {
+	return name == indexTable || name == ttlTable || name == auditTable || name == cdcTable
+}
+
+// tn可以是子表路径；连接不可用时返回false
+func (b *dbConnection) HasTable(tn string) bool {
+	err := b.view(func(tx *bolt.Tx) error {
+		_, err := getBucket(tx, tn)
+		return err
+	})
+	return err == nil
+}
+
+// 只返回子表，普通键值会被跳过
+func (b *dbConnection) ListCollections(tn string) (names []string, err error) {
+	ctx, cancel := b.opContext()
+	defer cancel()
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		return forEach(ctx, bucket, func(k, v []byte) error {
+			if v == nil && bucket.Bucket(k) != nil {
+				names = append(names, string(k))
+			}
+			return nil
+		})
+	})
+	return names, err
+}
+
+// 按键的顺序把键值依次折叠进SHA-256，内容相同的表哈希相同，与写入历史无关
+func (b *dbConnection) TableHash(tn string) (sum []byte, err error) {
+	ctx, cancel := b.opContext()
+	defer cancel()
+	err = b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+
+		h := sha256.New()
+		err = forEach(ctx, bucket, func(k, v []byte) error {
+			hashKV(h, k, v)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		sum = h.Sum(nil)
+		return nil
+	})
+	return sum, err
+}
+
+// 把一条键值折叠进h，带上长度，避免不同的键值拼接出相同的字节流
+func hashKV(h hash.Hash, k, v []byte) {
+	var n [binary.MaxVarintLen64]byte
+	h.Write(n[:binary.PutUvarint(n[:], uint64(len(k)))])
+	h.Write(k)
+	h.Write(n[:binary.PutUvarint(n[:], uint64(len(v)))])
+	h.Write(v)
+}
+
+// 遍历src，把每条记录写入shardFunc(k, v)返回的表，表不存在时自动创建，src保持不变。
+// 写入与Set一样经过钩子、索引、配额和镜像等。
+// shardFunc返回空串表示跳过该条，子表不参与拆分。分批提交，出错时返回已提交的计数
+func (b *dbConnection) Shard(src string, shardFunc func(k, v []byte) string) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := b.updateInBatches(src, func(tx *bolt.Tx, kvs []KV) error {
+		batch := make(map[string]int)
+		for _, kv := range kvs {
+			if kv.Value == nil {
+				continue
+			}
+			name := shardFunc(kv.Key, kv.Value)
+			if name == "" {
+				continue
+			}
+			if name == src {
+				return fmt.Errorf("cannot shard %v into itself", src)
+			}
+
+			bucket, err := createBucket(tx, name)
+			if err != nil {
+				return fmt.Errorf("create bucket (%v) failed: %s", name, err)
+			}
+			if _, ok := batch[name]; !ok {
+				if err := b.mirrorWrite(tx, func(m BoltDB) error { return m.CreateTable(name) }); err != nil {
+					return err
+				}
+			}
+			if err = b.putMirrored(tx, bucket, name, kv.Key, kv.Value); err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", name, kv.Key, err)
+			}
+			batch[name]++
+		}
+		// 只在本批成功后计入
+		tx.OnCommit(func() {
+			for name, n := range batch {
+				counts[name] += n
+			}
+		})
+		return nil
+	})
+	return counts, err
+}
+
+// 分批用transform重写每个值，返回值与原值相同时不写入。
+// transform出错时本批回滚，之前的批次已经提交，返回已提交的改动条数。改写同样写到镜像
+func (b *dbConnection) TransformValues(tn string, transform func(k, v []byte) ([]byte, error)) (int, error) {
+	changed := 0
+	err := b.updateInBatches(tn, func(tx *bolt.Tx, kvs []KV) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, kv := range kvs {
+			if kv.Value == nil {
+				continue
+			}
+			nv, err := transform(kv.Key, kv.Value)
+			if err != nil {
+				return fmt.Errorf("transform %v.%q failed: %v", tn, kv.Key, err)
+			}
+			if bytes.Equal(nv, kv.Value) {
+				continue
+			}
+			if err = b.putMirrored(tx, bucket, tn, kv.Key, nv); err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", tn, kv.Key, err)
+			}
+			n++
+		}
+		tx.OnCommit(func() { changed += n })
+		return nil
+	})
+	return changed, err
+}
+
+// 建表、判空、写入在同一个写事务中完成，重启时表中已有数据则什么都不做。
+// 镜像按主库的结果建表和写入，不再自己判空
+func (b *dbConnection) InitTable(tn string, seed map[interface{}]interface{}) (seeded bool, err error) {
+	kvs, err := encodeMap(seed)
+	if err != nil {
+		return false, err
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		seeded = false
+		bucket, err := createBucket(tx, tn)
+		if err != nil {
+			return fmt.Errorf("create bucket (%v) failed: %s", tn, err)
+		}
+		if err := b.mirrorWrite(tx, func(m BoltDB) error { return m.CreateTable(tn) }); err != nil {
+			return err
+		}
+		if k, _ := bucket.Cursor().First(); k != nil {
+			return nil
+		}
+
+		for _, kv := range kvs {
+			if err := b.putMirrored(tx, bucket, tn, kv.Key, kv.Value); err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", tn, kv.Key, err)
+			}
+		}
+		seeded = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return seeded, nil
+}
+
+// dst必须是新表，已存在时返回错误；复制在一个写事务中完成，src保持不变。
+// start、end为nil表示不限，子表不复制；镜像中同样建表并写入
+func (b *dbConnection) ExtractRange(src, dst string, start, end interface{}) (count int, err error) {
+	s, e, err := rangeBounds(start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := b.opContext()
+	defer cancel()
+	err = b.update(func(tx *bolt.Tx) error {
+		count = 0
+		from, err := getBucket(tx, src)
+		if err != nil {
+			return err
+		}
+		parent, name, err := parentOf(tx, dst, true)
+		if err != nil {
+			return err
+		}
+		to, err := parent.CreateBucket(name)
+		if err != nil {
+			return fmt.Errorf("create bucket (%v) failed: %s", dst, err)
+		}
+		if err := b.mirrorWrite(tx, func(m BoltDB) error { return m.CreateTable(dst) }); err != nil {
+			return err
+		}
+
+		return walkRange(ctx, from, s, e, func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			if err := b.putMirrored(tx, to, dst, k, v); err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", dst, k, err)
+			}
+			count++
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// fn返回delete为true时删除该条，否则newValue不为nil且编码后与原值不同时改写，
+// 返回改写和删除的总条数。按batchSize分批提交以限制内存：每批内是原子的，
+// 但整张表不是，fn出错时之前的批次已经提交，重跑时fn需要能处理已改过的值。改写和删除都写到镜像
+func (b *dbConnection) UpdateAll(tn string, fn func(k, v []byte) (newValue interface{}, delete bool, err error)) (int, error) {
+	changed := 0
+	err := b.updateInBatches(tn, func(tx *bolt.Tx, kvs []KV) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, kv := range kvs {
+			if kv.Value == nil {
+				continue
+			}
+			nv, del, err := fn(kv.Key, kv.Value)
+			if err != nil {
+				return fmt.Errorf("update %v.%q failed: %v", tn, kv.Key, err)
+			}
+
+			if del {
+				if err = b.del(tx, bucket, tn, kv.Key); err != nil {
+					return fmt.Errorf("delete %v.%q failed: %w", tn, kv.Key, err)
+				}
+				k := kv.Key
+				if err = b.mirrorWrite(tx, func(m BoltDB) error { return m.Delete(tn, k) }); err != nil {
+					return err
+				}
+				n++
+				continue
+			}
+			if nv == nil {
+				continue
+			}
+			v, err := dataToBytes(nv)
+			if err != nil {
+				return fmt.Errorf("invalid value of %v.%q:%v", tn, kv.Key, err)
+			}
+			if bytes.Equal(v, kv.Value) {
+				continue
+			}
+			if err = b.putMirrored(tx, bucket, tn, kv.Key, v); err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", tn, kv.Key, err)
+			}
+			n++
+		}
+		tx.OnCommit(func() { changed += n })
+		return nil
+	})
+	return changed, err
+}
+
+// 在一个写事务中把oldName的全部内容(包括子表和序列号)复制到新表newName后删除oldName。
+// 过期时间、索引数据和本连接上的索引声明也一并改到newName下。
+// newName已存在时返回错误；两者都可以是子表路径
+func (b *dbConnection) RenameTable(oldName, newName string) error {
+	if newName == oldName || strings.HasPrefix(newName, oldName+TableSeparator) {
+		return fmt.Errorf("cannot rename %v to %v", oldName, newName)
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		from, err := getBucket(tx, oldName)
+		if err != nil {
+			return err
+		}
+		parent, name, err := parentOf(tx, newName, true)
+		if err != nil {
+			return err
+		}
+		to, err := parent.CreateBucket(name)
+		if err != nil {
+			return fmt.Errorf("create bucket (%v) failed: %s", newName, err)
+		}
+		if err = copyBucket(to, from); err != nil {
+			return err
+		}
+
+		parent, name, err = parentOf(tx, oldName, false)
+		if err == nil {
+			err = parent.DeleteBucket(name)
+		}
+		if err != nil {
+			return fmt.Errorf("delete bucket (%v) failed: %s", oldName, err)
+		}
+		for _, root := range []string{ttlTable, indexTable} {
+			if err := renameMeta(tx, root, oldName, newName); err != nil {
+				return fmt.Errorf("rename %v of %v failed: %s", root, oldName, err)
+			}
+		}
+		tx.OnCommit(func() { b.indexes.rename(oldName, newName) })
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.RenameTable(oldName, newName) })
+	})
+}
+
+// 把顶层表root下oldName及其子表的元数据改名到newName下，newName下残留的旧数据先删除
+func renameMeta(tx *bolt.Tx, root, oldName, newName string) error {
+	r := tx.Bucket([]byte(root))
+	if r == nil {
+		return nil
+	}
+	var names []string
+	r.ForEach(func(k, v []byte) error {
+		if name := string(k); v == nil && (name == oldName || strings.HasPrefix(name, oldName+TableSeparator)) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	for _, name := range names {
+		dst := []byte(newName + name[len(oldName):])
+		if r.Bucket(dst) != nil {
+			if err := r.DeleteBucket(dst); err != nil {
+				return err
+			}
+		}
+		to, err := r.CreateBucket(dst)
+		if err != nil {
+			return err
+		}
+		if err := copyBucket(to, r.Bucket([]byte(name))); err != nil {
+			return err
+		}
+		if err := r.DeleteBucket([]byte(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 递归复制src的键值、子表和序列号到dst
+func copyBucket(dst, src *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		sub, err := dst.CreateBucketIfNotExists(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(sub, src.Bucket(k))
+	})
+}
+
+// 删除并重建表，比逐条删除快；子表一并清掉，序列号保留，之后Add的键不会与清空前的重复
+func (b *dbConnection) TruncateTable(tn string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		seq := bucket.Sequence()
+
+		parent, name, err := parentOf(tx, tn, false)
+		if err != nil {
+			return err
+		}
+		if err = parent.DeleteBucket(name); err != nil {
+			return fmt.Errorf("delete bucket (%v) failed: %s", tn, err)
+		}
+		if bucket, err = parent.CreateBucket(name); err != nil {
+			return fmt.Errorf("create bucket (%v) failed: %s", tn, err)
+		}
+		if err = bucket.SetSequence(seq); err != nil {
+			return fmt.Errorf("set sequence error:%v", err)
+		}
+		if err = dropIndexes(tx, tn); err != nil {
+			return err
+		}
+		if err = dropExpiry(tx, tn); err != nil {
+			return err
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.TruncateTable(tn) })
+	})
+}
+
+// dstTn不存在时自动创建，已有的同名键被覆盖；子表不复制。
+// 分批提交，出错时返回已提交的条数。写入同样写到镜像
+func (b *dbConnection) CopyTable(srcTn, dstTn string) (int, error) {
+	if srcTn == dstTn {
+		return 0, fmt.Errorf("cannot copy %v into itself", srcTn)
+	}
+
+	copied := 0
+	err := b.updateInBatches(srcTn, func(tx *bolt.Tx, kvs []KV) error {
+		to, err := createBucket(tx, dstTn)
+		if err != nil {
+			return fmt.Errorf("create bucket (%v) failed: %s", dstTn, err)
+		}
+		if err := b.mirrorWrite(tx, func(m BoltDB) error { return m.CreateTable(dstTn) }); err != nil {
+			return err
+		}
+		n := 0
+		for _, kv := range kvs {
+			if kv.Value == nil {
+				continue
+			}
+			if err := b.putMirrored(tx, to, dstTn, kv.Key, kv.Value); err != nil {
+				return fmt.Errorf("set %v.%q failed: %w", dstTn, kv.Key, err)
+			}
+			n++
+		}
+		tx.OnCommit(func() { copied += n })
+		return nil
+	})
+	return copied, err
+}
+
+// 每批在本库的一个只读事务中读出，再用dst.SetBatch写入，批与批之间不是同一个快照。
+// dst中不存在该表时自动创建；子表不复制。出错时返回已写入dst的条数
+func (b *dbConnection) CopyTableTo(dst BoltDB, tn string) (int, error) {
+	if b.isSelf(dst) {
+		return b.CopyTable(tn, tn)
+	}
+	if err := dst.CreateTable(tn); err != nil {
+		return 0, err
+	}
+
+	copied := 0
+	var after []byte
+	for {
+		batch := make(map[interface{}]interface{}, batchSize)
+		n := 0
+		err := b.view(func(tx *bolt.Tx) error {
+			bucket, err := getBucket(tx, tn)
+			if err != nil {
+				return err
+			}
+			c := bucket.Cursor()
+			for k, v := seekAfter(c, after); k != nil && n < batchSize; k, v = c.Next() {
+				n++
+				after = append(after[:0], k...)
+				if v != nil {
+					batch[string(k)] = append([]byte{}, v...)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return copied, err
+		}
+		if len(batch) > 0 {
+			if err := dst.SetBatch(tn, batch); err != nil {
+				return copied, err
+			}
+			copied += len(batch)
+		}
+		if n < batchSize {
+			return copied, nil
+		}
+	}
+}
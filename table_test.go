@@ -0,0 +1,464 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestListCollections(t *testing.T) {
+	db := openTestDB(t, "parent")
+	db.Set("parent", "plain1", "v")
+	db.Set("parent", "plain2", "")
+	err := db.(*dbConnection).bdb.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("parent"))
+		for _, name := range []string{"hash:user", "set:tags"} {
+			if _, err := bucket.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("create sub buckets failed, err=%v", err)
+	}
+
+	names, err := db.ListCollections("parent")
+	if err != nil {
+		t.Fatalf("db.ListCollections() failed, err=%v", err)
+	}
+	if fmt.Sprint(names) != "[hash:user set:tags]" {
+		t.Errorf("db.ListCollections() == %v, want [hash:user set:tags]", names)
+	}
+
+	if _, err := db.ListCollections("missing"); err == nil {
+		t.Errorf("db.ListCollections(missing) should fail")
+	}
+}
+
+func TestTableHash(t *testing.T) {
+	db := openTestDB(t, "a", "b", "c")
+	db.Set("a", "k1", "v1")
+	db.Set("a", "k2", "v2")
+	// 不同的写入顺序和历史
+	db.Set("b", "k2", "old")
+	db.Set("b", "k2", "v2")
+	db.Set("b", "tmp", "x")
+	db.Delete("b", "tmp")
+	db.Set("b", "k1", "v1")
+	// 拼接后字节相同
+	db.Set("c", "k1v", "1")
+	db.Set("c", "k2", "v2")
+
+	ha, err := db.TableHash("a")
+	if err != nil {
+		t.Fatalf("db.TableHash(a) failed, err=%v", err)
+	}
+	hb, _ := db.TableHash("b")
+	hc, _ := db.TableHash("c")
+	if !bytes.Equal(ha, hb) {
+		t.Errorf("identical tables hash differently: %x != %x", ha, hb)
+	}
+	if bytes.Equal(ha, hc) {
+		t.Errorf("different tables hash equally: %x", ha)
+	}
+
+	db.Set("b", "k1", "changed")
+	if hb2, _ := db.TableHash("b"); bytes.Equal(hb, hb2) {
+		t.Errorf("hash unchanged after write")
+	}
+
+	if _, err := db.TableHash("missing"); err == nil {
+		t.Errorf("db.TableHash(missing) should fail")
+	}
+}
+
+func TestShard(t *testing.T) {
+	db := openTestDB(t, "all")
+	// 超过一批，覆盖分批续跑
+	n := batchSize + 10
+	for i := 0; i < n; i++ {
+		db.Set("all", fmt.Sprintf("k%05d", i), i)
+	}
+	db.Set("all", "skip", "x")
+
+	counts, err := db.Shard("all", func(k, v []byte) string {
+		if string(k) == "skip" {
+			return ""
+		}
+		i, _ := strconv.Atoi(string(v))
+		return fmt.Sprintf("part%d", i%3)
+	})
+	if err != nil {
+		t.Fatalf("db.Shard() failed, err=%v", err)
+	}
+	total := 0
+	for name, c := range counts {
+		total += c
+		got, _ := db.AsMap(name, KindString, KindInt64)
+		if len(got) != c {
+			t.Errorf("table %v has %d keys, counts says %d", name, len(got), c)
+		}
+	}
+	if len(counts) != 3 || total != n {
+		t.Errorf("db.Shard() counts=%v total=%d, want 3 shards and %d", counts, total, n)
+	}
+	if got := string(db.Get("part1", "k00004")); got != "4" {
+		t.Errorf("db.Get(part1, k00004) == %q, want %q", got, "4")
+	}
+	if got := string(db.Get("all", "k00004")); got != "4" {
+		t.Errorf("source table modified, db.Get(all, k00004) == %q", got)
+	}
+
+	if _, err := db.Shard("all", func(k, v []byte) string { return "all" }); err == nil {
+		t.Errorf("db.Shard() into itself should fail")
+	}
+}
+
+func TestTransformValues(t *testing.T) {
+	db := openTestDB(t, "test")
+	n := batchSize + 10
+	for i := 0; i < n; i++ {
+		db.Set("test", fmt.Sprintf("k%05d", i), fmt.Sprintf("%d,%d", i, i*2))
+	}
+	db.Set("test", "json", `{"a":1,"b":2}`)
+
+	changed, err := db.TransformValues("test", func(k, v []byte) ([]byte, error) {
+		if bytes.HasPrefix(v, []byte("{")) {
+			return v, nil
+		}
+		parts := strings.Split(string(v), ",")
+		return []byte(fmt.Sprintf(`{"a":%s,"b":%s}`, parts[0], parts[1])), nil
+	})
+	if err != nil || changed != n {
+		t.Fatalf("db.TransformValues() == %d, err=%v, want %d", changed, err, n)
+	}
+	if got := string(db.Get("test", "k01003")); got != `{"a":1003,"b":2006}` {
+		t.Errorf("db.Get(k01003) == %s", got)
+	}
+
+	// 改写失败返回错误
+	_, err = db.TransformValues("test", func(k, v []byte) ([]byte, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	if err == nil {
+		t.Errorf("db.TransformValues() should return transform error")
+	}
+	if _, err = db.TransformValues("missing", nil); err == nil {
+		t.Errorf("db.TransformValues(missing) should fail")
+	}
+}
+
+func TestInitTable(t *testing.T) {
+	db := openTestDB(t)
+	seed := map[interface{}]interface{}{"timeout": 30, "name": "default"}
+
+	seeded, err := db.InitTable("config", seed)
+	if err != nil || !seeded {
+		t.Fatalf("db.InitTable() == %v, err=%v, want seeded", seeded, err)
+	}
+	if got := string(db.Get("config", "timeout")); got != "30" {
+		t.Errorf("db.Get(timeout) == %q, want %q", got, "30")
+	}
+
+	// 已有数据时不覆盖
+	db.Set("config", "timeout", 60)
+	seeded, err = db.InitTable("config", seed)
+	if err != nil || seeded {
+		t.Errorf("db.InitTable() on existing data == %v, err=%v, want not seeded", seeded, err)
+	}
+	if got := string(db.Get("config", "timeout")); got != "60" {
+		t.Errorf("db.Get(timeout) == %q, want %q", got, "60")
+	}
+
+	if _, err = db.InitTable("bad", map[interface{}]interface{}{"": 1}); err == nil {
+		t.Errorf("db.InitTable() with empty key should fail")
+	}
+}
+
+func TestExtractRange(t *testing.T) {
+	db := openTestDB(t, "events")
+	for _, day := range []string{"2024-05-30", "2024-06-01", "2024-06-15", "2024-06-30", "2024-07-01"} {
+		db.Set("events", day, day)
+	}
+
+	n, err := db.ExtractRange("events", "june", "2024-06", "2024-07")
+	if err != nil || n != 3 {
+		t.Fatalf("db.ExtractRange() == %d, err=%v, want 3", n, err)
+	}
+	got, _ := db.AsMap("june", KindString, KindString)
+	if len(got) != 3 || got["2024-06-15"] != "2024-06-15" || got["2024-07-01"] != nil {
+		t.Errorf("june table == %v", got)
+	}
+	if db.Get("events", "2024-06-15") == nil {
+		t.Errorf("source entry removed by ExtractRange")
+	}
+
+	if _, err = db.ExtractRange("events", "june", nil, nil); err == nil {
+		t.Errorf("db.ExtractRange() into existing table should fail")
+	}
+	if n, err = db.ExtractRange("events", "before", nil, "2024-06"); err != nil || n != 1 {
+		t.Errorf("db.ExtractRange(nil, 2024-06) == %d, err=%v, want 1", n, err)
+	}
+	if n, err = db.ExtractRange("events", "after", "2024-06-30", nil); err != nil || n != 2 {
+		t.Errorf("db.ExtractRange(2024-06-30, nil) == %d, err=%v, want 2", n, err)
+	}
+}
+
+func TestUpdateAll(t *testing.T) {
+	db := openTestDB(t, "scores")
+	n := batchSize + 10
+	for i := 0; i < n; i++ {
+		db.Set("scores", fmt.Sprintf("u%05d", i), i)
+	}
+
+	changed, err := db.UpdateAll("scores", func(k, v []byte) (interface{}, bool, error) {
+		score, _ := strconv.Atoi(string(v))
+		switch {
+		case score%10 == 0:
+			return nil, true, nil
+		case score%10 == 1:
+			return nil, false, nil
+		case score%10 == 2:
+			return score, false, nil
+		}
+		return score * 2, false, nil
+	})
+	// 每10条删1条、改7条
+	if want := (n / 10) * 8; err != nil || changed != want {
+		t.Fatalf("db.UpdateAll() == %d, err=%v, want %d", changed, err, want)
+	}
+	if got := db.Get("scores", "u01000"); got != nil {
+		t.Errorf("db.Get(u01000) == %q, want deleted", got)
+	}
+	if got := string(db.Get("scores", "u01001")); got != "1001" {
+		t.Errorf("db.Get(u01001) == %q, want unchanged", got)
+	}
+	if got := string(db.Get("scores", "u01003")); got != "2006" {
+		t.Errorf("db.Get(u01003) == %q, want %q", got, "2006")
+	}
+
+	_, err = db.UpdateAll("scores", func(k, v []byte) (interface{}, bool, error) {
+		return struct{}{}, false, nil
+	})
+	if err == nil {
+		t.Errorf("db.UpdateAll() with unsupported value should fail")
+	}
+}
+
+func TestNestedTables(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.CreateTable("users/1/settings"); err != nil {
+		t.Fatalf("db.CreateTable(users/1/settings) failed, err=%v", err)
+	}
+	if err := db.Set("users/1/settings", "theme", "dark"); err != nil {
+		t.Errorf("db.Set() failed, err=%v", err)
+	}
+	if got := string(db.Get("users/1/settings", "theme")); got != "dark" {
+		t.Errorf("db.Get(users/1/settings, theme) == %q, want dark", got)
+	}
+	if got := string(db.Tarverse("users/1/settings", func(k, v []byte) []byte { return k })); got != "theme " {
+		t.Errorf("db.Tarverse() == %q, want %q", got, "theme ")
+	}
+	if names, err := db.ListCollections("users/1"); err != nil || len(names) != 1 || names[0] != "settings" {
+		t.Errorf("db.ListCollections(users/1) == %v, %v, want [settings]", names, err)
+	}
+
+	if err := db.DeleteTable("users/1/settings"); err != nil {
+		t.Errorf("db.DeleteTable(users/1/settings) failed, err=%v", err)
+	}
+	if _, err := db.GetValue("users/1/settings", "theme"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.GetValue() after delete err=%v, want ErrTableNotFound", err)
+	}
+	if names, _ := db.ListCollections("users/1"); len(names) != 0 {
+		t.Errorf("db.ListCollections(users/1) after delete == %v, want []", names)
+	}
+	if v := db.Get("users/2/settings", "theme"); v != nil {
+		t.Errorf("db.Get() on missing nested table == %q, want nil", v)
+	}
+
+	for _, tn := range []string{"users//x", "/users", "users/"} {
+		if err := db.CreateTable(tn); err == nil {
+			t.Errorf("db.CreateTable(%q) succeeded", tn)
+		}
+	}
+}
+
+func TestListTables(t *testing.T) {
+	db := openTestDB(t)
+	if names, err := db.ListTables(); err != nil || len(names) != 0 {
+		t.Errorf("db.ListTables() on empty db == %v, %v, want []", names, err)
+	}
+
+	for _, tn := range []string{"users", "orders", "users/settings"} {
+		db.CreateTable(tn)
+	}
+	if names, err := db.ListTables(); err != nil || fmt.Sprint(names) != "[orders users]" {
+		t.Errorf("db.ListTables() == %v, %v, want [orders users]", names, err)
+	}
+}
+
+func TestHasTable(t *testing.T) {
+	db := openTestDB(t, "users", "users/settings")
+	var tests = []struct {
+		tn   string
+		want bool
+	}{
+		{"users", true},
+		{"users/settings", true},
+		{"orders", false},
+		{"users/missing", false},
+		{"users//settings", false},
+	}
+	for _, test := range tests {
+		if got := db.HasTable(test.tn); got != test.want {
+			t.Errorf("db.HasTable(%q) == %v, want %v", test.tn, got, test.want)
+		}
+	}
+	db.Close()
+	if db.HasTable("users") {
+		t.Errorf("db.HasTable() after Close == true, want false")
+	}
+}
+
+func TestRenameTable(t *testing.T) {
+	db := openTestDB(t, "old", "old/sub", "taken")
+	db.Add("old", "a")
+	db.Add("old", "b")
+	db.Set("old/sub", "k", "v")
+
+	if err := db.RenameTable("old", "taken"); err == nil {
+		t.Errorf("db.RenameTable(old, taken) succeeded")
+	}
+	if err := db.RenameTable("old", "old/sub/x"); err == nil {
+		t.Errorf("db.RenameTable(old, old/sub/x) succeeded")
+	}
+	if err := db.RenameTable("old", "archive/new"); err != nil {
+		t.Fatalf("db.RenameTable() failed, err=%v", err)
+	}
+	if db.HasTable("old") {
+		t.Errorf("old table still exists")
+	}
+	if got := string(db.Get("archive/new", "2")); got != "b" {
+		t.Errorf("db.Get(archive/new, 2) == %q, want b", got)
+	}
+	if got := string(db.Get("archive/new/sub", "k")); got != "v" {
+		t.Errorf("db.Get(archive/new/sub, k) == %q, want v", got)
+	}
+
+	// 序列号一并迁移，继续Add不会覆盖
+	db.Add("archive/new", "c")
+	if got := string(db.Get("archive/new", "3")); got != "c" {
+		t.Errorf("db.Get(archive/new, 3) == %q, want c", got)
+	}
+	if err := db.RenameTable("missing", "x"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.RenameTable(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestRenameTableMeta(t *testing.T) {
+	db := openTestDB(t, "old")
+	db.SetWithTTL("old", "short", "v", 30*time.Millisecond)
+	db.Set("old", "u1", "red")
+	byValue := func(k, v []byte) ([]interface{}, error) { return []interface{}{string(v)}, nil }
+	if err := db.CreateIndex("old", "value", byValue); err != nil {
+		t.Fatalf("db.CreateIndex() failed, err=%v", err)
+	}
+
+	if err := db.RenameTable("old", "new"); err != nil {
+		t.Fatalf("db.RenameTable() failed, err=%v", err)
+	}
+	if kvs, err := db.GetByIndex("new", "value", "red"); err != nil || keysOf(kvs) != "[u1]" {
+		t.Errorf("db.GetByIndex(new) == %v, %v, want [u1]", keysOf(kvs), err)
+	}
+	// 改名后写入仍然维护索引
+	db.Set("new", "u2", "red")
+	if kvs, _ := db.GetByIndex("new", "value", "red"); keysOf(kvs) != "[u1 u2]" {
+		t.Errorf("db.GetByIndex(new) after Set == %v, want [u1 u2]", keysOf(kvs))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if v, err := db.GetValue("new", "short"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.GetValue(new, short) after expiry == %q, %v, want ErrKeyNotFound", v, err)
+	}
+
+	// 旧名字下不残留过期时间和索引
+	db.CreateTable("old")
+	db.Set("old", "short", "again")
+	if err := db.CreateIndex("old", "value", byValue); err != nil {
+		t.Fatalf("db.CreateIndex(old) failed, err=%v", err)
+	}
+	if kvs, _ := db.GetByIndex("old", "value", "red"); len(kvs) != 0 {
+		t.Errorf("db.GetByIndex(old) == %v, want none", keysOf(kvs))
+	}
+	err := db.(*dbConnection).view(func(tx *bolt.Tx) error {
+		if ttlBucket(tx, "old", ttlKeys) != nil {
+			t.Errorf("expiry of old left behind")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTruncateTable(t *testing.T) {
+	db := openTestDB(t, "cache", "cache/sub")
+	db.Add("cache", "a")
+	db.Add("cache", "b")
+	db.Set("cache/sub", "k", "v")
+
+	if err := db.TruncateTable("cache"); err != nil {
+		t.Fatalf("db.TruncateTable() failed, err=%v", err)
+	}
+	if n, err := db.Count("cache"); err != nil || n != 0 {
+		t.Errorf("db.Count(cache) == %d, %v, want 0, nil", n, err)
+	}
+	db.Add("cache", "c")
+	if got := string(db.Get("cache", "3")); got != "c" {
+		t.Errorf("db.Get(cache, 3) == %q, want c (sequence kept)", got)
+	}
+	if err := db.TruncateTable("missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.TruncateTable(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
+
+func TestCopyTable(t *testing.T) {
+	db := openTestDB(t, "src", "src/sub")
+	n := batchSize + 10
+	kvs := make(map[interface{}]interface{})
+	for i := 0; i < n; i++ {
+		kvs[i] = strconv.Itoa(i)
+	}
+	db.SetBatch("src", kvs)
+	db.Set("src/sub", "k", "v")
+
+	if got, err := db.CopyTable("src", "staging/src"); err != nil || got != n {
+		t.Errorf("db.CopyTable() == %d, %v, want %d, nil", got, err, n)
+	}
+	if got := string(db.Get("staging/src", batchSize)); got != strconv.Itoa(batchSize) {
+		t.Errorf("db.Get(staging/src, %d) == %q", batchSize, got)
+	}
+	if db.HasTable("staging/src/sub") {
+		t.Errorf("sub table was copied")
+	}
+	if _, err := db.CopyTable("src", "src"); err == nil {
+		t.Errorf("db.CopyTable(src, src) succeeded")
+	}
+
+	other := openTestDB(t)
+	if got, err := db.CopyTableTo(other, "src"); err != nil || got != n {
+		t.Errorf("db.CopyTableTo() == %d, %v, want %d, nil", got, err, n)
+	}
+	if c, _ := other.Count("src"); c != n {
+		t.Errorf("other.Count(src) == %d, want %d", c, n)
+	}
+	if _, err := db.CopyTableTo(other, "missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("db.CopyTableTo(missing) err=%v, want ErrTableNotFound", err)
+	}
+}
@@ -0,0 +1,49 @@
+package bdb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 操作超过OpTimeout时返回
+var ErrOpTimeout = errors.New("operation timed out")
+
+// d为0表示不限时。超时只在遍历的每一步之间检查，单次读写无法中途打断
+func (b *dbConnection) SetOpTimeout(d time.Duration) {
+	b.opTimeout.Store(int64(d))
+}
+
+// 单次操作的上下文，设置了OpTimeout时带截止时间
+func (b *dbConnection) opContext() (context.Context, context.CancelFunc) {
+	return b.withTimeout(context.Background())
+}
+
+// 在ctx上加上OpTimeout，两者先到者生效
+func (b *dbConnection) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	d := time.Duration(b.opTimeout.Load())
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, d, ErrOpTimeout)
+}
+
+// 上下文结束时返回原因
+func ctxErr(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// 带超时检查的bucket.ForEach
+func forEach(ctx context.Context, bucket *bolt.Bucket, fn func(k, v []byte) error) error {
+	return bucket.ForEach(func(k, v []byte) error {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		return fn(k, v)
+	})
+}
@@ -0,0 +1,38 @@
+package bdb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestOpTimeout(t *testing.T) {
+	db := openTestDB(t, "test")
+	for i := 0; i < 10; i++ {
+		db.Set("test", i, i)
+	}
+
+	db.SetOpTimeout(time.Nanosecond)
+	if _, err := db.ForEachResumable("test", nil, func(k, v []byte) error { return nil }); err != ErrOpTimeout {
+		t.Errorf("db.ForEachResumable() err=%v, want ErrOpTimeout", err)
+	}
+	if err := db.Match("test", "*", func(k, v []byte) bool { return true }); err != ErrOpTimeout {
+		t.Errorf("db.Match() err=%v, want ErrOpTimeout", err)
+	}
+	if _, err := db.TableHash("test"); err != ErrOpTimeout {
+		t.Errorf("db.TableHash() err=%v, want ErrOpTimeout", err)
+	}
+	if err := db.ScanJSON("test", &bytes.Buffer{}, false); err != ErrOpTimeout {
+		t.Errorf("db.ScanJSON() err=%v, want ErrOpTimeout", err)
+	}
+	// 单次读写不受影响
+	if err := db.Set("test", "k", "v"); err != nil {
+		t.Errorf("db.Set() failed, err=%v", err)
+	}
+
+	db.SetOpTimeout(0)
+	n := 0
+	if _, err := db.ForEachResumable("test", nil, func(k, v []byte) error { n++; return nil }); err != nil || n != 11 {
+		t.Errorf("db.ForEachResumable() visited %d, err=%v, want 11", n, err)
+	}
+}
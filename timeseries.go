@@ -0,0 +1,156 @@
+package bdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 时间序列中的一个点
+type Point struct {
+	Time  time.Time
+	Value []byte
+}
+
+// 把一组点的值聚合成一个，start是该组的起始时间，values按时间先后排列
+type DownsampleFunc func(start time.Time, values [][]byte) []byte
+
+// 时间点的键：UnixNano的8字节大端编码，按时间排序
+func pointKey(t time.Time) ([]byte, error) {
+	ns := t.UnixNano()
+	if ns < 0 {
+		return nil, fmt.Errorf("time %v before 1970 not supported", t)
+	}
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(ns))
+	return k, nil
+}
+
+// 早于1970年的from按1970年处理，之前不会有点
+func notBeforeEpoch(t time.Time) time.Time {
+	if epoch := time.Unix(0, 0); t.Before(epoch) {
+		return epoch
+	}
+	return t
+}
+
+func pointTime(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k)))
+}
+
+// 同一纳秒已有点时顺延1纳秒，不会覆盖已有的点
+func (b *dbConnection) AppendPoint(tn string, t time.Time, value []byte) error {
+	k, err := pointKey(t)
+	if err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		for bucket.Get(k) != nil {
+			n := binary.BigEndian.Uint64(k) + 1
+			if n == 0 {
+				return errors.New("time series key overflow")
+			}
+			binary.BigEndian.PutUint64(k, n)
+		}
+		stored, err := b.putValue(tx, bucket, tn, k, value)
+		if err != nil {
+			return fmt.Errorf("append %v.%v failed: %w", tn, pointTime(k), err)
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, k, stored) })
+	})
+}
+
+// 按时间先后返回[from, to)内的点，不是8字节的键跳过
+func (b *dbConnection) QueryRange(tn string, from, to time.Time) ([]Point, error) {
+	var points []Point
+	err := b.walkPoints(tn, from, to, func(t time.Time, v []byte) {
+		points = append(points, Point{Time: t, Value: append([]byte{}, v...)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// 把[from, to)按step从from开始分组，每组非空时用fn聚合成一个点，点的时间是组的起始时间
+func (b *dbConnection) Downsample(tn string, from, to time.Time, step time.Duration, fn DownsampleFunc) ([]Point, error) {
+	return downsample(from, step, fn, func(each func(t time.Time, v []byte)) error {
+		return b.walkPoints(tn, from, to, each)
+	})
+}
+
+// 按时间先后用walk遍历的点从from开始按step分组聚合
+func downsample(from time.Time, step time.Duration, fn DownsampleFunc, walk func(each func(t time.Time, v []byte)) error) ([]Point, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("invalid downsample step %v", step)
+	}
+	from = notBeforeEpoch(from)
+
+	var points []Point
+	var start time.Time
+	var values [][]byte
+	emit := func() {
+		if len(values) > 0 {
+			points = append(points, Point{Time: start, Value: fn(start, values)})
+			values = nil
+		}
+	}
+	err := walk(func(t time.Time, v []byte) {
+		group := from.Add(t.Sub(from) / step * step)
+		if !group.Equal(start) {
+			emit()
+			start = group
+		}
+		values = append(values, append([]byte{}, v...))
+	})
+	if err != nil {
+		return nil, err
+	}
+	emit()
+	return points, nil
+}
+
+// 按时间先后遍历[from, to)内的点，v只在fn执行期间有效
+func (b *dbConnection) walkPoints(tn string, from, to time.Time, fn func(t time.Time, v []byte)) error {
+	s, e, err := pointRange(from, to)
+	if err != nil || s == nil {
+		return err
+	}
+
+	ctx, cancel := b.opContext()
+	defer cancel()
+	return b.view(func(tx *bolt.Tx) error {
+		bucket, err := getBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		return walkRange(ctx, bucket, s, e, func(k, v []byte) error {
+			if len(k) == 8 && v != nil {
+				fn(pointTime(k), v)
+			}
+			return nil
+		})
+	})
+}
+
+// [from, to)对应的键的范围，范围为空时s为nil
+func pointRange(from, to time.Time) (s, e []byte, err error) {
+	from = notBeforeEpoch(from)
+	if !from.Before(to) {
+		return nil, nil, nil
+	}
+	if s, err = pointKey(from); err != nil {
+		return nil, nil, err
+	}
+	if e, err = pointKey(to); err != nil {
+		return nil, nil, err
+	}
+	return s, e, nil
+}
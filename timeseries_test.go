@@ -0,0 +1,54 @@
+package bdb
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimeSeries(t *testing.T) {
+	db := openTestDB(t, "cpu")
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		if err := db.AppendPoint("cpu", base.Add(time.Duration(i)*time.Second), []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("db.AppendPoint(%d) failed, err=%v", i, err)
+		}
+	}
+	// 同一时刻不覆盖
+	db.AppendPoint("cpu", base, []byte("dup"))
+
+	points, err := db.QueryRange("cpu", base.Add(2*time.Second), base.Add(5*time.Second))
+	if err != nil || len(points) != 3 {
+		t.Fatalf("db.QueryRange(2s, 5s) == %v, %v, want 3 points", points, err)
+	}
+	if !points[0].Time.Equal(base.Add(2*time.Second)) || string(points[2].Value) != "4" {
+		t.Errorf("db.QueryRange(2s, 5s) == %v, want 2..4", points)
+	}
+	if all, _ := db.QueryRange("cpu", time.Time{}, base.Add(time.Minute)); len(all) != 11 || string(all[1].Value) != "dup" {
+		t.Errorf("db.QueryRange(all) == %v, want 11 points with dup second", all)
+	}
+
+	sum := func(start time.Time, values [][]byte) []byte {
+		n := 0
+		for _, v := range values {
+			i, _ := strconv.Atoi(string(v))
+			n += i
+		}
+		return []byte(strconv.Itoa(n))
+	}
+	got, err := db.Downsample("cpu", base.Add(time.Second), base.Add(10*time.Second), 4*time.Second, sum)
+	if err != nil || len(got) != 3 {
+		t.Fatalf("db.Downsample() == %v, %v, want 3 points", got, err)
+	}
+	for i, want := range []string{"10", "26", "9"} {
+		if string(got[i].Value) != want || !got[i].Time.Equal(base.Add(time.Duration(1+4*i)*time.Second)) {
+			t.Errorf("db.Downsample()[%d] == %v %q, want %v %q", i, got[i].Time, got[i].Value, base.Add(time.Duration(1+4*i)*time.Second), want)
+		}
+	}
+	if _, err := db.Downsample("cpu", base, base.Add(time.Second), 0, sum); err == nil {
+		t.Errorf("db.Downsample(step 0) err=nil, want error")
+	}
+	if err := db.AppendPoint("cpu", time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC), nil); err == nil {
+		t.Errorf("db.AppendPoint(1960) err=nil, want error")
+	}
+}
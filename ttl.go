@@ -0,0 +1,282 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// 存放过期时间的顶层表，表tn的信息在其下的子表tn中(tn整个作为一层的名字)：
+// 子表keys为 键 -> 过期时间，子表expiry为 过期时间+键 -> 空，按过期时间排序便于清理
+const ttlTable = "__bdb_ttl"
+
+var (
+	ttlKeys   = []byte("keys")
+	ttlExpiry = []byte("expiry")
+)
+
+// 未指定Options.TTLSweepInterval时的清理间隔
+const defaultSweepInterval = time.Minute
+
+// 后台过期清理协程
+type sweeper struct {
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// 写入键值，ttl后过期。过期的键在读取时视为不存在，由后台协程定期删除；
+// 遍历类的接口在删除前仍可能看到它们。之后用Set等普通写入覆盖时过期时间被清除
+func (b *dbConnection) SetWithTTL(tn string, key, value interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl:%v", ttl)
+	}
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	v, err := dataToBytes(value)
+	if err != nil {
+		return fmt.Errorf("invalid value:%v", err)
+	}
+	if err = debugCheck(key, value, k, v); err != nil {
+		return err
+	}
+
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			return err
+		}
+		stored, err := b.putValue(tx, bucket, tn, k, v)
+		if err != nil {
+			return fmt.Errorf("set %v.%v failed: %w", tn, k, err)
+		}
+		if err = setExpiry(tx, tn, k, time.Now().Add(ttl)); err != nil {
+			return err
+		}
+		return b.mirrorWrite(tx, func(m BoltDB) error { return m.SetWithTTL(tn, k, stored, ttl) })
+	})
+}
+
+// 删除所有已过期的键，返回删除的条数。后台协程定期调用，也可以手动调用。
+// 先在只读事务中检查，没有到期的键时不开启写事务
+func (b *dbConnection) PurgeExpired() (int, error) {
+	total := 0
+	for {
+		due := false
+		err := b.view(func(tx *bolt.Tx) error {
+			due = anyExpired(tx, time.Now())
+			return nil
+		})
+		if err != nil || !due {
+			return total, err
+		}
+
+		n := 0
+		err = b.update(func(tx *bolt.Tx) error {
+			n = 0
+			root := tx.Bucket([]byte(ttlTable))
+			if root == nil {
+				return nil
+			}
+
+			now := expiryKey(time.Now(), nil)
+			var tables [][]byte
+			root.ForEach(func(tn, _ []byte) error {
+				tables = append(tables, append([]byte{}, tn...))
+				return nil
+			})
+			for _, tn := range tables {
+				var keys [][]byte
+				c := root.Bucket(tn).Bucket(ttlExpiry).Cursor()
+				for ek, _ := c.First(); ek != nil && bytes.Compare(ek[:8], now) <= 0 && n+len(keys) < batchSize; ek, _ = c.Next() {
+					keys = append(keys, append([]byte{}, ek[8:]...))
+				}
+				for _, k := range keys {
+					if err := clearExpiry(tx, string(tn), k); err != nil {
+						return err
+					}
+					bucket, err := getBucket(tx, string(tn))
+					if err != nil {
+						// 表已被删除
+						continue
+					}
+					if err := b.remove(tx, bucket, string(tn), k, eventExpire); err != nil {
+						return fmt.Errorf("delete %v.%q failed: %w", tn, k, err)
+					}
+				}
+				n += len(keys)
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// 是否有表存在到期的键
+func anyExpired(tx *bolt.Tx, at time.Time) bool {
+	root := tx.Bucket([]byte(ttlTable))
+	if root == nil {
+		return false
+	}
+	now := expiryKey(at, nil)
+	c := root.Cursor()
+	for tn, v := c.First(); tn != nil; tn, v = c.Next() {
+		if v != nil {
+			continue
+		}
+		ek, _ := root.Bucket(tn).Bucket(ttlExpiry).Cursor().First()
+		if ek != nil && bytes.Compare(ek[:8], now) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 键是否已过期，没有设置过期时间时返回false
+func expired(tx *bolt.Tx, tn string, k []byte) bool {
+	keys := ttlBucket(tx, tn, ttlKeys)
+	if keys == nil {
+		return false
+	}
+	exp := keys.Get(k)
+	return exp != nil && bytes.Compare(exp, expiryKey(time.Now(), nil)) <= 0
+}
+
+// 获取未过期的值，过期时与不存在一样返回nil
+func liveValue(tx *bolt.Tx, bucket *bolt.Bucket, tn string, k []byte) []byte {
+	v := bucket.Get(k)
+	if v != nil && expired(tx, tn, k) {
+		return nil
+	}
+	return v
+}
+
+func ttlBucket(tx *bolt.Tx, tn string, name []byte) *bolt.Bucket {
+	root := tx.Bucket([]byte(ttlTable))
+	if root == nil {
+		return nil
+	}
+	if tb := root.Bucket([]byte(tn)); tb != nil {
+		return tb.Bucket(name)
+	}
+	return nil
+}
+
+// 过期时间编码为8字节大端的UnixNano，后面跟键
+func expiryKey(t time.Time, k []byte) []byte {
+	ek := make([]byte, 8, 8+len(k))
+	binary.BigEndian.PutUint64(ek, uint64(t.UnixNano()))
+	return append(ek, k...)
+}
+
+func setExpiry(tx *bolt.Tx, tn string, k []byte, at time.Time) error {
+	root, err := tx.CreateBucketIfNotExists([]byte(ttlTable))
+	if err != nil {
+		return err
+	}
+	tb, err := root.CreateBucketIfNotExists([]byte(tn))
+	if err != nil {
+		return err
+	}
+	keys, err := tb.CreateBucketIfNotExists(ttlKeys)
+	if err != nil {
+		return err
+	}
+	expiry, err := tb.CreateBucketIfNotExists(ttlExpiry)
+	if err != nil {
+		return err
+	}
+
+	ek := expiryKey(at, k)
+	if err = keys.Put(k, ek[:8]); err != nil {
+		return err
+	}
+	return expiry.Put(ek, []byte{})
+}
+
+// 清除键的过期时间，普通的写入和删除都会调用
+func clearExpiry(tx *bolt.Tx, tn string, k []byte) error {
+	keys := ttlBucket(tx, tn, ttlKeys)
+	if keys == nil {
+		return nil
+	}
+	exp := keys.Get(k)
+	if exp == nil {
+		return nil
+	}
+	ek := append(append([]byte{}, exp...), k...)
+	if err := keys.Delete(k); err != nil {
+		return err
+	}
+	return ttlBucket(tx, tn, ttlExpiry).Delete(ek)
+}
+
+// 删除表tn的全部过期时间
+func dropExpiry(tx *bolt.Tx, tn string) error {
+	root := tx.Bucket([]byte(ttlTable))
+	if root == nil || root.Bucket([]byte(tn)) == nil {
+		return nil
+	}
+	return root.DeleteBucket([]byte(tn))
+}
+
+// 启动后台清理协程，只读打开或TTLSweepInterval为负时不启动
+func (b *dbConnection) startSweeper() {
+	interval := defaultSweepInterval
+	if b.opts != nil {
+		if b.opts.ReadOnly || b.opts.TTLSweepInterval < 0 {
+			return
+		}
+		if b.opts.TTLSweepInterval > 0 {
+			interval = b.opts.TTLSweepInterval
+		}
+	}
+
+	s := &b.sweeper
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				n, err := b.PurgeExpired()
+				if err != nil && err != ErrClosed {
+					b.log().Error("bdb: purge expired keys failed", "err", err)
+				} else if n > 0 {
+					b.log().Info("bdb: purged expired keys", "count", n)
+				}
+			}
+		}
+	}(s.stop, s.done)
+}
+
+// 停止后台清理协程并等待其退出
+func (b *dbConnection) stopSweeper() {
+	s := &b.sweeper
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop, s.done = nil, nil
+	}
+}
@@ -0,0 +1,117 @@
+package bdb
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetWithTTL(t *testing.T) {
+	db := openTestDB(t, "test")
+	if err := db.SetWithTTL("test", "short", "v", 20*time.Millisecond); err != nil {
+		t.Fatalf("db.SetWithTTL(short) failed, err=%v", err)
+	}
+	if err := db.SetWithTTL("test", "long", "v", time.Hour); err != nil {
+		t.Fatalf("db.SetWithTTL(long) failed, err=%v", err)
+	}
+	if err := db.SetWithTTL("test", "k", "v", 0); err == nil {
+		t.Errorf("db.SetWithTTL(0) err=nil, want error")
+	}
+	if got := string(db.Get("test", "short")); got != "v" {
+		t.Errorf("db.Get(short) before expiry == %q, want %q", got, "v")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	// 过期后清理前读取也视为不存在
+	if got := db.Get("test", "short"); got != nil {
+		t.Errorf("db.Get(short) after expiry == %q, want nil", got)
+	}
+	if ok, err := db.Has("test", "short"); err != nil || ok {
+		t.Errorf("db.Has(short) == %v, %v, want false, nil", ok, err)
+	}
+	if _, err := db.GetValue("test", "short"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.GetValue(short) err=%v, want ErrKeyNotFound", err)
+	}
+	if got := string(db.Get("test", "long")); got != "v" {
+		t.Errorf("db.Get(long) == %q, want %q", got, "v")
+	}
+
+	if n, err := db.PurgeExpired(); err != nil || n != 1 {
+		t.Errorf("db.PurgeExpired() == %d, %v, want 1, nil", n, err)
+	}
+	if n, _ := db.Count("test"); n != 1 {
+		t.Errorf("db.Count() after purge == %d, want 1", n)
+	}
+	if n, err := db.PurgeExpired(); err != nil || n != 0 {
+		t.Errorf("db.PurgeExpired() again == %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestSetClearsTTL(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.SetWithTTL("test", "k", "old", 10*time.Millisecond)
+	db.Set("test", "k", "new")
+	db.SetWithTTL("test", "gone", "v", 10*time.Millisecond)
+	db.Delete("test", "gone")
+	db.Set("test", "gone", "again")
+
+	time.Sleep(20 * time.Millisecond)
+	if got := string(db.Get("test", "k")); got != "new" {
+		t.Errorf("db.Get(k) == %q, want %q", got, "new")
+	}
+	if n, err := db.PurgeExpired(); err != nil || n != 0 {
+		t.Errorf("db.PurgeExpired() == %d, %v, want 0, nil", n, err)
+	}
+	if got := string(db.Get("test", "gone")); got != "again" {
+		t.Errorf("db.Get(gone) == %q, want %q", got, "again")
+	}
+}
+
+func TestTTLSweeper(t *testing.T) {
+	db, err := OpenWithOptions(filepath.Join(t.TempDir(), "test.db"), 0600, &Options{TTLSweepInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenWithOptions() failed, err=%v", err)
+	}
+	defer db.Close()
+	db.CreateTable("test")
+	db.SetWithTTL("test", "k", "v", time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if n, _ := db.Count("test"); n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expired key not purged by the sweeper")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if tables, _ := db.ListTables(); len(tables) != 1 || tables[0] != "test" {
+		t.Errorf("db.ListTables() == %v, want [test]", tables)
+	}
+}
+
+func TestPurgeExpiredIdle(t *testing.T) {
+	db := openTestDB(t, "test")
+	o := &testObserver{}
+	db.SetObserver(o)
+	commits := func() int {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.commits
+	}
+	// 没有设置过过期时间和没有到期的键时都不应开启写事务
+	db.PurgeExpired()
+	if n := commits(); n != 0 {
+		t.Errorf("db.PurgeExpired() without ttl committed %d times, want 0", n)
+	}
+	db.SetWithTTL("test", "k", "v", time.Hour)
+	before := commits()
+	if n, err := db.PurgeExpired(); err != nil || n != 0 {
+		t.Errorf("db.PurgeExpired() == %d, %v, want 0, nil", n, err)
+	}
+	if n := commits() - before; n != 0 {
+		t.Errorf("db.PurgeExpired() with nothing due committed %d times, want 0", n)
+	}
+}
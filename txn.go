@@ -0,0 +1,142 @@
+package bdb
+
+import (
+	"errors"
+
+	"github.com/boltdb/bolt"
+)
+
+// 手动控制的事务，不能跨goroutine使用。事务结束前连接无法Close，
+// 写事务还会独占写锁，期间其它写操作都要等待，用完须尽快Commit或Rollback
+type Txn interface {
+	Set(tn string, key, value interface{}) error    // 设置键值
+	Get(tn string, key interface{}) ([]byte, error) // 获取键值的拷贝，不存在时返回ErrKeyNotFound
+	Delete(tn string, key interface{}) error        // 删除键
+	Commit() error                                  // 提交，只读事务直接结束
+	Rollback() error                                // 回滚，事务已结束时什么也不做，可以defer调用
+}
+
+var errRolledBack = errors.New("transaction rolled back")
+
+type txn struct {
+	b    *dbConnection
+	tx   *bolt.Tx
+	done bool
+}
+
+// 开始一个事务，writable为false时只读
+func (b *dbConnection) Begin(writable bool) (Txn, error) {
+	if err := b.acquireHandle(); err != nil {
+		return nil, err
+	}
+	if writable && b.readOnly() {
+		b.releaseHandle()
+		return nil, ErrReadOnly
+	}
+	if !writable {
+		b.flushBeforeRead()
+	} else if err := b.flushBuffer(); err != nil {
+		b.releaseHandle()
+		return nil, err
+	}
+	if writable {
+		if err := b.acquireWrite(); err != nil {
+			b.releaseHandle()
+			return nil, err
+		}
+		if err := b.breaker.allow(); err != nil {
+			b.pendingWrites.Add(-1)
+			b.releaseHandle()
+			return nil, err
+		}
+	}
+
+	tx, err := b.bdb.Begin(writable)
+	if err != nil {
+		t := &txn{b: b}
+		t.finish(writable, err, nil)
+		return nil, err
+	}
+	return &txn{b: b, tx: tx}, nil
+}
+
+func (t *txn) Set(tn string, key, value interface{}) error {
+	if t.done {
+		return bolt.ErrTxClosed
+	}
+	bucket, err := t.b.writeBucket(t.tx, tn)
+	if err != nil {
+		return err
+	}
+	return t.table(tn, bucket).Set(key, value)
+}
+
+func (t *txn) Get(tn string, key interface{}) ([]byte, error) {
+	if t.done {
+		return nil, bolt.ErrTxClosed
+	}
+	bucket, err := getBucket(t.tx, tn)
+	if err != nil {
+		return nil, err
+	}
+	return t.table(tn, bucket).Get(key)
+}
+
+func (t *txn) Delete(tn string, key interface{}) error {
+	if t.done {
+		return bolt.ErrTxClosed
+	}
+	bucket, err := getBucket(t.tx, tn)
+	if err != nil {
+		return err
+	}
+	return t.table(tn, bucket).Delete(key)
+}
+
+func (t *txn) table(tn string, bucket *bolt.Bucket) *table {
+	return &table{b: t.b, tx: t.tx, bucket: bucket, name: tn}
+}
+
+func (t *txn) Commit() error {
+	if t.done {
+		return bolt.ErrTxClosed
+	}
+	writable := t.tx.Writable()
+	var err error
+	if writable {
+		if err := t.b.applyMirror(t.tx); err != nil {
+			t.tx.Rollback()
+			t.finish(writable, nil, err)
+			return err
+		}
+		err = t.tx.Commit()
+	} else {
+		err = t.tx.Rollback()
+	}
+	t.finish(writable, err, nil)
+	if writable {
+		t.b.runAfter(t.tx)
+	}
+	return err
+}
+
+func (t *txn) Rollback() error {
+	if t.done {
+		return nil
+	}
+	writable := t.tx.Writable()
+	err := t.tx.Rollback()
+	t.finish(writable, nil, errRolledBack)
+	return err
+}
+
+// 结束事务，释放Begin时获取的资源。err、fnErr的含义同breaker.done
+func (t *txn) finish(writable bool, err, fnErr error) {
+	t.done = true
+	if writable {
+		t.b.txDone(t.tx)
+		t.b.breaker.done(err, fnErr)
+		t.b.pendingWrites.Add(-1)
+	}
+	t.b.releaseHandle()
+}
@@ -0,0 +1,124 @@
+package bdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestTxnCommit(t *testing.T) {
+	db := openTestDB(t, "accounts")
+	db.Set("accounts", "alice", 100)
+	db.Set("accounts", "bob", 0)
+
+	txn, err := db.Begin(true)
+	if err != nil {
+		t.Fatalf("db.Begin(true) failed, err=%v", err)
+	}
+	defer txn.Rollback()
+	if v, err := txn.Get("accounts", "alice"); err != nil || string(v) != "100" {
+		t.Fatalf("txn.Get(alice) == %q, %v", v, err)
+	}
+	txn.Set("accounts", "alice", 60)
+	txn.Set("accounts", "bob", 40)
+	txn.Delete("accounts", "nobody")
+	if v, err := txn.Get("accounts", "bob"); err != nil || string(v) != "40" {
+		t.Errorf("txn.Get(bob) inside txn == %q, %v, want 40", v, err)
+	}
+	if _, err := txn.Get("accounts", "nobody"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("txn.Get(nobody) err=%v, want ErrKeyNotFound", err)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("txn.Commit() failed, err=%v", err)
+	}
+	if err := txn.Commit(); err != bolt.ErrTxClosed {
+		t.Errorf("second txn.Commit() err=%v, want ErrTxClosed", err)
+	}
+	if err := txn.Set("accounts", "x", 1); err != bolt.ErrTxClosed {
+		t.Errorf("txn.Set() after Commit err=%v, want ErrTxClosed", err)
+	}
+
+	if a, b := string(db.Get("accounts", "alice")), string(db.Get("accounts", "bob")); a != "60" || b != "40" {
+		t.Errorf("after commit alice=%q bob=%q, want 60 40", a, b)
+	}
+	if n := db.PendingWrites(); n != 0 {
+		t.Errorf("db.PendingWrites() == %d after commit, want 0", n)
+	}
+}
+
+func TestTxnRollback(t *testing.T) {
+	db := openTestDB(t, "test")
+	txn, err := db.Begin(true)
+	if err != nil {
+		t.Fatalf("db.Begin(true) failed, err=%v", err)
+	}
+	txn.Set("test", "k", "v")
+	if err := txn.Rollback(); err != nil {
+		t.Errorf("txn.Rollback() failed, err=%v", err)
+	}
+	if err := txn.Rollback(); err != nil {
+		t.Errorf("second txn.Rollback() err=%v, want nil", err)
+	}
+	if v := db.Get("test", "k"); v != nil {
+		t.Errorf("db.Get(k) after rollback == %q, want nil", v)
+	}
+
+	ro, err := db.Begin(false)
+	if err != nil {
+		t.Fatalf("db.Begin(false) failed, err=%v", err)
+	}
+	if err := ro.Set("test", "k", "v"); !errors.Is(err, bolt.ErrTxNotWritable) {
+		t.Errorf("read-only txn.Set() err=%v, want ErrTxNotWritable", err)
+	}
+	if err := ro.Commit(); err != nil {
+		t.Errorf("read-only txn.Commit() err=%v", err)
+	}
+
+	// 事务都已结束，Close不会阻塞
+	db.Close()
+	if _, err := db.Begin(false); err != ErrClosed {
+		t.Errorf("db.Begin() after Close err=%v, want ErrClosed", err)
+	}
+}
+
+func TestTxnNestedCallDuringClose(t *testing.T) {
+	db := openTestDB(t, "test")
+	db.Set("test", "k", "v")
+	txn, err := db.Begin(true)
+	if err != nil {
+		t.Fatalf("db.Begin(true) failed, err=%v", err)
+	}
+
+	// 一个写操作在等待事务的写锁，Close在等待事务结束
+	written := make(chan error, 1)
+	go func() { written <- db.Set("test", "other", "v") }()
+	closed := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		db.Close()
+		close(closed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got := string(db.Get("test", "k")); got != "v" {
+			t.Errorf("db.Get() during Close == %q, want v", got)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("nested call while Close was waiting did not return")
+	}
+	if err := txn.Commit(); err != nil {
+		t.Errorf("txn.Commit() failed, err=%v", err)
+	}
+	if err := <-written; err != nil {
+		t.Errorf("db.Set() waiting for the transaction failed, err=%v", err)
+	}
+	<-closed
+}
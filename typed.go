@@ -0,0 +1,124 @@
+package bdb
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// 带类型的表，键按Set等方法的规则编码，值用codec编解码
+type TypedTable[K comparable, V any] struct {
+	db    BoltDB
+	name  string
+	codec Codec
+}
+
+// 打开表name，不存在时创建。codec为nil时使用JSONCodec。
+// K的底层类型须是字符串或整数，Iterate需要把键解码回K
+func OpenTable[K comparable, V any](db BoltDB, name string, codec Codec) (*TypedTable[K, V], error) {
+	switch reflect.TypeFor[K]().Kind() {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, fmt.Errorf("non supported key type %v", reflect.TypeFor[K]())
+	}
+	if codec == nil {
+		codec = JSONCodec
+	}
+	if err := db.CreateTable(name); err != nil {
+		return nil, err
+	}
+	return &TypedTable[K, V]{db: db, name: name, codec: codec}, nil
+}
+
+// 表名
+func (t *TypedTable[K, V]) Name() string {
+	return t.name
+}
+
+func (t *TypedTable[K, V]) Set(key K, value V) error {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode %v.%v failed: %w", t.name, key, err)
+	}
+	return t.db.Set(t.name, baseKey(key), data)
+}
+
+// 键不存在时返回ErrKeyNotFound
+func (t *TypedTable[K, V]) Get(key K) (value V, err error) {
+	data, err := t.db.GetValue(t.name, baseKey(key))
+	if err != nil {
+		return value, err
+	}
+	if err = t.codec.Unmarshal(data, &value); err != nil {
+		return value, fmt.Errorf("decode %v.%v failed: %w", t.name, key, err)
+	}
+	return value, nil
+}
+
+func (t *TypedTable[K, V]) Delete(key K) error {
+	return t.db.Delete(t.name, baseKey(key))
+}
+
+// 按键的字节序遍历，注意整数键按十进制字符串排序。子表跳过，fn返回错误时停止并返回该错误
+func (t *TypedTable[K, V]) Iterate(fn func(key K, value V) error) error {
+	return t.db.View(t.name, func(tb Table) error {
+		it := tb.Cursor()
+		defer it.Close()
+		for ; it.Valid(); it.Next() {
+			if it.Value() == nil {
+				continue
+			}
+			key, err := decodeKey[K](it.Key())
+			if err != nil {
+				return fmt.Errorf("decode key %v.%q failed: %w", t.name, it.Key(), err)
+			}
+			var value V
+			if err := t.codec.Unmarshal(it.Value(), &value); err != nil {
+				return fmt.Errorf("decode %v.%q failed: %w", t.name, it.Key(), err)
+			}
+			if err := fn(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 转成底层的string、int64或uint64，使type UserID int64这类自定义类型也能按Set的规则编码
+func baseKey[K comparable](key K) interface{} {
+	v := reflect.ValueOf(key)
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.CanInt():
+		return v.Int()
+	case v.CanUint():
+		return v.Uint()
+	}
+	return key
+}
+
+// 把dataToBytes编码的键解码回K
+func decodeKey[K comparable](b []byte) (key K, err error) {
+	v := reflect.ValueOf(&key).Elem()
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(b))
+	case v.CanInt():
+		n, err := strconv.ParseInt(string(b), 10, v.Type().Bits())
+		if err != nil {
+			return key, err
+		}
+		v.SetInt(n)
+	case v.CanUint():
+		n, err := strconv.ParseUint(string(b), 10, v.Type().Bits())
+		if err != nil {
+			return key, err
+		}
+		v.SetUint(n)
+	default:
+		return key, fmt.Errorf("non supported key type %T", key)
+	}
+	return key, nil
+}
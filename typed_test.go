@@ -0,0 +1,58 @@
+package bdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type userID int64
+
+type user struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func TestTypedTable(t *testing.T) {
+	db := openTestDB(t)
+	users, err := OpenTable[userID, user](db, "users", nil)
+	if err != nil {
+		t.Fatalf("OpenTable() failed, err=%v", err)
+	}
+
+	users.Set(1, user{Name: "alice", Email: "a@x"})
+	users.Set(2, user{Name: "bob", Email: "b@x"})
+	if u, err := users.Get(2); err != nil || u.Name != "bob" {
+		t.Errorf("users.Get(2) == %+v, %v, want bob", u, err)
+	}
+	// 与非类型化接口编码一致
+	if v := db.Get("users", 1); string(v) != `{"name":"alice","email":"a@x"}` {
+		t.Errorf("db.Get(users, 1) == %s", v)
+	}
+
+	var got []string
+	err = users.Iterate(func(id userID, u user) error {
+		got = append(got, fmt.Sprintf("%d:%s", id, u.Name))
+		return nil
+	})
+	if err != nil || fmt.Sprint(got) != "[1:alice 2:bob]" {
+		t.Errorf("users.Iterate() == %v, %v", got, err)
+	}
+
+	users.Delete(1)
+	if _, err := users.Get(1); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("users.Get(1) after delete err=%v, want ErrKeyNotFound", err)
+	}
+
+	if _, err := OpenTable[float64, user](db, "bad", nil); err == nil {
+		t.Errorf("OpenTable[float64]() succeeded")
+	}
+	names, err := OpenTable[string, []string](db, "names", JSONCodec)
+	if err != nil {
+		t.Fatalf("OpenTable[string]() failed, err=%v", err)
+	}
+	names.Set("team", []string{"a", "b"})
+	if v, err := names.Get("team"); err != nil || len(v) != 2 {
+		t.Errorf("names.Get(team) == %v, %v", v, err)
+	}
+}
@@ -0,0 +1,69 @@
+package bdb
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+func (b *dbConnection) AddUUID(tn string, value interface{}) (id string, ret error) {
+	err := b.update(func(tx *bolt.Tx) error {
+		v, err := dataToBytes(value)
+		if err != nil {
+			ret = fmt.Errorf("invalid value:%v", err)
+			return err
+		}
+		if err = debugCheck(nil, value, nil, v); err != nil {
+			ret = err
+			return err
+		}
+
+		bucket, err := b.writeBucket(tx, tn)
+		if err != nil {
+			ret = err
+			return err
+		}
+
+		// 碰撞几乎不可能，但仍在事务内检查
+		for {
+			id, err = newUUID()
+			if err != nil {
+				ret = fmt.Errorf("generate uuid error:%v", err)
+				return err
+			}
+			if bucket.Get([]byte(id)) == nil {
+				break
+			}
+		}
+
+		stored, err := b.putValue(tx, bucket, tn, []byte(id), v)
+		if err != nil {
+			ret = fmt.Errorf("set %v.%v failed: %w", tn, id, err)
+			return err
+		}
+		err = b.mirrorWrite(tx, func(m BoltDB) error { return m.Set(tn, id, stored) })
+		if err != nil {
+			ret = err
+		}
+		return err
+	})
+	if ret == nil {
+		ret = err
+	}
+	if ret != nil {
+		return "", ret
+	}
+	return id, nil
+}
+
+// 生成随机的v4 UUID
+func newUUID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]), nil
+}
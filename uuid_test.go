@@ -0,0 +1,35 @@
+package bdb
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestAddUUID(t *testing.T) {
+	db := openTestDB(t, "test")
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id, err := db.AddUUID("test", i)
+		if err != nil {
+			t.Fatalf("db.AddUUID() failed, err=%v", err)
+		}
+		if !re.MatchString(id) {
+			t.Errorf("db.AddUUID() == %q, not a v4 uuid", id)
+		}
+		if seen[id] {
+			t.Errorf("db.AddUUID() returned duplicate %q", id)
+		}
+		seen[id] = true
+
+		if got := string(db.Get("test", id)); got != strconv.Itoa(i) {
+			t.Errorf("db.Get(%q) == %q, want %d", id, got, i)
+		}
+	}
+
+	if _, err := db.AddUUID("missing", 1); err == nil {
+		t.Errorf("db.AddUUID(missing) should fail")
+	}
+}
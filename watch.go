@@ -0,0 +1,161 @@
+package bdb
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// 变化的类型
+type ChangeOp int
+
+const (
+	OpPut    ChangeOp = iota // 写入
+	OpDelete                 // 删除
+)
+
+// Watch收到的一次变化。删除时Value为删除前的值
+type Change struct {
+	Op    ChangeOp
+	Table string
+	Key   []byte
+	Value []byte
+}
+
+// 一个订阅，变化先进入不限长度的队列，由单独的协程按顺序送到out，慢的接收方不会阻塞写入
+type watcher struct {
+	tn     string
+	prefix []byte
+	out    chan Change
+
+	mu    sync.Mutex
+	queue []Change
+	wake  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// 所有订阅
+type watchers struct {
+	mu   sync.RWMutex
+	list []*watcher
+}
+
+// 订阅表tn中以prefix开头的键的变化，prefix为空时订阅整个表。
+// 写事务提交后送达，同一事务内的变化按发生的顺序送达；删除表、清空表等整表操作不逐条通知。
+// 调用返回的函数取消订阅，之后通道被关闭；Close数据库时所有通道也被关闭，关闭之后订阅得到的是已关闭的通道
+func (b *dbConnection) Watch(tn string, prefix []byte) (<-chan Change, func()) {
+	// 与Close中的unwatchAll互斥，关闭之后不再登记
+	b.lifecycle.RLock()
+	defer b.lifecycle.RUnlock()
+	if b.closed {
+		out := make(chan Change)
+		close(out)
+		return out, func() {}
+	}
+
+	w := &watcher{
+		tn:     tn,
+		prefix: append([]byte{}, prefix...),
+		out:    make(chan Change),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+
+	b.watches.mu.Lock()
+	b.watches.list = append(b.watches.list, w)
+	b.watches.mu.Unlock()
+	return w.out, func() { b.unwatch(w) }
+}
+
+func (b *dbConnection) unwatch(w *watcher) {
+	b.watches.mu.Lock()
+	for i, x := range b.watches.list {
+		if x == w {
+			b.watches.list = append(b.watches.list[:i:i], b.watches.list[i+1:]...)
+			break
+		}
+	}
+	b.watches.mu.Unlock()
+	w.stop()
+}
+
+// 取消所有订阅
+func (b *dbConnection) unwatchAll() {
+	b.watches.mu.Lock()
+	list := b.watches.list
+	b.watches.list = nil
+	b.watches.mu.Unlock()
+	for _, w := range list {
+		w.stop()
+	}
+}
+
+// 订阅了表tn中键k的订阅
+func (ws *watchers) match(tn string, k []byte) []*watcher {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	var ret []*watcher
+	for _, w := range ws.list {
+		if w.tn == tn && bytes.HasPrefix(k, w.prefix) {
+			ret = append(ret, w)
+		}
+	}
+	return ret
+}
+
+// 在写事务中调用，事务提交后把变化交给订阅者
+func (b *dbConnection) notify(tx *bolt.Tx, op ChangeOp, tn string, k, v []byte) {
+	list := b.watches.match(tn, k)
+	if len(list) == 0 {
+		return
+	}
+	c := Change{Op: op, Table: tn, Key: append([]byte{}, k...)}
+	if v != nil {
+		c.Value = append([]byte{}, v...)
+	}
+	tx.OnCommit(func() {
+		for _, w := range list {
+			w.push(c)
+		}
+	})
+}
+
+func (w *watcher) push(c Change) {
+	w.mu.Lock()
+	w.queue = append(w.queue, c)
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+func (w *watcher) run() {
+	defer close(w.out)
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.wake:
+		}
+
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, c := range queue {
+			select {
+			case w.out <- c:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package bdb
+
+import (
+	"testing"
+	"time"
+)
+
+func nextChange(t *testing.T, ch <-chan Change) Change {
+	t.Helper()
+	select {
+	case c, ok := <-ch:
+		if !ok {
+			t.Fatalf("watch channel closed")
+		}
+		return c
+	case <-time.After(time.Second):
+		t.Fatalf("no change received")
+	}
+	return Change{}
+}
+
+func TestWatch(t *testing.T) {
+	db := openTestDB(t, "test", "other")
+	ch, cancel := db.Watch("test", []byte("user:"))
+
+	db.Set("test", "user:1", "a")
+	db.Set("test", "item:1", "x")
+	db.Set("other", "user:1", "x")
+	db.Update("test", func(tb Table) error {
+		tb.Set("user:2", "b")
+		return tb.Delete("user:1")
+	})
+	db.Delete("test", "user:3")
+
+	var want = []struct {
+		op         ChangeOp
+		key, value string
+	}{
+		{OpPut, "user:1", "a"},
+		{OpPut, "user:2", "b"},
+		{OpDelete, "user:1", "a"},
+	}
+	for _, w := range want {
+		c := nextChange(t, ch)
+		if c.Op != w.op || c.Table != "test" || string(c.Key) != w.key || string(c.Value) != w.value {
+			t.Errorf("change == {%v %v %q %q}, want {%v test %q %q}", c.Op, c.Table, c.Key, c.Value, w.op, w.key, w.value)
+		}
+	}
+
+	cancel()
+	db.Set("test", "user:4", "d")
+	for c := range ch {
+		if string(c.Key) == "user:4" {
+			t.Errorf("change %q received after cancel", c.Key)
+		}
+	}
+	cancel()
+}
+
+func TestWatchClose(t *testing.T) {
+	db := openTestDB(t, "test")
+	ch, _ := db.Watch("test", nil)
+
+	// 不读取也不会阻塞写入
+	for i := 0; i < 100; i++ {
+		if err := db.Set("test", i, i); err != nil {
+			t.Fatalf("db.Set() failed, err=%v", err)
+		}
+	}
+	if c := nextChange(t, ch); string(c.Key) != "0" {
+		t.Errorf("first change key == %q, want %q", c.Key, "0")
+	}
+
+	db.Close()
+	select {
+	case <-drain(ch):
+	case <-time.After(time.Second):
+		t.Errorf("watch channel not closed by Close")
+	}
+
+	after, cancel := db.Watch("test", nil)
+	select {
+	case _, ok := <-after:
+		if ok {
+			t.Errorf("Watch() after Close received a change")
+		}
+	default:
+		t.Errorf("Watch() after Close returned an open channel")
+	}
+	cancel()
+	if n := len(db.(*dbConnection).watches.list); n != 0 {
+		t.Errorf("%d watchers registered after Close, want 0", n)
+	}
+}
+
+func drain(ch <-chan Change) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	return done
+}
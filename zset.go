@@ -0,0 +1,240 @@
+package bdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/boltdb/bolt"
+)
+
+// 有序集合的成员及分数
+type ZMember struct {
+	Member []byte
+	Score  float64
+}
+
+// 有序集合存放在键的子表中，每个成员两条记录：
+// zsetScore+分数+成员 -> 空，按分数排序；zsetMember+成员 -> 分数
+const (
+	zsetScore  = 0
+	zsetMember = 1
+)
+
+// 可按字节序比较的分数编码：正数翻转符号位，负数按位取反
+func scoreBytes(score float64) []byte {
+	if score == 0 {
+		score = 0 // -0与0相同
+	}
+	bits := math.Float64bits(score)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, bits)
+	return b
+}
+
+func scoreOf(b []byte) float64 {
+	bits := binary.BigEndian.Uint64(b)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+func zsetScoreKey(score []byte, member []byte) []byte {
+	k := make([]byte, 0, 1+len(score)+len(member))
+	k = append(k, zsetScore)
+	k = append(k, score...)
+	return append(k, member...)
+}
+
+func zsetMemberKey(member []byte) []byte {
+	return append([]byte{zsetMember}, member...)
+}
+
+// 加入成员或更新已有成员的分数，成员是新加入的时返回true
+func (b *dbConnection) ZAdd(tn string, key, member interface{}, score float64) (added bool, err error) {
+	if math.IsNaN(score) {
+		return false, errors.New("score is NaN")
+	}
+	k, err := keyToBytes(key)
+	if err != nil {
+		return false, fmt.Errorf("invalid key:%w", err)
+	}
+	m, err := keyToBytes(member)
+	if err != nil {
+		return false, fmt.Errorf("invalid member:%w", err)
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		zset, err := b.keyBucket(tx, tn, k, kindZSet, true)
+		if err != nil {
+			return err
+		}
+		mk := zsetMemberKey(m)
+		old := zset.Get(mk)
+		added = old == nil
+		if old != nil {
+			if err := zset.Delete(zsetScoreKey(old, m)); err != nil {
+				return err
+			}
+		}
+		s := scoreBytes(score)
+		if err := zset.Put(mk, s); err != nil {
+			return fmt.Errorf("add %v.%q member %q failed: %w", tn, k, m, err)
+		}
+		if err := zset.Put(zsetScoreKey(s, m), []byte{}); err != nil {
+			return fmt.Errorf("add %v.%q member %q failed: %w", tn, k, m, err)
+		}
+		return b.mirrorWrite(tx, func(mr BoltDB) error {
+			_, err := mr.ZAdd(tn, k, m, score)
+			return err
+		})
+	})
+	return added, err
+}
+
+// 删除成员，成员存在时返回true，有序集合空了时连同键一起删除
+func (b *dbConnection) ZRem(tn string, key, member interface{}) (removed bool, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return false, fmt.Errorf("invalid key:%w", err)
+	}
+	m, err := keyToBytes(member)
+	if err != nil {
+		return false, fmt.Errorf("invalid member:%w", err)
+	}
+
+	err = b.update(func(tx *bolt.Tx) error {
+		zset, err := b.keyBucket(tx, tn, k, kindZSet, false)
+		if err != nil || zset == nil {
+			return err
+		}
+		mk := zsetMemberKey(m)
+		s := zset.Get(mk)
+		if removed = s != nil; !removed {
+			return nil
+		}
+		if err := zset.Delete(zsetScoreKey(s, m)); err != nil {
+			return err
+		}
+		if err := zset.Delete(mk); err != nil {
+			return err
+		}
+		if err := zset.dropIfEmpty(); err != nil {
+			return err
+		}
+		return b.mirrorWrite(tx, func(mr BoltDB) error {
+			_, err := mr.ZRem(tn, k, m)
+			return err
+		})
+	})
+	return removed, err
+}
+
+// 成员的分数，成员不存在时返回ErrKeyNotFound
+func (b *dbConnection) ZScore(tn string, key, member interface{}) (score float64, err error) {
+	err = b.viewMember(tn, key, member, func(zset *bolt.Bucket, m []byte) error {
+		s := zset.Get(zsetMemberKey(m))
+		if s == nil {
+			return ErrKeyNotFound
+		}
+		score = scoreOf(s)
+		return nil
+	})
+	return score, err
+}
+
+// 成员按分数从小到大的排名，从0开始；分数相同时按成员的字节序。成员不存在时返回ErrKeyNotFound
+func (b *dbConnection) ZRank(tn string, key, member interface{}) (rank int, err error) {
+	err = b.viewMember(tn, key, member, func(zset *bolt.Bucket, m []byte) error {
+		s := zset.Get(zsetMemberKey(m))
+		if s == nil {
+			return ErrKeyNotFound
+		}
+		end := zsetScoreKey(s, m)
+		c := zset.Cursor()
+		for k, _ := c.Seek([]byte{zsetScore}); k != nil && !bytes.Equal(k, end); k, _ = c.Next() {
+			rank++
+		}
+		return nil
+	})
+	return rank, err
+}
+
+// 在有序集合的子表中查找成员
+func (b *dbConnection) viewMember(tn string, key, member interface{}, fn func(zset *bolt.Bucket, m []byte) error) error {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return fmt.Errorf("invalid key:%w", err)
+	}
+	m, err := keyToBytes(member)
+	if err != nil {
+		return fmt.Errorf("invalid member:%w", err)
+	}
+	return b.view(func(tx *bolt.Tx) error {
+		zset, err := b.keyBucket(tx, tn, k, kindZSet, false)
+		if err != nil {
+			return err
+		}
+		if zset == nil {
+			return ErrKeyNotFound
+		}
+		return fn(zset.Bucket, m)
+	})
+}
+
+// 按分数从小到大返回分数在[min, max]内的成员，键不存在时返回空
+func (b *dbConnection) ZRangeByScore(tn string, key interface{}, min, max float64) (members []ZMember, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key:%w", err)
+	}
+	if math.IsNaN(min) || math.IsNaN(max) || min > max {
+		return nil, nil
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		zset, err := b.keyBucket(tx, tn, k, kindZSet, false)
+		if err != nil || zset == nil {
+			return err
+		}
+		start := zsetScoreKey(scoreBytes(min), nil)
+		hi := scoreBytes(max)
+		c := zset.Cursor()
+		for sk, _ := c.Seek(start); sk != nil && sk[0] == zsetScore; sk, _ = c.Next() {
+			if bytes.Compare(sk[1:9], hi) > 0 {
+				break
+			}
+			members = append(members, ZMember{Member: append([]byte{}, sk[9:]...), Score: scoreOf(sk[1:9])})
+		}
+		return nil
+	})
+	return members, err
+}
+
+// 有序集合的成员数，键不存在时为0
+func (b *dbConnection) ZCard(tn string, key interface{}) (n int, err error) {
+	k, err := keyToBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key:%w", err)
+	}
+
+	err = b.view(func(tx *bolt.Tx) error {
+		zset, err := b.keyBucket(tx, tn, k, kindZSet, false)
+		if err != nil || zset == nil {
+			return err
+		}
+		n = zset.Stats().KeyN / 2
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,60 @@
+package bdb
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestZSet(t *testing.T) {
+	db := openTestDB(t, "boards")
+	scores := map[string]float64{"alice": 30, "bob": -5.5, "carol": 12, "dave": 30, "eve": math.Inf(1)}
+	for m, s := range scores {
+		if added, err := db.ZAdd("boards", "game1", m, s); err != nil || !added {
+			t.Fatalf("db.ZAdd(%v) == %v, %v, want true, nil", m, added, err)
+		}
+	}
+	// 更新分数不算新成员
+	if added, _ := db.ZAdd("boards", "game1", "carol", 20); added {
+		t.Errorf("db.ZAdd(update) == true, want false")
+	}
+	if s, err := db.ZScore("boards", "game1", "carol"); err != nil || s != 20 {
+		t.Errorf("db.ZScore(carol) == %v, %v, want 20, nil", s, err)
+	}
+	if n, _ := db.ZCard("boards", "game1"); n != 5 {
+		t.Errorf("db.ZCard() == %d, want 5", n)
+	}
+
+	var tests = []struct {
+		member string
+		rank   int
+	}{
+		{"bob", 0}, {"carol", 1}, {"alice", 2}, {"dave", 3}, {"eve", 4},
+	}
+	for _, test := range tests {
+		if rank, err := db.ZRank("boards", "game1", test.member); err != nil || rank != test.rank {
+			t.Errorf("db.ZRank(%v) == %d, %v, want %d", test.member, rank, err, test.rank)
+		}
+	}
+	if _, err := db.ZRank("boards", "game1", "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("db.ZRank(missing) err=%v, want ErrKeyNotFound", err)
+	}
+
+	got, err := db.ZRangeByScore("boards", "game1", 0, 30)
+	if err != nil || len(got) != 3 || string(got[0].Member) != "carol" || string(got[2].Member) != "dave" || got[2].Score != 30 {
+		t.Errorf("db.ZRangeByScore(0, 30) == %v, %v, want carol alice dave", got, err)
+	}
+	if got, _ := db.ZRangeByScore("boards", "game1", math.Inf(-1), -1); len(got) != 1 || got[0].Score != -5.5 {
+		t.Errorf("db.ZRangeByScore(-inf, -1) == %v, want bob", got)
+	}
+
+	if removed, err := db.ZRem("boards", "game1", "alice"); err != nil || !removed {
+		t.Errorf("db.ZRem(alice) == %v, %v, want true, nil", removed, err)
+	}
+	if rank, _ := db.ZRank("boards", "game1", "dave"); rank != 2 {
+		t.Errorf("db.ZRank(dave) after ZRem == %d, want 2", rank)
+	}
+	if _, err := db.ZAdd("boards", "game1", "x", math.NaN()); err == nil {
+		t.Errorf("db.ZAdd(NaN) err=nil, want error")
+	}
+}